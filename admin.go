@@ -0,0 +1,185 @@
+package spannerr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// CreateDatabaseOptions configures CreateDatabase.
+type CreateDatabaseOptions struct {
+	// Dialect is the database dialect, either "GOOGLE_STANDARD_SQL" (the default)
+	// or "POSTGRESQL".
+	Dialect string
+	// KMSKeyNames enables customer-managed encryption (CMEK) with the given Cloud
+	// KMS keys, in the form
+	// "projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>".
+	// Multi-region instances need one key per region of the instance configuration.
+	KMSKeyNames []string
+	// SkipKeyValidation disables the check that each KMS key exists and has an
+	// enabled primary version before the database is created.
+	SkipKeyValidation bool
+}
+
+// CreateDatabase creates the Client's database with the given DDL statements and
+// waits for the operation to complete.
+func (c *Client) CreateDatabase(ctx context.Context, ddl []string, opts *CreateDatabaseOptions) (*spanner.Database, error) {
+	if err := c.checkWrite("create database"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if opts == nil {
+		opts = &CreateDatabaseOptions{}
+	}
+	var enc *spanner.EncryptionConfig
+	if len(opts.KMSKeyNames) > 0 {
+		if !opts.SkipKeyValidation {
+			if err := c.validateKMSKeys(ctx, opts.KMSKeyNames); err != nil {
+				return nil, err
+			}
+		}
+		enc = &spanner.EncryptionConfig{KmsKeyNames: opts.KMSKeyNames}
+	}
+	svc, err := c.adminSpanner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	create := "CREATE DATABASE `" + c.databaseID() + "`"
+	if opts.Dialect == "POSTGRESQL" {
+		create = `CREATE DATABASE "` + c.databaseID() + `"`
+	}
+	op, err := svc.Projects.Instances.Databases.Create(c.instancePath(), &spanner.CreateDatabaseRequest{
+		CreateStatement:  create,
+		ExtraStatements:  ddl,
+		DatabaseDialect:  opts.Dialect,
+		EncryptionConfig: enc,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, adminError(err, "create database")
+	}
+	if _, err = waitOperation(ctx, svc, op); err != nil {
+		return nil, err
+	}
+	db, err := svc.Projects.Instances.Databases.Get(c.conn).Context(ctx).Do()
+	return db, adminError(err, "get database")
+}
+
+// validateKMSKeys verifies each key exists and its primary version is enabled, so
+// CMEK misconfiguration is reported clearly rather than as a failed operation.
+// Keys are read with the admin credentials, so this checks the caller's access to
+// them; it cannot check that the Spanner service agent has been granted the
+// Cloud KMS CryptoKey Encrypter/Decrypter role, which is reported by the create
+// operation itself.
+func (c *Client) validateKMSKeys(ctx context.Context, keys []string) error {
+	kms, err := cloudkms.New(c.adminHTTPClient(ctx, cloudkms.CloudPlatformScope))
+	if err != nil {
+		return errors.Wrap(err, "unable to init kms service")
+	}
+	for _, name := range keys {
+		key, err := kms.Projects.Locations.KeyRings.CryptoKeys.Get(name).Context(ctx).Do()
+		if err != nil {
+			return errors.Wrapf(err, "unable to get kms key %q", name)
+		}
+		if key.Primary == nil || key.Primary.State != "ENABLED" {
+			return errors.Errorf("kms key %q has no enabled primary version", name)
+		}
+	}
+	return nil
+}
+
+// AdminPermissionError is returned when an admin call is rejected because the
+// credentials used lack the required permissions or scopes.
+type AdminPermissionError struct {
+	// Op describes the admin call that was rejected.
+	Op  string
+	Err error
+}
+
+func (e *AdminPermissionError) Error() string {
+	return fmt.Sprintf("unable to %s: admin permission denied; the credentials need a role such as "+
+		"roles/spanner.databaseAdmin, see Client.AdminTokenSource: %s", e.Op, e.Err)
+}
+
+// Cause returns the underlying API error.
+func (e *AdminPermissionError) Cause() error { return e.Err }
+
+// Unwrap returns the underlying API error.
+func (e *AdminPermissionError) Unwrap() error { return e.Err }
+
+// adminError wraps an error returned by an admin call, turning permission
+// failures into an *AdminPermissionError.
+func adminError(err error, op string) error {
+	if err == nil {
+		return nil
+	}
+	switch ErrorCode(err) {
+	case "PERMISSION_DENIED", "UNAUTHENTICATED":
+		return &AdminPermissionError{Op: op, Err: err}
+	}
+	return errors.Wrap(err, "unable to "+op)
+}
+
+// adminSpanner returns a Spanner service authorized for database administration.
+func (c *Client) adminSpanner(ctx context.Context) (*spanner.Service, error) {
+	scopes := c.AdminScopes
+	if len(scopes) == 0 {
+		scopes = []string{spanner.SpannerAdminScope}
+	}
+	svc, err := c.newService(c.adminHTTPClient(ctx, scopes...))
+	return svc, errors.Wrap(err, "unable to init spanner admin service")
+}
+
+// adminHTTPClient returns an HTTP client carrying the admin credentials: tokens
+// from AdminTokenSource, scoped if it is a ScopedTokenSource, or otherwise the
+// Client's own credentials for scopes.
+func (c *Client) adminHTTPClient(ctx context.Context, scopes ...string) *http.Client {
+	if c.AdminTokenSource == nil {
+		return c.httpClient(scopes...)
+	}
+	c.useLogger(c.AdminTokenSource)
+	ts := c.AdminTokenSource
+	if sts, ok := ts.(ScopedTokenSource); ok {
+		ts = sts.WithScopes(scopes...)
+	}
+	return oauth2.NewClient(ctx, ts)
+}
+
+// waitOperation polls op until it completes, returning an error if the operation
+// failed.
+func waitOperation(ctx context.Context, svc *spanner.Service, op *spanner.Operation) (*spanner.Operation, error) {
+	for delay := time.Second; !op.Done; {
+		select {
+		case <-ctx.Done():
+			return op, ctx.Err()
+		case <-time.After(delay):
+		}
+		var err error
+		op, err = svc.Projects.Instances.Databases.Operations.Get(op.Name).Context(ctx).Do()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to get operation status")
+		}
+		if delay < 10*time.Second {
+			delay *= 2
+		}
+	}
+	if op.Error != nil {
+		return op, errors.Errorf("operation %s failed: %s", op.Name, op.Error.Message)
+	}
+	return op, nil
+}
+
+// instancePath returns the "projects/<p>/instances/<i>" path of the Client's database.
+func (c *Client) instancePath() string {
+	return c.conn[:strings.Index(c.conn, "/databases/")]
+}
+
+// databaseID returns the ID of the Client's database.
+func (c *Client) databaseID() string {
+	return c.conn[strings.LastIndex(c.conn, "/")+1:]
+}
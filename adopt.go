@@ -0,0 +1,81 @@
+package spannerr
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// SessionHandle is a serializable reference to a server-side session and,
+// optionally, a read-write transaction on it. It can be put into a task payload
+// so that a long workflow split across task queue invocations, possibly on other
+// instances, continues using the same session and transaction.
+type SessionHandle struct {
+	Session string `json:"session"`
+	// Seqno is the last request sequence number used on the session.
+	Seqno int64 `json:"seqno"`
+
+	TransactionID string `json:"transaction_id,omitempty"`
+	// Mutations are the mutations buffered in the transaction.
+	Mutations []*spanner.Mutation `json:"mutations,omitempty"`
+}
+
+// Handle returns a handle to the session.
+func (s *Session) Handle() SessionHandle {
+	return SessionHandle{Session: s.name, Seqno: atomic.LoadInt64(&s.seqno)}
+}
+
+// Handle returns a handle to the transaction, including its buffered mutations.
+// The transaction should not be used after the handle is taken.
+func (t *Txn) Handle() SessionHandle {
+	h := t.Session.Handle()
+	t.mu.Lock()
+	h.TransactionID = t.ID
+	h.Mutations = append([]*spanner.Mutation(nil), t.mutations...)
+	t.mu.Unlock()
+	return h
+}
+
+// AdoptSession returns a Session for a server-side session created elsewhere,
+// after checking that it still exists. Adopted sessions are not part of the
+// Client's pool and must not be passed to ReleaseSession.
+// This function wraps https://godoc.org/google.golang.org/api/spanner/v1#ProjectsInstancesDatabasesSessionsService.Get
+func (c *Client) AdoptSession(ctx context.Context, name string) (*Session, error) {
+	sess, _, err := c.adoptSession(ctx, name)
+	return sess, err
+}
+
+// adoptSession is AdoptSession, also returning the session resource.
+func (c *Client) adoptSession(ctx context.Context, name string) (*Session, *spanner.Session, error) {
+	if !strings.HasPrefix(name, c.conn+"/sessions/") {
+		return nil, nil, errors.Errorf("session %q does not belong to database %q", name, c.conn)
+	}
+	sess, err := c.session(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := sess.rpc(ctx).Get(name).Context(ctx).Do()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to adopt session")
+	}
+	return sess, res, nil
+}
+
+// AdoptTxn rehydrates the session and transaction referenced by h, as returned by
+// Txn.Handle. Transactions are only valid until they commit, roll back or are
+// aborted by Spanner, for example after ten seconds of inactivity.
+func (c *Client) AdoptTxn(ctx context.Context, h SessionHandle) (*Txn, error) {
+	if h.TransactionID == "" {
+		return nil, errors.New("session handle has no transaction")
+	}
+	sess, err := c.AdoptSession(ctx, h.Session)
+	if err != nil {
+		return nil, err
+	}
+	// keep sequence numbers increasing so Spanner doesn't treat DML as replays
+	sess.seqno = h.Seqno
+	return &Txn{Session: sess, ID: h.TransactionID, mutations: h.Mutations}, nil
+}
@@ -0,0 +1,54 @@
+package spannerr
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+)
+
+// ErrorCode returns the canonical gRPC status name of a Spanner API error, such as
+// "ABORTED" or "NOT_FOUND", or an empty string if err is not an API error.
+func ErrorCode(err error) string {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return ""
+	}
+	var body struct {
+		Error struct {
+			Status string `json:"status"`
+		} `json:"error"`
+	}
+	if json.Unmarshal([]byte(gerr.Body), &body) == nil && body.Error.Status != "" {
+		return body.Error.Status
+	}
+	// fall back to the closest status for the HTTP code
+	switch gerr.Code {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "ABORTED"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	case http.StatusInternalServerError:
+		return "INTERNAL"
+	}
+	return "UNKNOWN"
+}
+
+// IsAborted reports whether err is a Spanner ABORTED error, meaning the transaction
+// should be retried.
+func IsAborted(err error) bool {
+	return ErrorCode(err) == "ABORTED"
+}
@@ -0,0 +1,192 @@
+package spannerr
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// CommitTimestamp is the placeholder value that instructs Spanner to write the
+// commit timestamp of the transaction into a column created with the
+// allow_commit_timestamp option.
+const CommitTimestamp = "spanner.commit_timestamp()"
+
+// Conventions configures optional audit and soft-delete column conventions. Any
+// column name left empty disables the matching behavior.
+type Conventions struct {
+	// CreatedAt is set to the commit timestamp by Insert.
+	CreatedAt string
+	// UpdatedAt is set to the commit timestamp by every write built from the
+	// Conventions.
+	UpdatedAt string
+	// DeletedAt is set to the commit timestamp by SoftDelete. When set on a Client,
+	// rows with a non-NULL value in this column are skipped by ReadRow,
+	// BatchReadRows and Exists.
+	DeletedAt string
+}
+
+// Insert returns a mutation inserting v into table with the CreatedAt and UpdatedAt
+// columns set to the commit timestamp. A zero DeletedAt is written as NULL, so the
+// row is not born soft-deleted.
+func (c Conventions) Insert(table string, v interface{}) (*spanner.Mutation, error) {
+	w, err := structWrite(table, v)
+	if err != nil {
+		return nil, err
+	}
+	nullZeroTime(w, c.DeletedAt)
+	setColumn(w, c.CreatedAt, CommitTimestamp)
+	setColumn(w, c.UpdatedAt, CommitTimestamp)
+	return &spanner.Mutation{Insert: w}, nil
+}
+
+// Update returns a mutation updating the row in table matching the key of v with
+// the UpdatedAt column set to the commit timestamp.
+func (c Conventions) Update(table string, v interface{}) (*spanner.Mutation, error) {
+	return c.PartialUpdate(table, v, c.allFields(v))
+}
+
+// PartialUpdate behaves like the package level PartialUpdate, additionally setting
+// the UpdatedAt column to the commit timestamp.
+func (c Conventions) PartialUpdate(table string, v interface{}, mask FieldMask) (*spanner.Mutation, error) {
+	w, err := structWriteMask(table, v, mask.include)
+	if err != nil {
+		return nil, err
+	}
+	c.strip(w)
+	setColumn(w, c.UpdatedAt, CommitTimestamp)
+	return &spanner.Mutation{Update: w}, nil
+}
+
+// PartialUpsert behaves like the package level PartialUpsert, additionally setting
+// the UpdatedAt column to the commit timestamp. The CreatedAt column is left
+// untouched so existing rows keep their creation time.
+func (c Conventions) PartialUpsert(table string, v interface{}, mask FieldMask) (*spanner.Mutation, error) {
+	w, err := structWriteMask(table, v, mask.include)
+	if err != nil {
+		return nil, err
+	}
+	c.strip(w)
+	setColumn(w, c.UpdatedAt, CommitTimestamp)
+	return &spanner.Mutation{InsertOrUpdate: w}, nil
+}
+
+// SoftDelete returns a mutation marking the row in table matching the key of v as
+// deleted by setting the DeletedAt (and UpdatedAt) columns to the commit timestamp.
+func (c Conventions) SoftDelete(table string, v interface{}) (*spanner.Mutation, error) {
+	if c.DeletedAt == "" {
+		return nil, errors.New("no DeletedAt column configured")
+	}
+	w, err := structWriteMask(table, v, func(fieldInfo, reflect.Value) bool { return false })
+	if err != nil {
+		return nil, err
+	}
+	setColumn(w, c.DeletedAt, CommitTimestamp)
+	setColumn(w, c.UpdatedAt, CommitTimestamp)
+	return &spanner.Mutation{Update: w}, nil
+}
+
+// NotDeleted returns a SQL predicate excluding soft-deleted rows of the table with
+// the given alias (which may be empty), for use in a WHERE clause. It returns
+// "TRUE" if no DeletedAt column is configured.
+func (c Conventions) NotDeleted(alias string) string {
+	if c.DeletedAt == "" {
+		return "TRUE"
+	}
+	col := "`" + c.DeletedAt + "`"
+	if alias != "" {
+		col = alias + "." + col
+	}
+	return col + " IS NULL"
+}
+
+// allFields returns a FieldMask selecting every mapped field of v.
+func (c Conventions) allFields(v interface{}) FieldMask {
+	cols, _ := columnsOf(v)
+	return FieldMask(cols)
+}
+
+// strip removes the CreatedAt and DeletedAt columns from a write so updates never
+// overwrite them.
+func (c Conventions) strip(w *spanner.Write) {
+	for i := 0; i < len(w.Columns); i++ {
+		col := w.Columns[i]
+		if (c.CreatedAt != "" && strings.EqualFold(col, c.CreatedAt)) ||
+			(c.DeletedAt != "" && strings.EqualFold(col, c.DeletedAt)) {
+			w.Columns = append(w.Columns[:i], w.Columns[i+1:]...)
+			w.Values[0] = append(w.Values[0][:i], w.Values[0][i+1:]...)
+			i--
+		}
+	}
+}
+
+// setColumn sets col to val in a single row write, adding the column if needed.
+func setColumn(w *spanner.Write, col string, val interface{}) {
+	if col == "" {
+		return
+	}
+	for i, c := range w.Columns {
+		if strings.EqualFold(c, col) {
+			w.Values[0][i] = val
+			return
+		}
+	}
+	w.Columns = append(w.Columns, col)
+	w.Values[0] = append(w.Values[0], val)
+}
+
+// nullZeroTime replaces a zero time.Time written to col with NULL.
+func nullZeroTime(w *spanner.Write, col string) {
+	if col == "" {
+		return
+	}
+	zero := encodeValue(time.Time{})
+	for i, c := range w.Columns {
+		if strings.EqualFold(c, col) && w.Values[0][i] == zero {
+			w.Values[0][i] = nil
+		}
+	}
+}
+
+// readLive reads rows like Read, dropping soft-deleted rows if the Client has a
+// DeletedAt convention configured.
+func (s *Session) readLive(ctx context.Context, table string, keys *spanner.KeySet, columns []string) (*spanner.ResultSet, error) {
+	del := s.client.Conventions.DeletedAt
+	if del == "" {
+		return s.Read(ctx, table, keys, columns, nil)
+	}
+	// use the column if it was requested, otherwise add it and strip it again
+	idx, added := -1, false
+	for i, c := range columns {
+		if strings.EqualFold(c, del) {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		columns = append(append([]string(nil), columns...), del)
+		idx, added = len(columns)-1, true
+	}
+	res, err := s.Read(ctx, table, keys, columns, nil)
+	if err != nil {
+		return nil, err
+	}
+	live := res.Rows[:0]
+	for _, row := range res.Rows {
+		if row[idx] != nil {
+			continue
+		}
+		if added {
+			row = row[:idx]
+		}
+		live = append(live, row)
+	}
+	res.Rows = live
+	if added && res.Metadata != nil && res.Metadata.RowType != nil {
+		fs := res.Metadata.RowType.Fields
+		res.Metadata.RowType.Fields = fs[:idx]
+	}
+	return res, nil
+}
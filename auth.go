@@ -0,0 +1,83 @@
+package spannerr
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// ScopedTokenSource is a TokenSource that can also issue tokens for other scopes,
+// such as one backed by a service account key. When a Client's TokenSource is a
+// ScopedTokenSource, calls to other Google APIs, such as Cloud Storage by Export or
+// Pub/Sub by ChangeRelay, use tokens for their own scopes. Other TokenSources are
+// used as-is for every API. SecretTokenSource returns a ScopedTokenSource.
+type ScopedTokenSource interface {
+	oauth2.TokenSource
+	// WithScopes returns a TokenSource issuing tokens for scopes.
+	WithScopes(scopes ...string) oauth2.TokenSource
+}
+
+// loggingTokenSource is a TokenSource that reports failures in the background,
+// such as the secret refreshes of SecretTokenSource, through a Client's logger.
+type loggingTokenSource interface {
+	setLogf(logf func(ctx context.Context, format string, args ...interface{}))
+}
+
+// useLogger makes ts, if it is a loggingTokenSource, log through the Client.
+func (c *Client) useLogger(ts oauth2.TokenSource) {
+	if lts, ok := ts.(loggingTokenSource); ok {
+		lts.setLogf(c.logf)
+	}
+}
+
+// authTransport authenticates requests with the Client's credentials for scopes.
+// Tokens are fetched with the context of the request that needs a new one and
+// each request is sent with the base transport for its own context, so unlike a
+// client from oauth2.NewClient nothing is bound to the context the transport was
+// built with. On App Engine that context belongs to a request and stops working
+// once the request ends, while the transport of the Client's shared service
+// outlives it.
+type authTransport struct {
+	client *Client
+	scopes []string
+
+	mu  sync.Mutex
+	tok *oauth2.Token
+}
+
+// token returns the cached token, fetching a new one with ctx once it expires.
+func (t *authTransport) token(ctx context.Context) (*oauth2.Token, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tok.Valid() {
+		return t.tok, nil
+	}
+	ts, _, err := t.client.tokenSource(ctx, t.scopes)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get token")
+	}
+	t.tok = tok
+	return tok, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	tok, err := t.token(ctx)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	// the client oauth2 would use for ctx, such as urlfetch on App Engine
+	base := oauth2.NewClient(ctx, nil).Transport
+	return (&oauth2.Transport{Source: oauth2.StaticTokenSource(tok), Base: base}).RoundTrip(req)
+}
@@ -0,0 +1,367 @@
+package spannerr
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Operation is the kind of operation passed to a Client's Authorize hook.
+type Operation string
+
+const (
+	// OpQuery is a SELECT statement, executed by ExecuteSQL or streamed.
+	OpQuery Operation = "query"
+	// OpDML is an INSERT, UPDATE or DELETE statement, including each statement
+	// of a batch and partitioned DML.
+	OpDML Operation = "dml"
+	// OpRead is a key lookup or scan of a table by Read.
+	OpRead Operation = "read"
+	// OpCommit is a commit of mutations.
+	OpCommit Operation = "commit"
+)
+
+// AuthzRequest describes an operation about to be executed, for a Client's
+// Authorize hook.
+type AuthzRequest struct {
+	// Identity is the caller attached to the ctx with WithIdentity, or nil.
+	Identity interface{}
+	Op       Operation
+	// Tables are the tables the operation touches. For statements they are
+	// parsed from the SQL on a best effort basis, see StatementTables.
+	Tables []string
+	// SQL is the statement being executed, if any.
+	SQL string
+}
+
+// AuthzError is returned when a Client's Authorize hook vetoes an operation.
+type AuthzError struct {
+	Op     Operation
+	Tables []string
+	// Err is the error returned by the hook.
+	Err error
+}
+
+func (e *AuthzError) Error() string {
+	return string(e.Op) + " on " + strings.Join(e.Tables, ", ") + " not authorized: " + e.Err.Error()
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the identity of the caller, such as
+// an authenticated user, for the Client's Authorize hook.
+func WithIdentity(ctx context.Context, id interface{}) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity attached to ctx with WithIdentity, or nil.
+func IdentityFromContext(ctx context.Context) interface{} {
+	return ctx.Value(identityKey{})
+}
+
+// authorize runs the Client's Authorize hook, if any, returning an *AuthzError if
+// it vetoes the operation. A panicking hook vetoes it too.
+func (c *Client) authorize(ctx context.Context, op Operation, sql string, tables []string) error {
+	if c == nil || c.Authorize == nil {
+		return nil
+	}
+	req := &AuthzRequest{
+		Identity: IdentityFromContext(ctx),
+		Op:       op,
+		Tables:   tables,
+		SQL:      sql,
+	}
+	err := protect(func() error { return c.Authorize(ctx, req) })
+	if err == nil {
+		return nil
+	}
+	return errors.WithStack(&AuthzError{Op: op, Tables: tables, Err: err})
+}
+
+// authorizeSQL runs the Client's Authorize hook for a statement.
+func (c *Client) authorizeSQL(ctx context.Context, sql string) error {
+	if c == nil || c.Authorize == nil {
+		return nil
+	}
+	op := OpDML
+	if isQuery(sql) {
+		op = OpQuery
+	}
+	return c.authorize(ctx, op, sql, StatementTables(sql))
+}
+
+// authorizeCommit runs the Client's Authorize hook for a commit of mutations.
+func (c *Client) authorizeCommit(ctx context.Context, muts []*spanner.Mutation) error {
+	if c == nil || c.Authorize == nil || len(muts) == 0 {
+		return nil
+	}
+	var (
+		tables []string
+		seen   = map[string]bool{}
+	)
+	for _, m := range muts {
+		table := mutationTable(m)
+		if table != "" && !seen[table] {
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+	return c.authorize(ctx, OpCommit, "", tables)
+}
+
+// StatementTables returns the tables named in the FROM, JOIN, INTO, UPDATE and
+// DELETE clauses of sql, in order of appearance and without duplicates.
+// References to names defined by a WITH clause and table-valued functions such as
+// UNNEST are skipped, but a WITH clause name is still reported where it refers to
+// the table it shadows, such as within its own definition. It does not resolve
+// views, so it is a best effort for authorization and auditing rather than a full
+// SQL parser.
+func StatementTables(sql string) []string {
+	toks := sqlTokens(sql)
+	type (
+		// tableRef is a table name at toks[at]
+		tableRef struct {
+			name string
+			at   int
+		}
+		// cte is a WITH clause name, which refers to its query between the
+		// tokens closing its definition and the enclosing statement
+		cte struct {
+			name       string
+			start, end int
+		}
+	)
+	var (
+		refs []tableRef
+		ctes []cte
+	)
+	// name returns the possibly dotted table name starting at toks[i] and the
+	// index just past it, or "" if there is none. In FROM and JOIN clauses a
+	// name followed by parentheses is a table-valued function, whereas after
+	// INSERT they hold the column list.
+	name := func(i int, from bool) (string, int) {
+		if i >= len(toks) || !toks[i].ident || toks[i].keyword() {
+			return "", i
+		}
+		n := toks[i].text
+		i++
+		for i+1 < len(toks) && toks[i].text == "." && toks[i+1].ident {
+			n += "." + toks[i+1].text
+			i += 2
+		}
+		if from && i < len(toks) && toks[i].text == "(" {
+			// a table-valued function
+			return "", i
+		}
+		return n, i
+	}
+	add := func(n string, i int) {
+		if n != "" {
+			refs = append(refs, tableRef{name: n, at: i})
+		}
+	}
+	// funcs holds the token before each open parenthesis, to tell FROM in
+	// EXTRACT(DAY FROM ts) from a FROM clause
+	var funcs []string
+	for i, tok := range toks {
+		switch {
+		case tok.text == "(":
+			prev := ""
+			if i > 0 {
+				prev = strings.ToUpper(toks[i-1].text)
+			}
+			funcs = append(funcs, prev)
+			continue
+		case tok.text == ")" && len(funcs) > 0:
+			funcs = funcs[:len(funcs)-1]
+			continue
+		case !tok.ident || tok.quoted:
+			continue
+		}
+		// WITH a AS (...), b AS (...)
+		if i+2 < len(toks) && i > 0 && strings.EqualFold(toks[i+1].text, "AS") && toks[i+2].text == "(" &&
+			(strings.EqualFold(toks[i-1].text, "WITH") || strings.EqualFold(toks[i-1].text, "RECURSIVE") || toks[i-1].text == ",") {
+			ctes = append(ctes, cte{
+				name:  strings.ToUpper(tok.text),
+				start: closeParen(toks, i+3),
+				end:   closeParen(toks, i),
+			})
+			continue
+		}
+		switch strings.ToUpper(tok.text) {
+		case "JOIN":
+			n, _ := name(i+1, true)
+			add(n, i+1)
+		case "INTO", "UPDATE", "INSERT", "DELETE":
+			// INTO and FROM are optional after INSERT and DELETE, and are
+			// handled by their own cases when present
+			n, _ := name(i+1, false)
+			add(n, i+1)
+		case "FROM":
+			if len(funcs) > 0 && (funcs[len(funcs)-1] == "EXTRACT" || funcs[len(funcs)-1] == "TRIM") {
+				continue
+			}
+			// FROM a [AS] x, (SELECT ...) [AS] y, UNNEST(...) [AS] z
+			for j := i + 1; ; {
+				n, next := name(j, true)
+				if n == "" && next < len(toks) && strings.EqualFold(toks[next].text, "UNNEST") {
+					next++
+				}
+				if n != "" {
+					add(n, j)
+					j = next
+				} else if next < len(toks) && toks[next].text == "(" {
+					// the tables of subqueries are found on their own
+					j = closeParen(toks, next+1) + 1
+				} else {
+					break
+				}
+				if j < len(toks) && strings.EqualFold(toks[j].text, "AS") {
+					j++
+				}
+				if j < len(toks) && toks[j].ident && !toks[j].keyword() {
+					j++
+				}
+				if j >= len(toks) || toks[j].text != "," {
+					break
+				}
+				j++
+			}
+		}
+	}
+
+	var (
+		tables []string
+		seen   = map[string]bool{}
+	)
+refs:
+	for _, ref := range refs {
+		upper := strings.ToUpper(ref.name)
+		for _, c := range ctes {
+			if c.name == upper && ref.at > c.start && ref.at < c.end {
+				continue refs
+			}
+		}
+		if !seen[upper] {
+			seen[upper] = true
+			tables = append(tables, ref.name)
+		}
+	}
+	return tables
+}
+
+// closeParen returns the index of the first ")" from toks[i] on that closes a
+// parenthesis opened before toks[i], or len(toks) if there is none.
+func closeParen(toks []sqlToken, i int) int {
+	depth := 0
+	for ; i < len(toks); i++ {
+		switch toks[i].text {
+		case "(":
+			depth++
+		case ")":
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return len(toks)
+}
+
+// sqlToken is a token of a statement. Comments are dropped.
+type sqlToken struct {
+	text string
+	// ident is set for identifiers and keywords, and quoted for identifiers
+	// quoted with backticks.
+	ident, quoted bool
+	// value is set for params, string literals and hints, which sqlTokens
+	// drops.
+	value bool
+	// pos and end are the rune offsets of the token in the statement.
+	pos, end int
+}
+
+// keyword reports whether the token is a reserved word that can follow a table
+// name, and so can't be a table name or alias itself.
+func (t sqlToken) keyword() bool {
+	if t.quoted {
+		return false
+	}
+	switch strings.ToUpper(t.text) {
+	case "AS", "ON", "USING", "WHERE", "JOIN", "INNER", "LEFT", "RIGHT", "FULL",
+		"CROSS", "OUTER", "GROUP", "ORDER", "HAVING", "LIMIT", "OFFSET", "UNION",
+		"INTERSECT", "EXCEPT", "WINDOW", "QUALIFY", "TABLESAMPLE", "SET", "INTO",
+		"FROM", "SELECT", "VALUES", "THEN", "RETURN", "RETURNING", "WITH", "UNNEST",
+		"OR", "FOR":
+		return true
+	}
+	return false
+}
+
+// sqlTokens returns the identifiers, keywords and punctuation of sql.
+func sqlTokens(sql string) []sqlToken {
+	var toks []sqlToken
+	for _, tok := range lexSQL(sql) {
+		if !tok.value {
+			toks = append(toks, tok)
+		}
+	}
+	return toks
+}
+
+// lexSQL returns all tokens of sql, including params, string literals and hints.
+func lexSQL(sql string) []sqlToken {
+	var (
+		toks []sqlToken
+		rs   = []rune(sql)
+	)
+	for i := 0; i < len(rs); i++ {
+		switch r := rs[i]; {
+		case r == '\'' || r == '"':
+			end := endOfQuoted(rs, i)
+			toks = append(toks, sqlToken{text: string(rs[i:end]), value: true, pos: i, end: end})
+			i = end - 1
+		case r == '`':
+			end := endOfQuoted(rs, i)
+			toks = append(toks, sqlToken{text: strings.Trim(string(rs[i:end]), "`"), ident: true, quoted: true, pos: i, end: end})
+			i = end - 1
+		case r == '-' && i+1 < len(rs) && rs[i+1] == '-', r == '#':
+			for i+1 < len(rs) && rs[i+1] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			i += 2
+			for i+1 < len(rs) && !(rs[i] == '*' && rs[i+1] == '/') {
+				i++
+			}
+			i++
+		case r == '@':
+			start := i
+			if i+1 < len(rs) && rs[i+1] == '{' {
+				// a hint
+				for i+1 < len(rs) && rs[i] != '}' {
+					i++
+				}
+			} else {
+				for i+1 < len(rs) && isIdentRune(rs[i+1]) {
+					i++
+				}
+			}
+			toks = append(toks, sqlToken{text: string(rs[start : i+1]), value: true, pos: start, end: i + 1})
+		case isIdentRune(r):
+			end := i
+			for end < len(rs) && isIdentRune(rs[end]) && rs[end] != '@' {
+				end++
+			}
+			toks = append(toks, sqlToken{text: string(rs[i:end]), ident: true, pos: i, end: end})
+			i = end - 1
+		case unicode.IsSpace(r):
+		default:
+			toks = append(toks, sqlToken{text: string(r), pos: i, end: i + 1})
+		}
+	}
+	return toks
+}
@@ -0,0 +1,89 @@
+package spannerr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+func TestStatementTables(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT * FROM Users WHERE id = @id", []string{"Users"}},
+		{"SELECT u.a, o.b FROM Users u JOIN Orders AS o ON u.id = o.uid", []string{"Users", "Orders"}},
+		{"SELECT * FROM a, b AS x, `Order`", []string{"a", "b", "Order"}},
+		{"INSERT INTO Users (id) VALUES (1)", []string{"Users"}},
+		{"INSERT Users (id) SELECT id FROM Staging", []string{"Users", "Staging"}},
+		{"INSERT OR UPDATE INTO Users (id) VALUES (1)", []string{"Users"}},
+		{"UPDATE Users SET name = 'FROM x' WHERE id IN (SELECT id FROM Banned)", []string{"Users", "Banned"}},
+		{"DELETE FROM Users WHERE true", []string{"Users"}},
+		{"DELETE Users WHERE true", []string{"Users"}},
+		{"SELECT EXTRACT(DAY FROM ts) FROM Events@{FORCE_INDEX=ByTs} -- FROM Nope", []string{"Events"}},
+		{"SELECT * FROM UNNEST(@ids) AS id LEFT JOIN INFORMATION_SCHEMA.TABLES t ON true", []string{"INFORMATION_SCHEMA.TABLES"}},
+		{"SELECT * FROM (SELECT * FROM Inner1) x", []string{"Inner1"}},
+		{"SELECT * FROM (SELECT 1) x, Secrets", []string{"Secrets"}},
+		{"SELECT * FROM UNNEST(@ids) AS id, Secrets", []string{"Secrets"}},
+		{"SELECT * FROM ML.PREDICT(MODEL m, TABLE t), Secrets", []string{"Secrets"}},
+		{"SELECT 1", nil},
+
+		// WITH clause names are skipped only where they refer to their query
+		{"WITH t AS (SELECT * FROM Users), s AS (SELECT 1) SELECT * FROM t JOIN Accounts", []string{"Users", "Accounts"}},
+		{"WITH Secrets AS (SELECT * FROM Secrets) SELECT * FROM Secrets", []string{"Secrets"}},
+		{"WITH a AS (SELECT * FROM b), b AS (SELECT * FROM a) SELECT * FROM b", []string{"b"}},
+		{"WITH s AS (SELECT * FROM Secrets) SELECT * FROM s JOIN Secrets ON true", []string{"Secrets"}},
+		{"SELECT * FROM (WITH Secrets AS (SELECT 1) SELECT * FROM Secrets) x JOIN Secrets ON true", []string{"Secrets"}},
+		{"SELECT * FROM (WITH t AS (SELECT 1) SELECT * FROM t) x, t", []string{"t"}},
+		{"WITH RECURSIVE r AS (SELECT * FROM Nodes UNION ALL SELECT * FROM r) SELECT * FROM r", []string{"Nodes", "r"}},
+	}
+	for _, tt := range tests {
+		if got := StatementTables(tt.sql); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("StatementTables(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	var got []*AuthzRequest
+	c := NewClient("p", "i", "d")
+	c.Authorize = func(ctx context.Context, req *AuthzRequest) error {
+		got = append(got, req)
+		for _, table := range req.Tables {
+			if table == "Secrets" {
+				return errors.New("no access to Secrets")
+			}
+		}
+		return nil
+	}
+	ctx := WithIdentity(context.Background(), "alice")
+
+	if err := c.authorizeSQL(ctx, "SELECT * FROM Users"); err != nil {
+		t.Errorf("query on Users vetoed: %s", err)
+	}
+	err := c.authorizeSQL(ctx, "WITH Secrets AS (SELECT * FROM Secrets) SELECT * FROM Secrets")
+	if _, ok := errors.Cause(err).(*AuthzError); !ok {
+		t.Errorf("query shadowing Secrets returned %v, want an *AuthzError", err)
+	}
+	err = c.authorizeCommit(ctx, []*spanner.Mutation{{Delete: &spanner.Delete{Table: "Secrets"}}})
+	if _, ok := errors.Cause(err).(*AuthzError); !ok {
+		t.Errorf("commit to Secrets returned %v, want an *AuthzError", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("hook called %d times, want 3", len(got))
+	}
+	if got[0].Identity != "alice" || got[0].Op != OpQuery {
+		t.Errorf("first request is %+v, want a query by alice", got[0])
+	}
+	if got[2].Op != OpCommit {
+		t.Errorf("last request is a %s, want a commit", got[2].Op)
+	}
+
+	c.Authorize = func(ctx context.Context, req *AuthzRequest) error { panic("boom") }
+	if err := c.authorizeSQL(ctx, "SELECT * FROM Users"); err == nil {
+		t.Error("panicking hook did not veto the query")
+	}
+}
@@ -0,0 +1,150 @@
+package spannerr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// DefaultBlobChunkSize is the size of the chunks a BlobStore splits values into
+// when ChunkSize is not set. Chunks are sent base64 encoded, so they stay well
+// under Spanner's 10 MiB cell and request size limits.
+const DefaultBlobChunkSize = 1 << 20
+
+// blobBatch is the number of chunks a BlobStore writes or reads per call.
+const blobBatch = 8
+
+// BlobStore stores values too large for a single BYTES cell by splitting them
+// into chunks kept in a content-addressed table with the following schema:
+//
+//	CREATE TABLE BlobChunks (
+//		Hash STRING(64) NOT NULL,
+//		Data BYTES(MAX) NOT NULL,
+//	) PRIMARY KEY (Hash)
+//
+// Write returns the hashes of a value's chunks, which the application stores in
+// place of the value, such as in an ARRAY<STRING(64)> column, and passes to Read
+// to reassemble it. Identical chunks are stored once, so chunks may be shared by
+// many values and are never deleted by BlobStore.
+type BlobStore struct {
+	Client *Client
+	Table  string
+	// ChunkSize is the maximum size of a chunk in bytes. It defaults to
+	// DefaultBlobChunkSize.
+	ChunkSize int
+}
+
+type blobChunk struct {
+	Hash string `spanner:"Hash,pk"`
+	Data []byte `spanner:"Data"`
+}
+
+// Write stores the contents of r, returning the hashes of its chunks in order.
+// Chunks are read from r and committed a few at a time, so the value is never
+// held in memory in full. If ctx carries a transaction the chunks are buffered
+// in it instead, so they commit atomically with the row referencing them.
+func (b *BlobStore) Write(ctx context.Context, r io.Reader) ([]string, error) {
+	size := b.ChunkSize
+	if size <= 0 {
+		size = DefaultBlobChunkSize
+	}
+	var (
+		hashes []string
+		muts   []*spanner.Mutation
+		seen   = map[string]bool{}
+		buf    = make([]byte, size)
+	)
+	flush := func() error {
+		if len(muts) == 0 {
+			return nil
+		}
+		err := b.Client.Apply(ctx, muts...)
+		muts = nil
+		seen = map[string]bool{}
+		return errors.Wrap(err, "unable to write blob chunks")
+	}
+	for {
+		n, rerr := io.ReadFull(r, buf)
+		if n > 0 {
+			sum := sha256.Sum256(buf[:n])
+			hash := hex.EncodeToString(sum[:])
+			hashes = append(hashes, hash)
+			if !seen[hash] {
+				seen[hash] = true
+				m, err := InsertOrUpdateStruct(b.Table, &blobChunk{
+					Hash: hash,
+					Data: append([]byte(nil), buf[:n]...),
+				})
+				if err != nil {
+					return nil, err
+				}
+				if muts = append(muts, m); len(muts) == blobBatch {
+					if err := flush(); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			return nil, errors.Wrap(rerr, "unable to read blob")
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// Read reassembles the value with the given chunk hashes, as returned by Write,
+// writing it to w. Chunks are fetched a few at a time and verified against their
+// hashes.
+func (b *BlobStore) Read(ctx context.Context, w io.Writer, hashes []string) error {
+	sess, err := b.Client.AcquireSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer b.Client.ReleaseSession(ctx, *sess)
+	for len(hashes) > 0 {
+		n := len(hashes)
+		if n > blobBatch {
+			n = blobBatch
+		}
+		keys := make([]Key, n)
+		for i, h := range hashes[:n] {
+			keys[i] = Key{h}
+		}
+		res, err := sess.Read(ctx, b.Table, keySet(keys...), []string{"Hash", "Data"}, nil)
+		if err != nil {
+			return errors.Wrap(err, "unable to read blob chunks")
+		}
+		var chunks []blobChunk
+		if err := decodeRows(res, &chunks); err != nil {
+			return errors.Wrap(err, "unable to decode blob chunks")
+		}
+		byHash := make(map[string][]byte, len(chunks))
+		for _, c := range chunks {
+			byHash[c.Hash] = c.Data
+		}
+		for _, h := range hashes[:n] {
+			data, ok := byHash[h]
+			if !ok {
+				return errors.Errorf("blob chunk %s not found", h)
+			}
+			if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != h {
+				return errors.Errorf("blob chunk %s is corrupt", h)
+			}
+			if _, err := w.Write(data); err != nil {
+				return errors.Wrap(err, "unable to write blob")
+			}
+		}
+		hashes = hashes[n:]
+	}
+	return nil
+}
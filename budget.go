@@ -0,0 +1,72 @@
+package spannerr
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// QueryBudget limits the Spanner work done with a context, such as within a
+// single request.
+type QueryBudget struct {
+	// MaxStatements is the maximum number of queries, DML statements and reads.
+	// Zero means no limit.
+	MaxStatements int64
+	// MaxTime is the maximum total time spent waiting on Spanner. Zero means no
+	// limit.
+	MaxTime time.Duration
+}
+
+// QueryBudgetError is returned once a context's QueryBudget is spent. It usually
+// means a handler is issuing a query per item of a list rather than batching.
+type QueryBudgetError struct {
+	Budget QueryBudget
+	// Statements is the number of statements run so far.
+	Statements int64
+	// Elapsed is the time spent waiting on Spanner so far.
+	Elapsed time.Duration
+}
+
+func (e *QueryBudgetError) Error() string {
+	return fmt.Sprintf("query budget exhausted: %d statements in %s (max %d statements, %s)",
+		e.Statements, e.Elapsed.Round(time.Millisecond), e.Budget.MaxStatements, e.Budget.MaxTime)
+}
+
+type queryBudgetKey struct{}
+
+type queryBudget struct {
+	QueryBudget
+	statements int64
+	elapsed    int64 // nanoseconds
+}
+
+// WithQueryBudget returns a copy of ctx with the given budget. Every statement
+// and read made with the returned context, or contexts derived from it, counts
+// against the budget, and once it is spent further calls fail immediately with a
+// *QueryBudgetError.
+func WithQueryBudget(ctx context.Context, b QueryBudget) context.Context {
+	return context.WithValue(ctx, queryBudgetKey{}, &queryBudget{QueryBudget: b})
+}
+
+// spendStatement counts a statement against the budget of ctx, returning a
+// *QueryBudgetError if the budget is already spent.
+func spendStatement(ctx context.Context) error {
+	b, ok := ctx.Value(queryBudgetKey{}).(*queryBudget)
+	if !ok {
+		return nil
+	}
+	n := atomic.AddInt64(&b.statements, 1)
+	elapsed := time.Duration(atomic.LoadInt64(&b.elapsed))
+	if (b.MaxStatements > 0 && n > b.MaxStatements) || (b.MaxTime > 0 && elapsed >= b.MaxTime) {
+		return &QueryBudgetError{Budget: b.QueryBudget, Statements: n - 1, Elapsed: elapsed}
+	}
+	return nil
+}
+
+// spendTime adds the time since start to the budget of ctx.
+func spendTime(ctx context.Context, start time.Time) {
+	if b, ok := ctx.Value(queryBudgetKey{}).(*queryBudget); ok {
+		atomic.AddInt64(&b.elapsed, int64(time.Since(start)))
+	}
+}
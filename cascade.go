@@ -0,0 +1,309 @@
+package spannerr
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// CascadeDelete is the set of operations required to delete a row along with the
+// rows that depend on it.
+type CascadeDelete struct {
+	// Statements are DML statements deleting rows in other tables that reference
+	// the row or its interleaved descendants via foreign keys without ON DELETE
+	// CASCADE, and the rows depending on those in turn, dependents first. They
+	// must be executed, in order, inside the read-write transaction used to
+	// commit Mutations.
+	Statements []*spanner.Statement
+	// Mutations delete rows in interleaved child tables without ON DELETE CASCADE,
+	// deepest tables first, followed by the row itself.
+	Mutations []*spanner.Mutation
+}
+
+// PlanCascadeDelete uses schema introspection to build the operations that delete
+// the row of table with the given key along with its interleaved descendants and
+// any rows referencing them through foreign keys, and, in turn, the rows depending
+// on those. Relationships Spanner already cascades on its own are skipped, but the
+// rows they remove are still followed. Dependencies forming a cycle, such as a
+// table referencing itself, are reported as an error rather than planned partially.
+func (s *Session) PlanCascadeDelete(ctx context.Context, table string, key Key) (*CascadeDelete, error) {
+	pk, err := s.PrimaryKey(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(pk) != len(key) {
+		return nil, errors.Errorf("table %q has %d key columns, got %d key values", table, len(pk), len(key))
+	}
+	p := &cascadePlanner{s: s, key: key, plan: &CascadeDelete{}}
+	if err := p.planReferencing(ctx, keyedRows(table, pk, key), []string{table}); err != nil {
+		return nil, err
+	}
+	if err := p.planChildDeletes(ctx, table, []string{table}); err != nil {
+		return nil, err
+	}
+	p.plan.Mutations = append(p.plan.Mutations, DeleteKeys(table, key))
+	return p.plan, nil
+}
+
+// cascadePlanner builds a CascadeDelete for the row with key.
+type cascadePlanner struct {
+	s    *Session
+	key  Key
+	plan *CascadeDelete
+}
+
+// rowSet is a set of rows of table being deleted, those matching the condition
+// where returns for an alias of table. Conditions only use the params of the
+// planner's key, named k0, k1 and so on.
+type rowSet struct {
+	table string
+	pk    []string
+	// key is set if the set holds the rows whose primary key begins with key.
+	key   Key
+	where func(alias string) (string, error)
+}
+
+// keyedRows returns the rows of table whose primary key pk begins with key.
+func keyedRows(table string, pk []string, key Key) *rowSet {
+	return &rowSet{table: table, pk: pk, key: key, where: func(alias string) (string, error) {
+		conds := make([]string, len(key))
+		for i := range key {
+			qk, err := quoteIdent(pk[i])
+			if err != nil {
+				return "", err
+			}
+			conds[i] = alias + "." + qk + " = @k" + strconv.Itoa(i)
+		}
+		return strings.Join(conds, " AND "), nil
+	}}
+}
+
+// referencingRows returns the rows of fk.Table, whose primary key is pk, that
+// reference rows through fk. When rows is a single row and fk references its key
+// columns, the referencing columns are compared with the key directly; otherwise
+// the referenced rows are looked up in a subquery.
+func referencingRows(rows *rowSet, fk *ForeignKey, pk []string) *rowSet {
+	return &rowSet{table: fk.Table, pk: pk, where: func(alias string) (string, error) {
+		var (
+			conds  []string
+			direct = rows.key != nil && len(rows.key) == len(rows.pk)
+		)
+		for i, col := range fk.Columns {
+			pos := -1
+			for j, k := range rows.pk {
+				if k == fk.ReferencedColumns[i] {
+					pos = j
+				}
+			}
+			if pos < 0 || !direct {
+				direct = false
+				break
+			}
+			qc, err := quoteIdent(col)
+			if err != nil {
+				return "", err
+			}
+			conds = append(conds, alias+"."+qc+" = @k"+strconv.Itoa(pos))
+		}
+		if direct {
+			return strings.Join(conds, " AND "), nil
+		}
+
+		inner := alias + "r"
+		cond, err := rows.where(inner)
+		if err != nil {
+			return "", err
+		}
+		conds = []string{cond}
+		for i, col := range fk.Columns {
+			qc, err := quoteIdent(col)
+			if err != nil {
+				return "", err
+			}
+			qrc, err := quoteIdent(fk.ReferencedColumns[i])
+			if err != nil {
+				return "", err
+			}
+			conds = append(conds, inner+"."+qrc+" = "+alias+"."+qc)
+		}
+		return existsIn(rows.table, inner, conds)
+	}}
+}
+
+// childRows returns the rows of the interleaved child table, whose primary key is
+// pk, whose parent rows are in rows.
+func childRows(rows *rowSet, child string, pk []string) *rowSet {
+	if rows.key != nil {
+		return keyedRows(child, pk, rows.key)
+	}
+	return &rowSet{table: child, pk: pk, where: func(alias string) (string, error) {
+		inner := alias + "p"
+		cond, err := rows.where(inner)
+		if err != nil {
+			return "", err
+		}
+		conds := []string{cond}
+		for _, k := range rows.pk {
+			qk, err := quoteIdent(k)
+			if err != nil {
+				return "", err
+			}
+			conds = append(conds, inner+"."+qk+" = "+alias+"."+qk)
+		}
+		return existsIn(rows.table, inner, conds)
+	}}
+}
+
+// existsIn returns an EXISTS subquery over table, aliased as alias, with conds.
+func existsIn(table, alias string, conds []string) (string, error) {
+	qt, err := quoteIdent(table)
+	if err != nil {
+		return "", err
+	}
+	return "EXISTS (SELECT 1 FROM " + qt + " AS " + alias + " WHERE " + strings.Join(conds, " AND ") + ")", nil
+}
+
+// planChildDeletes appends prefix range deletes for the interleaved descendants of
+// table, deepest first, and plans the deletes of rows depending on them. path
+// holds the tables whose rows are being deleted by the enclosing calls.
+func (p *cascadePlanner) planChildDeletes(ctx context.Context, table string, path []string) error {
+	children, err := p.s.InterleavedChildren(ctx, table)
+	if err != nil {
+		return err
+	}
+	prefix := keySet(p.key).Keys[0]
+	for _, c := range children {
+		pk, err := p.s.PrimaryKey(ctx, c.Name)
+		if err != nil {
+			return err
+		}
+		if len(pk) < len(p.key) {
+			return errors.Errorf("table %q has %d key columns, got %d key values", c.Name, len(pk), len(p.key))
+		}
+		cpath := append(path[:len(path):len(path)], c.Name)
+		if err := p.planReferencing(ctx, keyedRows(c.Name, pk, p.key), cpath); err != nil {
+			return err
+		}
+		// descendants of a CASCADE child may not cascade themselves
+		if err := p.planChildDeletes(ctx, c.Name, cpath); err != nil {
+			return err
+		}
+		if c.OnDelete == "CASCADE" {
+			continue
+		}
+		// a closed range with identical start and end matches every key
+		// beginning with the parent's key
+		p.plan.Mutations = append(p.plan.Mutations, &spanner.Mutation{Delete: &spanner.Delete{
+			Table: c.Name,
+			KeySet: &spanner.KeySet{Ranges: []*spanner.KeyRange{
+				{StartClosed: prefix, EndClosed: prefix},
+			}},
+		}})
+	}
+	return nil
+}
+
+// planReferencing plans the deletes of the rows referencing rows through foreign
+// keys, along with the rows depending on them in turn. Rows removed by a foreign
+// key with ON DELETE CASCADE are not deleted but their dependents are.
+func (p *cascadePlanner) planReferencing(ctx context.Context, rows *rowSet, path []string) error {
+	fks, err := p.s.ReferencingForeignKeys(ctx, rows.table)
+	if err != nil {
+		return err
+	}
+	for _, fk := range fks {
+		pk, err := p.s.PrimaryKey(ctx, fk.Table)
+		if err != nil {
+			return err
+		}
+		if err := p.planRows(ctx, referencingRows(rows, fk, pk), path, fk.OnDelete != "CASCADE"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planRows appends DML deleting rows, if del is set, after planning the deletes
+// of the rows referencing them and of their interleaved children.
+func (p *cascadePlanner) planRows(ctx context.Context, rows *rowSet, path []string, del bool) error {
+	for _, t := range path {
+		if t == rows.table {
+			return errors.Errorf("foreign keys and interleaving lead back to table %q; "+
+				"unable to plan a cascade delete of cyclic dependencies", rows.table)
+		}
+	}
+	path = append(path[:len(path):len(path)], rows.table)
+	if err := p.planReferencing(ctx, rows, path); err != nil {
+		return err
+	}
+	children, err := p.s.InterleavedChildren(ctx, rows.table)
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		pk, err := p.s.PrimaryKey(ctx, c.Name)
+		if err != nil {
+			return err
+		}
+		if err := p.planRows(ctx, childRows(rows, c.Name, pk), path, c.OnDelete != "CASCADE"); err != nil {
+			return err
+		}
+	}
+	if !del {
+		return nil
+	}
+
+	qt, err := quoteIdent(rows.table)
+	if err != nil {
+		return err
+	}
+	cond, err := rows.where("t")
+	if err != nil {
+		return err
+	}
+	sql := "DELETE FROM " + qt + " AS t WHERE " + cond
+	var params []*Param
+	for i, v := range p.key {
+		name := "k" + strconv.Itoa(i)
+		if usesParam(sql, name) {
+			params = append(params, valueParam(name, v))
+		}
+	}
+	pTypes, pJSON, err := p.s.client.encodeParams(params)
+	if err != nil {
+		return err
+	}
+	p.plan.Statements = append(p.plan.Statements, &spanner.Statement{
+		Sql:        sql,
+		Params:     pJSON,
+		ParamTypes: pTypes,
+	})
+	return nil
+}
+
+// usesParam reports whether sql refers to the param called name.
+func usesParam(sql, name string) bool {
+	for i := 0; ; {
+		j := strings.Index(sql[i:], "@"+name)
+		if j < 0 {
+			return false
+		}
+		i += j + len(name) + 1
+		if i == len(sql) || !isIdentRune(rune(sql[i])) {
+			return true
+		}
+	}
+}
+
+// Apply executes the plan within the given read-write transaction and commits it.
+func (p *CascadeDelete) Apply(ctx context.Context, s *Session, txID string) (*spanner.CommitResponse, error) {
+	if len(p.Statements) > 0 {
+		if _, err := s.ExecuteBatchDML(ctx, p.Statements, txID); err != nil {
+			return nil, err
+		}
+	}
+	return s.Commit(ctx, p.Mutations, nil, txID)
+}
@@ -0,0 +1,68 @@
+package spannerr
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Catalog is a machine-readable description of the statements in a Registry, for
+// generating documentation or client code in other languages that use the same
+// database. It encodes to JSON.
+type Catalog struct {
+	Statements []*CatalogStatement `json:"statements"`
+}
+
+// CatalogStatement describes a registered statement.
+type CatalogStatement struct {
+	Name string `json:"name"`
+	SQL  string `json:"sql"`
+	// DML is set for statements that modify data and must run in a
+	// read-write transaction.
+	DML    bool            `json:"dml"`
+	Params []*CatalogField `json:"params"`
+	// Result lists the columns returned by the statement, which for DML are
+	// those of a THEN RETURN clause.
+	Result []*CatalogField `json:"result"`
+}
+
+// CatalogField is a named value and its type in SQL syntax, such as
+// "ARRAY<STRING>".
+type CatalogField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Catalog plans every registered statement against the database to learn its
+// result schema and returns the catalog of all statements, sorted by name.
+func (r *Registry) Catalog(ctx context.Context, s *Session) (*Catalog, error) {
+	cat := &Catalog{}
+	for _, name := range r.Names() {
+		st, _ := r.Lookup(name)
+		res, err := st.plan(ctx, s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to plan statement %q", name)
+		}
+		cs := &CatalogStatement{
+			Name:   name,
+			SQL:    st.SQL,
+			DML:    isDML(st.SQL),
+			Params: []*CatalogField{},
+			Result: []*CatalogField{},
+		}
+		for pname, typ := range st.Params {
+			code, elem, _ := parseParamType(typ)
+			if elem != "" {
+				code += "<" + elem + ">"
+			}
+			cs.Params = append(cs.Params, &CatalogField{Name: pname, Type: code})
+		}
+		sort.Slice(cs.Params, func(i, j int) bool { return cs.Params[i].Name < cs.Params[j].Name })
+		for _, col := range Columns(res.Metadata) {
+			cs.Result = append(cs.Result, &CatalogField{Name: col.Name, Type: col.String()})
+		}
+		cat.Statements = append(cat.Statements, cs)
+	}
+	return cat, nil
+}
@@ -0,0 +1,123 @@
+package spannerr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+// DedupeAttribute is the Pub/Sub message attribute holding an ID that is unique to
+// each change published by a ChangeRelay. A change may be published more than
+// once, for example when a relay is restarted, so subscribers should use it to
+// discard duplicates.
+const DedupeAttribute = "spannerr_change_id"
+
+// maxPublishBatch is the maximum number of messages in a single Publish call.
+const maxPublishBatch = 1000
+
+// ChangeRelay publishes the records of a ChangeStream to a Pub/Sub topic. Each
+// modified row is published as its own message containing a ChangeMessage, with an
+// ordering key derived from its table and primary key so subscribers with message
+// ordering enabled see the changes to a row in commit order.
+type ChangeRelay struct {
+	Stream *ChangeStream
+	// Topic is the full name of the topic, e.g. "projects/my-project/topics/changes".
+	Topic string
+}
+
+// ChangeMessage is the JSON payload of a message published by a ChangeRelay.
+type ChangeMessage struct {
+	Table               string          `json:"table"`
+	ModType             string          `json:"mod_type"`
+	CommitTimestamp     time.Time       `json:"commit_timestamp"`
+	ServerTransactionID string          `json:"server_transaction_id"`
+	RecordSequence      string          `json:"record_sequence"`
+	TransactionTag      string          `json:"transaction_tag,omitempty"`
+	Keys                json.RawMessage `json:"keys"`
+	NewValues           json.RawMessage `json:"new_values,omitempty"`
+	OldValues           json.RawMessage `json:"old_values,omitempty"`
+}
+
+// Run relays the changes committed from start until end, or indefinitely if end
+// is zero. Delivery is at least once; see DedupeAttribute.
+func (r *ChangeRelay) Run(ctx context.Context, start, end time.Time) error {
+	ps, err := pubsub.New(r.Stream.Client.httpClient(pubsub.PubsubScope))
+	if err != nil {
+		return errors.Wrap(err, "unable to init pubsub service")
+	}
+	return r.Stream.Read(ctx, start, end, func(ctx context.Context, rec *DataChangeRecord) error {
+		return r.publish(ctx, ps, rec)
+	})
+}
+
+// publish publishes every mod in rec, in order.
+func (r *ChangeRelay) publish(ctx context.Context, ps *pubsub.Service, rec *DataChangeRecord) error {
+	msgs := make([]*pubsub.PubsubMessage, 0, len(rec.Mods))
+	for i, mod := range rec.Mods {
+		msg, err := changeMessage(rec, i, mod)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+	}
+	for len(msgs) > 0 {
+		n := len(msgs)
+		if n > maxPublishBatch {
+			n = maxPublishBatch
+		}
+		_, err := ps.Projects.Topics.Publish(r.Topic, &pubsub.PublishRequest{
+			Messages: msgs[:n],
+		}).Context(ctx).Do()
+		if err != nil {
+			return errors.Wrap(err, "unable to publish change")
+		}
+		msgs = msgs[n:]
+	}
+	return nil
+}
+
+func changeMessage(rec *DataChangeRecord, i int, mod Mod) (*pubsub.PubsubMessage, error) {
+	raw := func(s string) json.RawMessage {
+		if s == "" {
+			return nil
+		}
+		return json.RawMessage(s)
+	}
+	data, err := json.Marshal(&ChangeMessage{
+		Table:               rec.TableName,
+		ModType:             rec.ModType,
+		CommitTimestamp:     rec.CommitTimestamp,
+		ServerTransactionID: rec.ServerTransactionID,
+		RecordSequence:      rec.RecordSequence,
+		TransactionTag:      rec.TransactionTag,
+		Keys:                raw(mod.Keys),
+		NewValues:           raw(mod.NewValues),
+		OldValues:           raw(mod.OldValues),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encode change")
+	}
+	key := rec.TableName + ":" + mod.Keys
+	if len(key) > 1024 {
+		// ordering keys are limited to 1KB
+		sum := sha256.Sum256([]byte(mod.Keys))
+		key = rec.TableName + ":" + hex.EncodeToString(sum[:])
+	}
+	return &pubsub.PubsubMessage{
+		Data:        base64.StdEncoding.EncodeToString(data),
+		OrderingKey: key,
+		Attributes: map[string]string{
+			DedupeAttribute:    rec.ServerTransactionID + "/" + rec.RecordSequence + "/" + strconv.Itoa(i),
+			"table":            rec.TableName,
+			"mod_type":         rec.ModType,
+			"commit_timestamp": rec.CommitTimestamp.UTC().Format(time.RFC3339Nano),
+		},
+	}, nil
+}
@@ -0,0 +1,233 @@
+package spannerr
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+type (
+	// DataChangeRecord is a set of changes to a table made by a single transaction
+	// within a change stream partition.
+	// More details can be found here: https://cloud.google.com/spanner/docs/change-streams/details#data-change-records
+	DataChangeRecord struct {
+		CommitTimestamp                      time.Time    `spanner:"commit_timestamp" json:"commit_timestamp"`
+		RecordSequence                       string       `spanner:"record_sequence" json:"record_sequence"`
+		ServerTransactionID                  string       `spanner:"server_transaction_id" json:"server_transaction_id"`
+		IsLastRecordInTransactionInPartition bool         `spanner:"is_last_record_in_transaction_in_partition" json:"is_last_record_in_transaction_in_partition"`
+		TableName                            string       `spanner:"table_name" json:"table_name"`
+		ColumnTypes                          []ColumnType `spanner:"column_types" json:"column_types"`
+		Mods                                 []Mod        `spanner:"mods" json:"mods"`
+		ModType                              string       `spanner:"mod_type" json:"mod_type"`
+		ValueCaptureType                     string       `spanner:"value_capture_type" json:"value_capture_type"`
+		NumberOfRecordsInTransaction         int64        `spanner:"number_of_records_in_transaction" json:"number_of_records_in_transaction"`
+		NumberOfPartitionsInTransaction      int64        `spanner:"number_of_partitions_in_transaction" json:"number_of_partitions_in_transaction"`
+		TransactionTag                       string       `spanner:"transaction_tag" json:"transaction_tag"`
+		IsSystemTransaction                  bool         `spanner:"is_system_transaction" json:"is_system_transaction"`
+	}
+
+	// ColumnType describes a column modified in a DataChangeRecord.
+	ColumnType struct {
+		Name string `spanner:"name" json:"name"`
+		// Type is the JSON encoded Spanner type of the column.
+		Type            string `spanner:"type" json:"type"`
+		IsPrimaryKey    bool   `spanner:"is_primary_key" json:"is_primary_key"`
+		OrdinalPosition int64  `spanner:"ordinal_position" json:"ordinal_position"`
+	}
+
+	// Mod is a change to a single row. Keys, NewValues and OldValues are JSON
+	// objects keyed by column name.
+	Mod struct {
+		Keys      string `spanner:"keys" json:"keys"`
+		NewValues string `spanner:"new_values" json:"new_values,omitempty"`
+		OldValues string `spanner:"old_values" json:"old_values,omitempty"`
+	}
+
+	heartbeatRecord struct {
+		Timestamp time.Time `spanner:"timestamp"`
+	}
+
+	childPartitionsRecord struct {
+		StartTimestamp  time.Time        `spanner:"start_timestamp"`
+		RecordSequence  string           `spanner:"record_sequence"`
+		ChildPartitions []childPartition `spanner:"child_partitions"`
+	}
+
+	childPartition struct {
+		Token                 string   `spanner:"token"`
+		ParentPartitionTokens []string `spanner:"parent_partition_tokens"`
+	}
+
+	changeRecord struct {
+		DataChangeRecord      []DataChangeRecord      `spanner:"data_change_record"`
+		HeartbeatRecord       []heartbeatRecord       `spanner:"heartbeat_record"`
+		ChildPartitionsRecord []childPartitionsRecord `spanner:"child_partitions_record"`
+	}
+)
+
+// ChangeStream consumes a Spanner change stream, following partition splits and
+// merges so every data change record between two timestamps is delivered once.
+type ChangeStream struct {
+	Client *Client
+	// Name is the name of the change stream.
+	Name string
+	// Heartbeat is the heartbeat interval requested from Spanner, bounding how long
+	// a quiet partition read goes without a response. It defaults to 10 seconds.
+	Heartbeat time.Duration
+	// MaxPartitions is the maximum number of partitions read at once, each
+	// holding a pooled session. Further partitions wait for one to end. It
+	// defaults to half the Client's pool size, so reading without an end
+	// requires a pool large enough for the stream's partitions.
+	MaxPartitions int
+}
+
+func (cs *ChangeStream) maxPartitions() int {
+	if cs.MaxPartitions > 0 {
+		return cs.MaxPartitions
+	}
+	if n := cs.Client.maxSessions / 2; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// Read reads the records committed from start until end, or indefinitely if end
+// is zero, passing each data change record to fn. Partitions are read
+// concurrently, up to MaxPartitions at once, so fn must be safe for concurrent
+// use. Records within a partition are delivered in commit order, and a partition
+// is only read once all of its parents have ended, so the changes to a key are
+// delivered in commit order too. Read returns when every partition has ended,
+// ctx is done or fn returns an error.
+func (cs *ChangeStream) Read(ctx context.Context, start, end time.Time, fn func(context.Context, *DataChangeRecord) error) error {
+	if !identRE.MatchString(cs.Name) {
+		return errors.Errorf("invalid change stream name %q", cs.Name)
+	}
+	if err := cs.Client.requireFeatures(ctx, FeatureGoogleSQL, FeatureChangeStreams); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		slots    = make(chan struct{}, cs.maxPartitions())
+		mu       sync.Mutex
+		ended    = map[string]bool{}
+		started  = map[string]bool{}
+		errOnce  sync.Once
+		firstErr error
+		read     func(token string, start time.Time)
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	read = func(token string, start time.Time) {
+		defer wg.Done()
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			fail(ctx.Err())
+			return
+		}
+		children, err := cs.readPartition(ctx, token, start, end, fn)
+		<-slots
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		ended[token] = true
+		// merged partitions are reported by every parent and may only be read
+		// once the last of them has ended
+		ready := func(c childPartition) bool {
+			if started[c.Token] {
+				return false
+			}
+			for _, parent := range c.ParentPartitionTokens {
+				if !ended[parent] {
+					return false
+				}
+			}
+			return true
+		}
+		for _, child := range children {
+			for _, c := range child.ChildPartitions {
+				if !ready(c) {
+					continue
+				}
+				started[c.Token] = true
+				wg.Add(1)
+				go read(c.Token, child.StartTimestamp)
+			}
+		}
+	}
+	wg.Add(1)
+	read("", start)
+	wg.Wait()
+	return firstErr
+}
+
+// readPartition reads a single partition, or the initial query if token is empty,
+// returning its child partitions once it ends.
+func (cs *ChangeStream) readPartition(ctx context.Context, token string, start, end time.Time, fn func(context.Context, *DataChangeRecord) error) ([]childPartitionsRecord, error) {
+	heartbeat := cs.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = 10 * time.Second
+	}
+	params := []*Param{
+		{Name: "start", Value: encodeValue(start), Type: TypeTimestamp},
+		{Name: "end", Type: TypeTimestamp},
+		{Name: "token", Type: TypeString},
+		{Name: "heartbeat", Value: strconv.FormatInt(int64(heartbeat/time.Millisecond), 10), Type: TypeInt64},
+	}
+	if !end.IsZero() {
+		params[1].Value = encodeValue(end)
+	}
+	if token != "" {
+		params[2].Value = token
+	}
+	sql := "SELECT ChangeRecord FROM READ_" + cs.Name + "(start_timestamp => @start, " +
+		"end_timestamp => @end, partition_token => @token, heartbeat_milliseconds => @heartbeat)"
+
+	sess, err := cs.Client.AcquireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cs.Client.ReleaseSession(ctx, *sess)
+	it, err := sess.ExecuteStreamingSQL(ctx, params, sql, QueryModeNormal, &spanner.TransactionSelector{
+		SingleUse: &spanner.TransactionOptions{ReadOnly: &spanner.ReadOnly{Strong: true}},
+	}, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read change stream")
+	}
+	defer it.Close()
+
+	var children []childPartitionsRecord
+	for it.Next() {
+		var recs []changeRecord
+		if err := decodeValue(it.Row()[0], it.Metadata().RowType.Fields[0].Type,
+			reflect.ValueOf(&recs).Elem()); err != nil {
+			return nil, errors.Wrap(err, "unable to decode change record")
+		}
+		for _, rec := range recs {
+			for i := range rec.DataChangeRecord {
+				dcr := &rec.DataChangeRecord[i]
+				if err := protect(func() error { return fn(ctx, dcr) }); err != nil {
+					return nil, err
+				}
+			}
+			children = append(children, rec.ChildPartitionsRecord...)
+		}
+	}
+	return children, errors.Wrap(it.Err(), "unable to read change stream")
+}
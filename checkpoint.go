@@ -0,0 +1,326 @@
+package spannerr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+	"google.golang.org/appengine/memcache"
+)
+
+// CheckpointStore records the progress of a ResumableBatch so a crashed job can
+// pick up where it left off.
+type CheckpointStore interface {
+	// Load returns the batch saved for job, or nil if there is none.
+	Load(ctx context.Context, job string) (*BatchQuery, error)
+	// Save records the batch for job.
+	Save(ctx context.Context, job string, q *BatchQuery) error
+	// Completed reports which of the given partition tokens have been completed.
+	Completed(ctx context.Context, job string, tokens []string) (map[string]bool, error)
+	// Complete records the partition token as completed.
+	Complete(ctx context.Context, job, token string) error
+	// Clear removes all checkpoints for job.
+	Clear(ctx context.Context, job string) error
+}
+
+// ResumableBatch runs a partitioned query, checkpointing each completed partition
+// so that a job that crashes part way through can be run again and only execute
+// the remaining partitions. Resuming reads the same snapshot, so it is only
+// possible while the batch session is alive and the read timestamp is within
+// the database's version retention period (one hour by default). Once the saved
+// batch has expired, its checkpoints are dropped and the query is partitioned
+// again, so every partition is executed again against a new snapshot.
+type ResumableBatch struct {
+	Client *Client
+	Store  CheckpointStore
+	// Job uniquely identifies the batch within the Store.
+	Job string
+
+	SQL     string
+	Params  []*Param
+	Options *spanner.PartitionOptions
+}
+
+// Run executes every partition of the batch that has not yet been completed,
+// passing its rows to fn and marking it complete once fn returns nil. When all
+// partitions are complete the batch is closed and its checkpoints cleared.
+func (b *ResumableBatch) Run(ctx context.Context, fn func(ctx context.Context, token string, it *RowIterator) error) error {
+	q, resumed, err := b.batch(ctx)
+	if err != nil {
+		return err
+	}
+	err = b.run(ctx, q, fn)
+	if !resumed || !batchExpired(err) {
+		return err
+	}
+	b.Client.logf(ctx, "batch %q expired, partitioning it again: %s", b.Job, err)
+	if err := b.Store.Clear(ctx, b.Job); err != nil {
+		return errors.Wrap(err, "unable to clear expired checkpoints")
+	}
+	if q, _, err = b.batch(ctx); err != nil {
+		return err
+	}
+	return b.run(ctx, q, fn)
+}
+
+// run executes the partitions of q that have not yet been completed.
+func (b *ResumableBatch) run(ctx context.Context, q *BatchQuery, fn func(context.Context, string, *RowIterator) error) error {
+	done, err := b.Store.Completed(ctx, b.Job, q.Tokens)
+	if err != nil {
+		return errors.Wrap(err, "unable to load completed partitions")
+	}
+	for _, token := range q.Tokens {
+		if done[token] {
+			continue
+		}
+		if err := b.runPartition(ctx, q, token, fn); err != nil {
+			return err
+		}
+		if err := b.Store.Complete(ctx, b.Job, token); err != nil {
+			return errors.Wrap(err, "unable to checkpoint partition")
+		}
+	}
+	if err := b.Client.CloseBatch(ctx, q); err != nil {
+		b.Client.logf(ctx, "unable to close batch %q: %s", b.Job, err)
+	}
+	return errors.Wrap(b.Store.Clear(ctx, b.Job), "unable to clear checkpoints")
+}
+
+// batchExpired reports whether err means a saved batch can no longer be read:
+// its session or transaction is gone, or its snapshot is older than the version
+// retention period.
+func batchExpired(err error) bool {
+	switch ErrorCode(err) {
+	case "NOT_FOUND":
+		return true
+	case "FAILED_PRECONDITION":
+		return strings.Contains(strings.ToLower(err.Error()), "too old")
+	}
+	return false
+}
+
+// batch loads the saved batch, reporting that it was resumed, or partitions the
+// query and saves a new one.
+func (b *ResumableBatch) batch(ctx context.Context) (*BatchQuery, bool, error) {
+	q, err := b.Store.Load(ctx, b.Job)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "unable to load checkpoint")
+	}
+	if q != nil {
+		return q, true, nil
+	}
+	q, err = b.Client.PartitionQuery(ctx, b.SQL, b.Params, b.Options)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := b.Store.Save(ctx, b.Job, q); err != nil {
+		b.Client.CloseBatch(ctx, q)
+		return nil, false, errors.Wrap(err, "unable to save checkpoint")
+	}
+	return q, false, nil
+}
+
+func (b *ResumableBatch) runPartition(ctx context.Context, q *BatchQuery, token string, fn func(context.Context, string, *RowIterator) error) error {
+	it, err := b.Client.ExecutePartition(ctx, q, token, nil)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	if err := protect(func() error { return fn(ctx, token, it) }); err != nil {
+		return err
+	}
+	return it.Err()
+}
+
+// partitionKey returns a fixed length key for a partition token.
+func partitionKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TableCheckpointStore is a CheckpointStore backed by a Spanner table with the
+// following schema:
+//
+//	CREATE TABLE BatchCheckpoints (
+//		Job       STRING(MAX) NOT NULL,
+//		Partition STRING(64) NOT NULL,
+//		Batch     STRING(MAX),
+//	) PRIMARY KEY (Job, Partition)
+//
+// The batch itself is stored in the row with an empty Partition.
+type TableCheckpointStore struct {
+	Client *Client
+	Table  string
+}
+
+type checkpointRow struct {
+	Job       string  `spanner:"Job,pk"`
+	Partition string  `spanner:"Partition,pk"`
+	Batch     *string `spanner:"Batch"`
+}
+
+// Load implements CheckpointStore.
+func (t *TableCheckpointStore) Load(ctx context.Context, job string) (*BatchQuery, error) {
+	var rows []checkpointRow
+	err := t.read(ctx, keySet(Key{job, ""}), &rows)
+	if err != nil || len(rows) == 0 || rows[0].Batch == nil {
+		return nil, err
+	}
+	var q BatchQuery
+	err = json.Unmarshal([]byte(*rows[0].Batch), &q)
+	return &q, errors.Wrap(err, "unable to decode batch")
+}
+
+// Save implements CheckpointStore.
+func (t *TableCheckpointStore) Save(ctx context.Context, job string, q *BatchQuery) error {
+	b, err := json.Marshal(q)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode batch")
+	}
+	batch := string(b)
+	m, err := InsertOrUpdateStruct(t.Table, &checkpointRow{Job: job, Batch: &batch})
+	if err != nil {
+		return err
+	}
+	return t.Client.Apply(ctx, m)
+}
+
+// Completed implements CheckpointStore.
+func (t *TableCheckpointStore) Completed(ctx context.Context, job string, tokens []string) (map[string]bool, error) {
+	var rows []checkpointRow
+	err := t.read(ctx, &spanner.KeySet{Ranges: []*spanner.KeyRange{{
+		StartClosed: []interface{}{job},
+		EndClosed:   []interface{}{job},
+	}}}, &rows)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for _, r := range rows {
+		keys[r.Partition] = true
+	}
+	done := map[string]bool{}
+	for _, token := range tokens {
+		if keys[partitionKey(token)] {
+			done[token] = true
+		}
+	}
+	return done, nil
+}
+
+// Complete implements CheckpointStore.
+func (t *TableCheckpointStore) Complete(ctx context.Context, job, token string) error {
+	m, err := InsertOrUpdateStruct(t.Table, &checkpointRow{Job: job, Partition: partitionKey(token)})
+	if err != nil {
+		return err
+	}
+	return t.Client.Apply(ctx, m)
+}
+
+// Clear implements CheckpointStore.
+func (t *TableCheckpointStore) Clear(ctx context.Context, job string) error {
+	return t.Client.Apply(ctx, &spanner.Mutation{Delete: &spanner.Delete{
+		Table: t.Table,
+		KeySet: &spanner.KeySet{Ranges: []*spanner.KeyRange{{
+			StartClosed: []interface{}{job},
+			EndClosed:   []interface{}{job},
+		}}},
+	}})
+}
+
+func (t *TableCheckpointStore) read(ctx context.Context, keys *spanner.KeySet, dst interface{}) error {
+	sess, err := t.Client.AcquireSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer t.Client.ReleaseSession(ctx, *sess)
+	res, err := sess.Read(ctx, t.Table, keys, []string{"Job", "Partition", "Batch"}, nil)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(decodeRows(res, dst), "unable to decode checkpoints")
+}
+
+// MemcacheCheckpointStore is a CheckpointStore backed by App Engine memcache. It
+// is cheaper than a table but best effort: an evicted checkpoint causes its
+// partition, or the whole batch, to be executed again.
+type MemcacheCheckpointStore struct {
+	// Prefix is prepended to all memcache keys. It defaults to "spannerr:checkpoint:".
+	Prefix string
+	// Expiration is how long checkpoints are kept. It defaults to a day, well
+	// past the version retention period that bounds resuming a batch.
+	Expiration time.Duration
+}
+
+func (m *MemcacheCheckpointStore) expiration() time.Duration {
+	if m.Expiration <= 0 {
+		return 24 * time.Hour
+	}
+	return m.Expiration
+}
+
+func (m *MemcacheCheckpointStore) key(job, partition string) string {
+	prefix := m.Prefix
+	if prefix == "" {
+		prefix = "spannerr:checkpoint:"
+	}
+	// keep keys under memcache's 250 byte limit regardless of the job name
+	return prefix + partitionKey(job) + ":" + partition
+}
+
+// Load implements CheckpointStore.
+func (m *MemcacheCheckpointStore) Load(ctx context.Context, job string) (*BatchQuery, error) {
+	var q BatchQuery
+	_, err := memcache.JSON.Get(ctx, m.key(job, ""), &q)
+	if err == memcache.ErrCacheMiss {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// Save implements CheckpointStore.
+func (m *MemcacheCheckpointStore) Save(ctx context.Context, job string, q *BatchQuery) error {
+	return memcache.JSON.Set(ctx, &memcache.Item{Key: m.key(job, ""), Object: q, Expiration: m.expiration()})
+}
+
+// Completed implements CheckpointStore.
+func (m *MemcacheCheckpointStore) Completed(ctx context.Context, job string, tokens []string) (map[string]bool, error) {
+	keys := make([]string, len(tokens))
+	byKey := map[string]string{}
+	for i, token := range tokens {
+		keys[i] = m.key(job, partitionKey(token))
+		byKey[keys[i]] = token
+	}
+	items, err := memcache.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	done := map[string]bool{}
+	for k := range items {
+		done[byKey[k]] = true
+	}
+	return done, nil
+}
+
+// Complete implements CheckpointStore.
+func (m *MemcacheCheckpointStore) Complete(ctx context.Context, job, token string) error {
+	return memcache.Set(ctx, &memcache.Item{Key: m.key(job, partitionKey(token)), Value: []byte{1}, Expiration: m.expiration()})
+}
+
+// Clear implements CheckpointStore. Only the batch is removed; partition entries
+// are keyed by token and expire after Expiration.
+func (m *MemcacheCheckpointStore) Clear(ctx context.Context, job string) error {
+	err := memcache.Delete(ctx, m.key(job, ""))
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
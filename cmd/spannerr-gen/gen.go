@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jprobinson/spannerr"
+	"github.com/pkg/errors"
+)
+
+// scalar describes how a Spanner scalar type maps to Go.
+type scalar struct {
+	goType string
+	// pkg is the import path needed by goType, if any.
+	pkg string
+	// builder is the spannerr.ParamBuilder method binding the type. Types
+	// without one can't be used as parameters.
+	builder string
+}
+
+var scalars = map[string]scalar{
+	"BOOL":      {goType: "bool", builder: "Bool"},
+	"INT64":     {goType: "int64", builder: "Int64"},
+	"FLOAT32":   {goType: "float32", builder: "Float32"},
+	"FLOAT64":   {goType: "float64", builder: "Float64"},
+	"NUMERIC":   {goType: "*big.Rat", pkg: "math/big", builder: "Numeric"},
+	"STRING":    {goType: "string", builder: "String"},
+	"JSON":      {goType: "string", builder: "JSON"},
+	"BYTES":     {goType: "[]byte", builder: "Bytes"},
+	"DATE":      {goType: "civil.Date", pkg: "cloud.google.com/go/civil", builder: "Date"},
+	"TIMESTAMP": {goType: "time.Time", pkg: "time", builder: "Timestamp"},
+	"INTERVAL":  {goType: "spannerr.Interval", builder: "Interval"},
+	"UUID":      {goType: "string"},
+	"ENUM":      {goType: "int64"},
+	"PROTO":     {goType: "[]byte"},
+}
+
+// generator renders the Go source for a set of planned queries.
+type generator struct {
+	pkg     string
+	imports map[string]bool
+	types   bytes.Buffer
+	funcs   bytes.Buffer
+}
+
+func newGenerator(pkg string) *generator {
+	return &generator{pkg: pkg, imports: map[string]bool{
+		"context":                        true,
+		"github.com/jprobinson/spannerr": true,
+	}}
+}
+
+// source returns the formatted Go source for everything added so far.
+func (g *generator) source() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by spannerr-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\nimport (\n", g.pkg)
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	// standard library imports first, as goimports would
+	sort.Slice(imports, func(i, j int) bool {
+		si, sj := !strings.Contains(imports[i], "."), !strings.Contains(imports[j], ".")
+		if si != sj {
+			return si
+		}
+		return imports[i] < imports[j]
+	})
+	for i, imp := range imports {
+		if i > 0 && strings.Contains(imp, ".") && !strings.Contains(imports[i-1], ".") {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "\t%q\n", imp)
+	}
+	buf.WriteString(")\n")
+	buf.Write(g.types.Bytes())
+	buf.Write(g.funcs.Bytes())
+	src, err := format.Source(buf.Bytes())
+	return src, errors.Wrap(err, "unable to format generated code")
+}
+
+// add generates the SQL constant, params and row types and function for q.
+func (g *generator) add(q *query, cs *spannerr.CatalogStatement) error {
+	kind := q.Kind
+	if kind == "" {
+		kind = kindMany
+		if cs.DML {
+			kind = kindExec
+		}
+	}
+	if kind != kindExec && len(cs.Result) == 0 {
+		return errors.Errorf("query %s returns no columns; use %s", q.Name, kindExec)
+	}
+
+	sqlConst := lowerFirst(q.Name) + "SQL"
+	fmt.Fprintf(&g.types, "\nconst %s = %s\n", sqlConst, quoteSQL(q.SQL))
+
+	var params []string
+	if len(cs.Params) > 0 {
+		fmt.Fprintf(&g.types, "\n// %sParams are the parameters of %s.\ntype %sParams struct {\n", q.Name, q.Name, q.Name)
+		seen := map[string]string{}
+		for _, p := range cs.Params {
+			field := goName(p.Name)
+			if prev, ok := seen[field]; ok {
+				return errors.Errorf("query %s: parameters %q and %q both map to field %s", q.Name, prev, p.Name, field)
+			}
+			seen[field] = p.Name
+			typ, method, err := g.paramType(p.Type)
+			if err != nil {
+				return errors.Wrapf(err, "query %s parameter %q", q.Name, p.Name)
+			}
+			fmt.Fprintf(&g.types, "\t%s %s\n", field, typ)
+			params = append(params, fmt.Sprintf(".\n\t\t%s(%q, p.%s)", method, p.Name, field))
+		}
+		g.types.WriteString("}\n")
+	}
+
+	rowType := q.Name + "Row"
+	if kind != kindExec {
+		fields := make([]structField, len(cs.Result))
+		for i, col := range cs.Result {
+			fields[i] = structField{Name: col.Name, Type: col.Type}
+		}
+		if err := g.structType(rowType, fmt.Sprintf("a row returned by %s", q.Name), fields); err != nil {
+			return errors.Wrapf(err, "query %s", q.Name)
+		}
+	}
+
+	// function
+	g.funcs.WriteString("\n")
+	if len(q.Doc) > 0 {
+		for _, line := range q.Doc {
+			fmt.Fprintf(&g.funcs, "// %s\n", line)
+		}
+	} else {
+		fmt.Fprintf(&g.funcs, "// %s executes:\n//\n", q.Name)
+		for _, line := range strings.Split(q.SQL, "\n") {
+			fmt.Fprintf(&g.funcs, "//\t%s\n", strings.TrimRight(line, " \t"))
+		}
+	}
+	if cs.DML {
+		g.funcs.WriteString("//\n// It must be called with a ctx carrying a read-write transaction; see spannerr.NewContext.\n")
+	}
+	args := "ctx context.Context, c *spannerr.Client"
+	if len(params) > 0 {
+		args += ", p " + q.Name + "Params"
+	}
+	switch kind {
+	case kindOne:
+		fmt.Fprintf(&g.funcs, "func %s(%s) (*%s, error) {\n", q.Name, args, rowType)
+	case kindMany:
+		fmt.Fprintf(&g.funcs, "func %s(%s) ([]%s, error) {\n", q.Name, args, rowType)
+	case kindExec:
+		fmt.Fprintf(&g.funcs, "func %s(%s) (int64, error) {\n", q.Name, args)
+	}
+	bind := "nil"
+	if len(params) > 0 {
+		bind = "params"
+		fmt.Fprintf(&g.funcs, "\tparams := spannerr.Params()%s.\n\t\tBuild()\n", strings.Join(params, ""))
+	}
+	if kind == kindExec {
+		g.imports["github.com/pkg/errors"] = true
+		fmt.Fprintf(&g.funcs, `	txn, ok := spannerr.FromContextTxn(ctx)
+	if !ok {
+		return 0, errors.New("%s must run in a read-write transaction")
+	}
+	res, err := txn.ExecuteSQL(ctx, %s, %s)
+	if err != nil {
+		return 0, err
+	}
+	if res.Stats == nil {
+		return 0, nil
+	}
+	return res.Stats.RowCountExact, nil
+}
+`, q.Name, bind, sqlConst)
+		return nil
+	}
+	if cs.DML {
+		g.imports["github.com/pkg/errors"] = true
+		fmt.Fprintf(&g.funcs, `	if _, ok := spannerr.FromContextTxn(ctx); !ok {
+		return nil, errors.New("%s must run in a read-write transaction")
+	}
+`, q.Name)
+	}
+	fmt.Fprintf(&g.funcs, "\tvar rows []%s\n\tif err := c.Query(ctx, %s, %s, &rows); err != nil {\n\t\treturn nil, err\n\t}\n", rowType, sqlConst, bind)
+	if kind == kindOne {
+		g.funcs.WriteString("\tif len(rows) == 0 {\n\t\treturn nil, spannerr.ErrRowNotFound\n\t}\n\treturn &rows[0], nil\n}\n")
+	} else {
+		g.funcs.WriteString("\treturn rows, nil\n}\n")
+	}
+	return nil
+}
+
+// paramType returns the Go type of a parameter of the given Spanner type and the
+// ParamBuilder method that binds it.
+func (g *generator) paramType(typ string) (string, string, error) {
+	if elem, ok := arrayElem(typ); ok {
+		s, ok := scalars[elem]
+		// JSON arrays would be inferred as ARRAY<STRING>
+		if !ok || s.builder == "" || elem == "JSON" {
+			return "", "", errors.Errorf("unsupported parameter type %s", typ)
+		}
+		g.use(s)
+		return "[]" + s.goType, "Value", nil
+	}
+	s, ok := scalars[typ]
+	if !ok || s.builder == "" {
+		return "", "", errors.Errorf("unsupported parameter type %s", typ)
+	}
+	g.use(s)
+	return s.goType, s.builder, nil
+}
+
+// structField is a named field of a row or STRUCT type.
+type structField struct {
+	Name string
+	Type string
+}
+
+// structType emits a struct type named name with the given fields. STRUCT-typed
+// fields are emitted as their own types, named after name and the field.
+func (g *generator) structType(name, doc string, fields []structField) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\n// %s is %s.\ntype %s struct {\n", name, doc, name)
+	seen := map[string]string{}
+	for _, f := range fields {
+		if f.Name == "" {
+			return errors.Errorf("column of type %s has no name; add an alias", f.Type)
+		}
+		field := goName(f.Name)
+		if prev, ok := seen[field]; ok {
+			return errors.Errorf("columns %q and %q both map to field %s", prev, f.Name, field)
+		}
+		seen[field] = f.Name
+		typ, err := g.resultType(name+field, f.Type)
+		if err != nil {
+			return errors.Wrapf(err, "column %q", f.Name)
+		}
+		fmt.Fprintf(&buf, "\t%s %s `spanner:%q`\n", field, typ, f.Name)
+	}
+	buf.WriteString("}\n")
+	g.types.Write(buf.Bytes())
+	return nil
+}
+
+// resultType returns the Go type of a result value of the given Spanner type,
+// emitting a struct type named name for STRUCT values.
+func (g *generator) resultType(name, typ string) (string, error) {
+	if elem, ok := arrayElem(typ); ok {
+		t, err := g.resultType(name, elem)
+		return "[]" + t, err
+	}
+	if strings.HasPrefix(typ, "STRUCT<") && strings.HasSuffix(typ, ">") {
+		fields, err := parseStructFields(typ[len("STRUCT<") : len(typ)-1])
+		if err != nil {
+			return "", err
+		}
+		return name, g.structType(name, "a STRUCT value", fields)
+	}
+	s, ok := scalars[typ]
+	if !ok {
+		return "", errors.Errorf("unsupported type %s", typ)
+	}
+	g.use(s)
+	return s.goType, nil
+}
+
+func (g *generator) use(s scalar) {
+	if s.pkg != "" {
+		g.imports[s.pkg] = true
+	}
+}
+
+func arrayElem(typ string) (string, bool) {
+	if strings.HasPrefix(typ, "ARRAY<") && strings.HasSuffix(typ, ">") {
+		return typ[len("ARRAY<") : len(typ)-1], true
+	}
+	return "", false
+}
+
+// parseStructFields parses the fields of a STRUCT type as rendered by
+// spannerr.Column.String, such as "Id INT64, Tags ARRAY<STRING>".
+func parseStructFields(s string) ([]structField, error) {
+	var (
+		fields []structField
+		depth  int
+		start  int
+	)
+	split := func(part string) error {
+		part = strings.TrimSpace(part)
+		i := strings.IndexByte(part, ' ')
+		if i < 0 || strings.IndexByte(part[:i], '<') >= 0 {
+			return errors.Errorf("STRUCT field of type %s has no name", part)
+		}
+		fields = append(fields, structField{Name: part[:i], Type: strings.TrimSpace(part[i+1:])})
+		return nil
+	}
+	for i, r := range s {
+		switch r {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				if err := split(s[start:i]); err != nil {
+					return nil, err
+				}
+				start = i + 1
+			}
+		}
+	}
+	if strings.TrimSpace(s[start:]) != "" {
+		if err := split(s[start:]); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// goName converts a column or parameter name such as "singer_id" into an
+// exported Go identifier such as "SingerId".
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	out := b.String()
+	if out == "" || unicode.IsDigit(rune(out[0])) {
+		out = "X" + out
+	}
+	return out
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// quoteSQL returns s as a Go string literal, preferring a raw string.
+func quoteSQL(s string) string {
+	if strings.Contains(s, "`") {
+		return fmt.Sprintf("%q", s)
+	}
+	return "`" + s + "`"
+}
@@ -0,0 +1,127 @@
+// Command spannerr-gen generates typed Go functions for annotated SQL statements.
+// Each statement is planned against a development database to learn its result
+// schema, and a params struct, a row struct and a function wrapping
+// spannerr.Client.Query, or Txn.ExecuteSQL for DML, are generated for it.
+//
+// Statements are read from SQL files, each introduced by a name annotation with
+// an optional kind, :one, :many or :exec, and followed by its parameters:
+//
+//	-- name: GetSinger :one
+//	-- param: id INT64
+//	-- GetSinger returns the singer with the given ID.
+//	SELECT SingerId, FirstName, LastName FROM Singers WHERE SingerId = @id;
+//
+// Plain comment lines after the annotations become the function's doc comment.
+// Queries default to :many and DML to :exec, which returns the affected row
+// count.
+//
+// Usage:
+//
+//	spannerr-gen -project p -instance i -database dev -package db -out queries.go queries/*.sql
+//
+// Application default credentials are used to reach the database.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/jprobinson/spannerr"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+func main() {
+	var (
+		project  = flag.String("project", "", "Google Cloud project of the development database")
+		instance = flag.String("instance", "", "Spanner instance of the development database")
+		database = flag.String("database", "", "development database to plan statements against")
+		pkg      = flag.String("package", "db", "package name of the generated file")
+		out      = flag.String("out", "", "file to write; defaults to stdout")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: spannerr-gen [flags] file.sql...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *project == "" || *instance == "" || *database == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	log.SetFlags(0)
+	log.SetPrefix("spannerr-gen: ")
+
+	src, err := generate(context.Background(), *project, *instance, *database, *pkg, flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate parses the SQL files, plans their statements and returns the
+// generated source.
+func generate(ctx context.Context, project, instance, database, pkg string, files []string) ([]byte, error) {
+	var qs []*query
+	reg := spannerr.NewRegistry()
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open SQL file")
+		}
+		fqs, err := parseQueries(file, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range fqs {
+			st := spannerr.Statement{Name: q.Name, SQL: q.SQL, Params: map[string]string{}}
+			for _, p := range q.Params {
+				st.Params[p.Name] = p.Type
+			}
+			if err := reg.Register(st); err != nil {
+				return nil, errors.Wrap(err, file)
+			}
+		}
+		qs = append(qs, fqs...)
+	}
+
+	ts, err := google.DefaultTokenSource(ctx, spanner.SpannerDataScope)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find default credentials")
+	}
+	client := spannerr.NewClient(project, instance, database,
+		spannerr.WithTokenSource(ts), spannerr.WithMaxSessions(1))
+	defer client.Close(ctx)
+	sess, err := client.AcquireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cat, err := reg.Catalog(ctx, sess)
+	client.ReleaseSession(ctx, *sess)
+	if err != nil {
+		return nil, err
+	}
+	stmts := map[string]*spannerr.CatalogStatement{}
+	for _, cs := range cat.Statements {
+		stmts[cs.Name] = cs
+	}
+
+	g := newGenerator(pkg)
+	for _, q := range qs {
+		if err := g.add(q, stmts[q.Name]); err != nil {
+			return nil, err
+		}
+	}
+	return g.source()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// query kinds, set with a suffix on the name annotation.
+const (
+	kindOne  = ":one"
+	kindMany = ":many"
+	kindExec = ":exec"
+)
+
+// query is an annotated statement read from a SQL file.
+type query struct {
+	Name string
+	// Kind is the kind of function to generate. If empty, it is :exec for
+	// DML and :many for queries.
+	Kind string
+	// Doc holds the plain comment lines following the annotations.
+	Doc    []string
+	Params []param
+	SQL    string
+}
+
+type param struct {
+	Name string
+	Type string
+}
+
+var (
+	nameRE  = regexp.MustCompile(`^--\s*name:\s*([A-Za-z0-9_]+)\s*(:[a-z]+)?\s*$`)
+	paramRE = regexp.MustCompile(`^--\s*param:\s*([A-Za-z_][A-Za-z0-9_]*)\s+(.+?)\s*$`)
+	goRE    = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+)
+
+// parseQueries reads the statements in a SQL file. Each statement is introduced
+// by a name annotation, optionally followed by the kind of the generated
+// function, and declares its parameters with param annotations:
+//
+//	-- name: GetSinger :one
+//	-- param: id INT64
+//	SELECT SingerId, FirstName FROM Singers WHERE SingerId = @id;
+func parseQueries(file string, r io.Reader) ([]*query, error) {
+	var (
+		qs   []*query
+		cur  *query
+		body []string
+	)
+	flush := func() error {
+		if cur == nil {
+			return nil
+		}
+		cur.SQL = strings.TrimSuffix(strings.TrimSpace(strings.Join(body, "\n")), ";")
+		if cur.SQL == "" {
+			return errors.Errorf("%s: query %q has no SQL", file, cur.Name)
+		}
+		qs = append(qs, cur)
+		body = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if m := nameRE.FindStringSubmatch(trimmed); m != nil {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			if !goRE.MatchString(m[1]) {
+				return nil, errors.Errorf("%s:%d: query name %q must be an exported Go identifier", file, n, m[1])
+			}
+			switch m[2] {
+			case "", kindOne, kindMany, kindExec:
+			default:
+				return nil, errors.Errorf("%s:%d: unknown query kind %q", file, n, m[2])
+			}
+			cur = &query{Name: m[1], Kind: m[2]}
+			continue
+		}
+		if cur == nil {
+			if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return nil, errors.Errorf("%s:%d: SQL before the first name annotation", file, n)
+			}
+			continue
+		}
+		if len(body) == 0 && strings.HasPrefix(trimmed, "--") {
+			if m := paramRE.FindStringSubmatch(trimmed); m != nil {
+				cur.Params = append(cur.Params, param{Name: m[1], Type: m[2]})
+			} else {
+				cur.Doc = append(cur.Doc, strings.TrimSpace(strings.TrimPrefix(trimmed, "--")))
+			}
+			continue
+		}
+		body = append(body, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "unable to read %s", file)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return qs, nil
+}
@@ -0,0 +1,88 @@
+package spannerr
+
+import (
+	"time"
+
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Column describes a column of a result set.
+type Column struct {
+	Name string
+	// Type is the type code of the column, such as "INT64" or "ARRAY".
+	Type string
+	// ElemType is the type code of the elements of an ARRAY column.
+	ElemType string
+	// Fields are the fields of a STRUCT column or of the elements of an
+	// ARRAY<STRUCT> column.
+	Fields []Column
+
+	typ *spanner.Type
+}
+
+// String renders the column type in SQL syntax, e.g. ARRAY<INT64>.
+func (c Column) String() string {
+	return typeString(c.typ)
+}
+
+// SpannerType returns the underlying API type of the column.
+func (c Column) SpannerType() *spanner.Type {
+	return c.typ
+}
+
+// Columns returns the columns described by a result set's metadata, in order.
+func Columns(md *spanner.ResultSetMetadata) []Column {
+	if md == nil || md.RowType == nil {
+		return nil
+	}
+	return structColumns(md.RowType)
+}
+
+func structColumns(st *spanner.StructType) []Column {
+	cols := make([]Column, len(st.Fields))
+	for i, f := range st.Fields {
+		c := Column{Name: f.Name, typ: f.Type}
+		t := f.Type
+		if t != nil {
+			c.Type = t.Code
+			if t.Code == "ARRAY" && t.ArrayElementType != nil {
+				c.ElemType = t.ArrayElementType.Code
+				t = t.ArrayElementType
+			}
+			if t.Code == "STRUCT" && t.StructType != nil {
+				c.Fields = structColumns(t.StructType)
+			}
+		}
+		cols[i] = c
+	}
+	return cols
+}
+
+// ColumnNames returns the names of the columns described by a result set's
+// metadata, in order.
+func ColumnNames(md *spanner.ResultSetMetadata) []string {
+	cols := Columns(md)
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// TransactionInfo returns the ID and read timestamp of the transaction begun by a
+// statement, as reported in its result set's metadata. ok is false if the
+// statement did not begin a transaction. The read timestamp is zero unless it was
+// requested with ReturnReadTimestamp.
+func TransactionInfo(md *spanner.ResultSetMetadata) (id string, readTimestamp time.Time, ok bool) {
+	if md == nil || md.Transaction == nil {
+		return "", time.Time{}, false
+	}
+	ts, _ := time.Parse(time.RFC3339Nano, md.Transaction.ReadTimestamp)
+	return md.Transaction.Id, ts, true
+}
+
+// Columns returns the columns of the result set, which are available once Next
+// has been called.
+func (it *RowIterator) Columns() []Column {
+	return Columns(it.metadata)
+}
@@ -0,0 +1,96 @@
+package spannerr
+
+import (
+	"context"
+	"strings"
+
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// TouchedTable describes the rows of a table written by a commit, as passed to
+// Client.OnCommitSuccess.
+type TouchedTable struct {
+	Table string
+	// Keys are the primary keys of the rows inserted, updated or deleted, with
+	// values in their API encoding, such as INT64 values as decimal strings.
+	Keys []Key
+	// Ranges are the key ranges deleted.
+	Ranges []*spanner.KeyRange
+	// All is set when the commit may have touched any row of the table, such as
+	// when a delete covered the whole table or the table's primary key could not
+	// be looked up.
+	All bool
+}
+
+// touchedTables summarizes the rows written by the given mutations, in the order
+// the tables were first written.
+func (s *Session) touchedTables(ctx context.Context, muts []*spanner.Mutation) []*TouchedTable {
+	var (
+		touched []*TouchedTable
+		byTable = map[string]*TouchedTable{}
+	)
+	for _, m := range muts {
+		table := mutationTable(m)
+		t, ok := byTable[table]
+		if !ok {
+			t = &TouchedTable{Table: table}
+			byTable[table] = t
+			touched = append(touched, t)
+		}
+		if t.All {
+			continue
+		}
+		if m.Delete != nil {
+			ks := m.Delete.KeySet
+			if ks == nil || ks.All {
+				t.All = true
+				continue
+			}
+			for _, k := range ks.Keys {
+				t.Keys = append(t.Keys, Key(k))
+			}
+			t.Ranges = append(t.Ranges, ks.Ranges...)
+			continue
+		}
+		w, _ := mutationWrite(m)
+		if w == nil {
+			continue
+		}
+		pk, err := s.PrimaryKey(ctx, table)
+		if err != nil {
+			s.client.logf(ctx, "unable to determine keys written to %s: %s", table, err)
+			t.All = true
+			continue
+		}
+		idx := make([]int, len(pk))
+		for i, col := range pk {
+			idx[i] = -1
+			for j, c := range w.Columns {
+				if strings.EqualFold(c, col) {
+					idx[i] = j
+					break
+				}
+			}
+			if idx[i] < 0 {
+				// a generated key column left for Spanner to fill in
+				t.All = true
+			}
+		}
+		if t.All {
+			continue
+		}
+		for _, row := range w.Values {
+			k := make(Key, len(idx))
+			for i, j := range idx {
+				k[i] = row[j]
+			}
+			t.Keys = append(t.Keys, k)
+		}
+	}
+	for _, t := range touched {
+		if t.All {
+			t.Keys, t.Ranges = nil, nil
+		}
+	}
+	return touched
+}
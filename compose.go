@@ -0,0 +1,102 @@
+package spannerr
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Fragment is a piece of SQL along with the parameters it references. Fragments
+// can be combined with Join without worrying about parameter name collisions,
+// which makes building dynamic WHERE clauses safe without resorting to fmt.Sprintf.
+//
+//	conds := []spannerr.Fragment{
+//		spannerr.Frag("Active = @v", &spannerr.Param{Name: "v", Value: true, Type: spannerr.TypeBool}),
+//	}
+//	if name != "" {
+//		conds = append(conds, spannerr.Frag("Name = @v",
+//			&spannerr.Param{Name: "v", Value: name, Type: spannerr.TypeString}))
+//	}
+//	sql, params, err := spannerr.Join(" ",
+//		spannerr.Frag("SELECT * FROM"), spannerr.Ident("Users"),
+//		spannerr.Frag("WHERE"), spannerr.Join(" AND ", conds...),
+//	).Build()
+type Fragment struct {
+	SQL    string
+	Params []*Param
+	err    error
+}
+
+// Frag returns a Fragment of raw SQL referencing the given params. The SQL must not
+// contain user input; pass values in params instead.
+func Frag(sql string, params ...*Param) Fragment {
+	return Fragment{SQL: sql, Params: params}
+}
+
+// Ident returns a Fragment holding the quoted table, column or index name. Invalid
+// identifiers cause Build to return an error.
+func Ident(name string) Fragment {
+	q, err := quoteIdent(name)
+	return Fragment{SQL: q, err: err}
+}
+
+// Idents returns a Fragment holding a comma-separated list of quoted identifiers.
+func Idents(names ...string) Fragment {
+	frags := make([]Fragment, len(names))
+	for i, n := range names {
+		frags[i] = Ident(n)
+	}
+	return Join(", ", frags...)
+}
+
+// Join concatenates the fragments with sep, renaming parameters so that parameters
+// of the same name in different fragments stay distinct. Within a single fragment,
+// repeated references to the same parameter are preserved.
+func Join(sep string, frags ...Fragment) Fragment {
+	var (
+		out   Fragment
+		parts []string
+		n     int
+	)
+	for _, f := range frags {
+		if f.err != nil && out.err == nil {
+			out.err = f.err
+		}
+		if f.SQL == "" {
+			continue
+		}
+		names := map[string]string{}
+		for _, p := range f.Params {
+			if _, ok := names[p.Name]; ok {
+				out.err = errors.Errorf("duplicate parameter %q in fragment %q", p.Name, f.SQL)
+				continue
+			}
+			np := *p
+			np.Name = "p" + strconv.Itoa(n)
+			n++
+			names[p.Name] = np.Name
+			out.Params = append(out.Params, &np)
+		}
+		parts = append(parts, paramRE.ReplaceAllStringFunc(f.SQL, func(m string) string {
+			if nn, ok := names[m[1:]]; ok {
+				return "@" + nn
+			}
+			if out.err == nil {
+				out.err = errors.Errorf("fragment %q references undeclared parameter %s", f.SQL, m)
+			}
+			return m
+		}))
+	}
+	out.SQL = strings.Join(parts, sep)
+	return out
+}
+
+// Build returns the final SQL and params of the fragment, or the first error
+// encountered while composing it.
+func (f Fragment) Build() (string, []*Param, error) {
+	if f.err != nil {
+		return "", nil, f.err
+	}
+	return f.SQL, f.Params, nil
+}
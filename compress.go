@@ -0,0 +1,95 @@
+package spannerr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultLargeParams is the size in bytes of encoded params, or of a request body,
+// above which a Client warns or compresses when LargeParams is not set.
+const DefaultLargeParams = 1 << 20
+
+type compressKey struct{}
+
+// CompressContext returns a copy of ctx that makes calls made with it gzip request
+// bodies larger than the Client's LargeParams threshold, such as queries with
+// big UNNEST array params, which would otherwise fail with opaque 413 errors.
+func CompressContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, compressKey{}, true)
+}
+
+func (c *Client) largeParams() int {
+	if c.LargeParams > 0 {
+		return c.LargeParams
+	}
+	return DefaultLargeParams
+}
+
+// compressing reports whether large request bodies of calls made with ctx are
+// compressed.
+func (c *Client) compressing(ctx context.Context) bool {
+	if c.NewService != nil {
+		// the caller's service controls the transport
+		return false
+	}
+	on, _ := ctx.Value(compressKey{}).(bool)
+	return on || c.CompressLargeParams
+}
+
+// checkParamsSize warns about params payloads over the LargeParams threshold that
+// will be sent uncompressed.
+func (c *Client) checkParamsSize(ctx context.Context, sql string, size int) {
+	if size <= c.largeParams() || c.compressing(ctx) {
+		return
+	}
+	c.logf(ctx, "params for statement %q are %d bytes; large requests may be rejected, "+
+		"consider CompressContext or splitting the statement", sql, size)
+}
+
+// compress returns a copy of hc that gzips large request bodies when the call's
+// ctx asks for it.
+func (c *Client) compress(hc *http.Client) *http.Client {
+	out := *hc
+	out.Transport = &gzipTransport{base: hc.Transport, client: c}
+	return &out
+}
+
+// gzipTransport compresses request bodies larger than the Client's LargeParams
+// threshold.
+type gzipTransport struct {
+	base   http.RoundTripper
+	client *Client
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *gzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Body == nil || req.Header.Get("Content-Encoding") != "" ||
+		req.ContentLength <= int64(t.client.largeParams()) || !t.client.compressing(req.Context()) {
+		return base.RoundTrip(req)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := io.Copy(zw, req.Body)
+	req.Body.Close()
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to compress request body")
+	}
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(&buf)
+	out.ContentLength = int64(buf.Len())
+	out.GetBody = nil
+	out.Header.Set("Content-Encoding", "gzip")
+	return base.RoundTrip(out)
+}
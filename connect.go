@@ -0,0 +1,28 @@
+package spannerr
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Connect eagerly resolves the Client's credentials, acquires a pooled session and
+// runs a trivial query on it, so that configuration, authentication and
+// permission errors surface at startup rather than on the first user-facing
+// request. Calling it is optional; Clients otherwise initialize lazily. When it
+// fails, Diagnose reports which step of connecting went wrong.
+func (c *Client) Connect(ctx context.Context) error {
+	if c.TokenSource != nil {
+		if _, err := c.TokenSource.Token(); err != nil {
+			return errors.Wrap(err, "unable to get token from TokenSource")
+		}
+	}
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to connect to %s", c.conn)
+	}
+	defer c.ReleaseSession(ctx, *sess)
+	_, err = sess.rpc(ctx).ExecuteSql(sess.name, &spanner.ExecuteSqlRequest{Sql: "SELECT 1"}).Context(ctx).Do()
+	return errors.Wrapf(err, "unable to query %s", c.conn)
+}
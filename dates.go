@@ -0,0 +1,33 @@
+package spannerr
+
+import (
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/pkg/errors"
+)
+
+// ParseDate parses a Spanner DATE value, such as "2006-01-02". Unlike a plain
+// time.Parse it returns a calendar date free of any time zone, so it can't shift
+// by a day when converted between zones.
+func ParseDate(s string) (civil.Date, error) {
+	d, err := civil.ParseDate(s)
+	if err != nil {
+		return civil.Date{}, errors.Errorf("invalid DATE value %q", s)
+	}
+	if d.Year < 1 || d.Year > 9999 {
+		return civil.Date{}, errors.Errorf("DATE value %q out of range", s)
+	}
+	return d, nil
+}
+
+// ParseTimestamp parses a Spanner TIMESTAMP value in RFC 3339 format with up to
+// nanosecond precision and either a "Z" or numeric offset, such as
+// "2006-01-02T15:04:05.999999999Z". The result is in UTC.
+func ParseTimestamp(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid TIMESTAMP value %q", s)
+	}
+	return t.UTC(), nil
+}
@@ -0,0 +1,203 @@
+package spannerr
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// TableSchema describes a table parsed from the database DDL.
+	TableSchema struct {
+		Name    string
+		Columns []*ColumnSchema
+		// PrimaryKey holds the key columns in key order.
+		PrimaryKey []string
+		// Parent is the table this table is interleaved in, if any.
+		Parent string
+	}
+
+	// ColumnSchema describes a column parsed from the database DDL.
+	ColumnSchema struct {
+		Name string
+		// Type is the column type as written in the DDL, e.g. "STRING(MAX)" or
+		// "ARRAY<INT64>".
+		Type    string
+		NotNull bool
+		// Generated is true for generated columns, which cannot be written.
+		Generated bool
+	}
+)
+
+// Column returns the named column, or nil if there is no such column.
+func (t *TableSchema) Column(name string) *ColumnSchema {
+	for _, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Schema returns the tables of the Client's database, parents before their
+// interleaved children, as described by the database DDL.
+// This function wraps https://godoc.org/google.golang.org/api/spanner/v1#ProjectsInstancesDatabasesService.GetDdl
+func (c *Client) Schema(ctx context.Context) ([]*TableSchema, error) {
+	svc, err := c.adminSpanner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := svc.Projects.Instances.Databases.GetDdl(c.conn).Context(ctx).Do()
+	if err != nil {
+		return nil, adminError(err, "get database ddl")
+	}
+	return ParseDDL(res.Statements)
+}
+
+var (
+	createTableRE = regexp.MustCompile(`(?is)^\s*CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?` + "`?" + `(\w+)` + "`?" + `\s*\(`)
+	primaryKeyRE  = regexp.MustCompile(`(?is)^\s*PRIMARY\s+KEY\s*\(([^)]*)\)`)
+	interleaveRE  = regexp.MustCompile(`(?is)INTERLEAVE\s+IN\s+(?:PARENT\s+)?` + "`?" + `(\w+)` + "`?")
+	notNullRE     = regexp.MustCompile(`(?i)\bNOT\s+NULL\b`)
+	generatedRE   = regexp.MustCompile(`(?i)^\s*AS\s*\(`)
+)
+
+// ParseDDL parses the CREATE TABLE statements among the given DDL statements, as
+// returned by GetDatabaseDdl, ignoring all other statements. Tables are returned
+// in the order they were created, so parents precede their interleaved children.
+func ParseDDL(stmts []string) ([]*TableSchema, error) {
+	var tables []*TableSchema
+	for _, stmt := range stmts {
+		m := createTableRE.FindStringSubmatchIndex(stmt)
+		if m == nil {
+			continue
+		}
+		t := &TableSchema{Name: stmt[m[2]:m[3]]}
+		open := m[1] - 1
+		end := closingParen(stmt, open)
+		if end < 0 {
+			return nil, errors.Errorf("unbalanced parentheses in definition of table %q", t.Name)
+		}
+		for _, def := range splitTopLevel(stmt[open+1 : end]) {
+			col, err := parseColumn(def)
+			if err != nil {
+				return nil, errors.Wrapf(err, "table %q", t.Name)
+			}
+			if col != nil {
+				t.Columns = append(t.Columns, col)
+			}
+		}
+		rest := stmt[end+1:]
+		pk := primaryKeyRE.FindStringSubmatch(rest)
+		if pk == nil {
+			return nil, errors.Errorf("no primary key in definition of table %q", t.Name)
+		}
+		for _, k := range strings.Split(pk[1], ",") {
+			if f := strings.Fields(k); len(f) > 0 {
+				t.PrimaryKey = append(t.PrimaryKey, strings.Trim(f[0], "`"))
+			}
+		}
+		if il := interleaveRE.FindStringSubmatch(rest); il != nil {
+			t.Parent = il[1]
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// parseColumn parses a single column definition, returning nil for constraints.
+func parseColumn(def string) (*ColumnSchema, error) {
+	def = strings.TrimSpace(def)
+	upper := strings.ToUpper(def)
+	for _, kw := range []string{"CONSTRAINT ", "FOREIGN KEY", "CHECK", "PRIMARY KEY"} {
+		if strings.HasPrefix(upper, kw) {
+			return nil, nil
+		}
+	}
+	fs := strings.Fields(def)
+	if len(fs) < 2 {
+		return nil, errors.Errorf("invalid column definition %q", def)
+	}
+	col := &ColumnSchema{Name: strings.Trim(fs[0], "`")}
+	rest := strings.TrimSpace(def[len(fs[0]):])
+	// the type runs until the first space outside of brackets
+	depth, i := 0, 0
+	for ; i < len(rest); i++ {
+		if c := rest[i]; c == '(' || c == '<' {
+			depth++
+		} else if c == ')' || c == '>' {
+			depth--
+		} else if depth == 0 && (c == ' ' || c == '\t' || c == '\n') {
+			break
+		}
+	}
+	col.Type = strings.Join(strings.Fields(rest[:i]), "")
+	opts := rest[i:]
+	col.NotNull = notNullRE.MatchString(opts)
+	col.Generated = generatedRE.MatchString(notNullRE.ReplaceAllString(opts, ""))
+	return col, nil
+}
+
+// closingParen returns the index of the parenthesis closing the one at open,
+// skipping over quoted strings, or -1.
+func closingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			if j := strings.IndexByte(s[i+1:], s[i]); j >= 0 {
+				i += j + 1
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits s on commas that are not nested within brackets or quotes.
+func splitTopLevel(s string) []string {
+	var (
+		parts  []string
+		parens int
+		angles int
+		start  int
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"', '`':
+			if j := strings.IndexByte(s[i+1:], s[i]); j >= 0 {
+				i += j + 1
+			}
+		case '(':
+			parens++
+		case ')':
+			parens--
+		case '<':
+			// only type parameters, not comparisons within expressions
+			if parens == 0 {
+				angles++
+			}
+		case '>':
+			if parens == 0 {
+				angles--
+			}
+		case ',':
+			if parens == 0 && angles == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if strings.TrimSpace(s[start:]) != "" {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
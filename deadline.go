@@ -0,0 +1,41 @@
+package spannerr
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BudgetError is returned when too little time remains before the ctx deadline,
+// such as the App Engine request deadline, to safely start an operation. Failing
+// early leaves the request time to respond instead of being killed by the
+// platform part way through a commit.
+type BudgetError struct {
+	// Op is the operation that was not started.
+	Op string
+	// Remaining is the time that was left before the deadline.
+	Remaining time.Duration
+	// Reserve is the Client's DeadlineReserve.
+	Reserve time.Duration
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("deadline budget exhausted: %s not started with %s remaining (reserve %s)",
+		e.Op, e.Remaining.Round(time.Millisecond), e.Reserve)
+}
+
+// checkBudget returns a *BudgetError if ctx has a deadline and less than the
+// Client's DeadlineReserve remains before it.
+func (c *Client) checkBudget(ctx context.Context, op string) error {
+	if c == nil || c.DeadlineReserve <= 0 {
+		return nil
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	if remaining := time.Until(deadline); remaining < c.DeadlineReserve {
+		return &BudgetError{Op: op, Remaining: remaining, Reserve: c.DeadlineReserve}
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package spannerr
+
+import (
+	"encoding/base64"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// fieldInfo describes a struct field mapped to a Spanner column via the `spanner`
+// struct tag. Tags take the form `spanner:"ColumnName,option,..."`. A tag of "-"
+// skips the field and an empty name uses the Go field name.
+type fieldInfo struct {
+	name  string
+	index []int
+	opts  []string
+}
+
+func (f fieldInfo) hasOpt(opt string) bool {
+	for _, o := range f.opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+var fieldCache sync.Map // map[reflect.Type][]fieldInfo
+
+// structFields returns the mapped fields of the given struct type, flattening
+// untagged embedded structs.
+func structFields(t reflect.Type) []fieldInfo {
+	if fs, ok := fieldCache.Load(t); ok {
+		return fs.([]fieldInfo)
+	}
+	var fs []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("spanner")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Struct {
+			for _, ef := range structFields(f.Type) {
+				ef.index = append([]int{i}, ef.index...)
+				fs = append(fs, ef)
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		if name == "" {
+			name = f.Name
+		}
+		fs = append(fs, fieldInfo{name: name, index: []int{i}, opts: parts[1:]})
+	}
+	fieldCache.Store(t, fs)
+	return fs
+}
+
+// decodeRows decodes all rows of the result set into dst, which must be a pointer
+// to a slice of structs or struct pointers.
+func decodeRows(rs *spanner.ResultSet, dst interface{}) error {
+	sv := reflect.ValueOf(dst)
+	if sv.Kind() != reflect.Ptr || sv.Elem().Kind() != reflect.Slice {
+		return errors.Errorf("destination must be a pointer to a slice, got %T", dst)
+	}
+	sv = sv.Elem()
+	if rs == nil {
+		return nil
+	}
+	var fields []*spanner.Field
+	if rs.Metadata != nil && rs.Metadata.RowType != nil {
+		fields = rs.Metadata.RowType.Fields
+	}
+	elemType := sv.Type().Elem()
+	for _, row := range rs.Rows {
+		ev := reflect.New(elemType)
+		if err := decodeStruct(fields, row, ev.Interface()); err != nil {
+			return err
+		}
+		sv.Set(reflect.Append(sv, ev.Elem()))
+	}
+	return nil
+}
+
+// decodeStruct decodes a single row into the struct pointed to by dst. Columns
+// are matched to fields by name, case-insensitively. Columns without a matching
+// field are ignored.
+func decodeStruct(fields []*spanner.Field, row []interface{}, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.Errorf("destination must be a non-nil pointer, got %T", dst)
+	}
+	v = v.Elem()
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return errors.Errorf("destination must point to a struct, got %T", dst)
+	}
+	fs := structFields(v.Type())
+	for i, f := range fields {
+		if i >= len(row) {
+			break
+		}
+		for _, fi := range fs {
+			if !strings.EqualFold(fi.name, f.Name) {
+				continue
+			}
+			if err := decodeValue(row[i], f.Type, v.FieldByIndex(fi.index)); err != nil {
+				return errors.Wrapf(err, "unable to decode column %q", f.Name)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	ratType      = reflect.TypeOf(big.Rat{})
+	bytesType    = reflect.TypeOf([]byte(nil))
+	intervalType = reflect.TypeOf(Interval{})
+	dateType     = reflect.TypeOf(civil.Date{})
+)
+
+// decodeValue decodes a JSON-encoded Spanner value of type t into dst. NULL values
+// set dst to its zero value.
+func decodeValue(val interface{}, t *spanner.Type, dst reflect.Value) error {
+	if dst.Kind() == reflect.Ptr {
+		if val == nil {
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return decodeValue(val, t, dst.Elem())
+	}
+	if val == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if dst.Kind() == reflect.Interface {
+		dst.Set(reflect.ValueOf(val))
+		return nil
+	}
+	var code string
+	if t != nil {
+		code = t.Code
+	}
+
+	switch dst.Type() {
+	case timeType:
+		s, ok := val.(string)
+		if !ok {
+			return errors.Errorf("cannot decode %T into time.Time", val)
+		}
+		if code == "DATE" {
+			d, err := ParseDate(s)
+			if err != nil {
+				return err
+			}
+			dst.Set(reflect.ValueOf(d.In(time.UTC)))
+			return nil
+		}
+		tm, err := ParseTimestamp(s)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(tm))
+		return nil
+	case dateType:
+		s, ok := val.(string)
+		if !ok {
+			return errors.Errorf("cannot decode %T into civil.Date", val)
+		}
+		if code == "TIMESTAMP" {
+			tm, err := ParseTimestamp(s)
+			if err != nil {
+				return err
+			}
+			dst.Set(reflect.ValueOf(civil.DateOf(tm)))
+			return nil
+		}
+		d, err := ParseDate(s)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(d))
+		return nil
+	case ratType:
+		s, ok := val.(string)
+		if !ok {
+			return errors.Errorf("cannot decode %T into big.Rat", val)
+		}
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return errors.Errorf("invalid numeric value %q", s)
+		}
+		dst.Set(reflect.ValueOf(*r))
+		return nil
+	case intervalType:
+		s, ok := val.(string)
+		if !ok {
+			return errors.Errorf("cannot decode %T into Interval", val)
+		}
+		iv, err := ParseInterval(s)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(iv))
+		return nil
+	case bytesType:
+		s, ok := val.(string)
+		if !ok {
+			return errors.Errorf("cannot decode %T into []byte", val)
+		}
+		if code != "BYTES" && code != "" {
+			dst.SetBytes([]byte(s))
+			return nil
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return errors.Wrap(err, "invalid bytes value")
+		}
+		dst.SetBytes(b)
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		switch v := val.(type) {
+		case string:
+			dst.SetString(v)
+		case float64:
+			dst.SetString(strconv.FormatFloat(v, 'g', -1, 64))
+		case bool:
+			dst.SetString(strconv.FormatBool(v))
+		default:
+			return errors.Errorf("cannot decode %T into string", val)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v := val.(type) {
+		case string:
+			i, err := strconv.ParseInt(v, 10, dst.Type().Bits())
+			if err != nil {
+				return errors.Wrap(err, "invalid integer value")
+			}
+			dst.SetInt(i)
+		case float64:
+			dst.SetInt(int64(v))
+		default:
+			return errors.Errorf("cannot decode %T into %s", val, dst.Type())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch v := val.(type) {
+		case string:
+			i, err := strconv.ParseUint(v, 10, dst.Type().Bits())
+			if err != nil {
+				return errors.Wrap(err, "invalid integer value")
+			}
+			dst.SetUint(i)
+		case float64:
+			dst.SetUint(uint64(v))
+		default:
+			return errors.Errorf("cannot decode %T into %s", val, dst.Type())
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v := val.(type) {
+		case float64:
+			dst.SetFloat(v)
+		case string:
+			// NaN and infinities are encoded as strings, as is NUMERIC.
+			f, err := strconv.ParseFloat(v, dst.Type().Bits())
+			if err != nil {
+				return errors.Wrap(err, "invalid float value")
+			}
+			dst.SetFloat(f)
+		default:
+			return errors.Errorf("cannot decode %T into %s", val, dst.Type())
+		}
+	case reflect.Bool:
+		b, ok := val.(bool)
+		if !ok {
+			return errors.Errorf("cannot decode %T into bool", val)
+		}
+		dst.SetBool(b)
+	case reflect.Slice:
+		vals, ok := val.([]interface{})
+		if !ok {
+			return errors.Errorf("cannot decode %T into %s", val, dst.Type())
+		}
+		var et *spanner.Type
+		if t != nil {
+			et = t.ArrayElementType
+		}
+		out := reflect.MakeSlice(dst.Type(), len(vals), len(vals))
+		for i, v := range vals {
+			if err := decodeValue(v, et, out.Index(i)); err != nil {
+				return errors.Wrapf(err, "array element %d", i)
+			}
+		}
+		dst.Set(out)
+	case reflect.Struct:
+		vals, ok := val.([]interface{})
+		if !ok || t == nil || t.StructType == nil {
+			return errors.Errorf("cannot decode %T into %s", val, dst.Type())
+		}
+		return decodeStruct(t.StructType.Fields, vals, dst.Addr().Interface())
+	default:
+		return errors.Errorf("unsupported destination type %s", dst.Type())
+	}
+	return nil
+}
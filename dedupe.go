@@ -0,0 +1,175 @@
+package spannerr
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// mutationRow is a single row written or deleted by a mutation.
+type mutationRow struct {
+	op      string
+	table   string
+	columns []string
+	values  []interface{}
+	// key is the encoded primary key of the row, or empty if it is unknown.
+	key string
+	// raw is set for deletes of key ranges or whole tables, which are kept as is.
+	raw *spanner.Mutation
+}
+
+// supersedes reports whether r makes the earlier write or delete of the same row,
+// prev, redundant.
+func (r *mutationRow) supersedes(prev *mutationRow) bool {
+	if r.op != prev.op || strings.Join(r.columns, "\x00") != strings.Join(prev.columns, "\x00") {
+		return false
+	}
+	if r.op == "insert" {
+		// a second insert of the same row fails unless it is a duplicate
+		return reflect.DeepEqual(r.values, prev.values)
+	}
+	return true
+}
+
+// dedupeMutations drops mutations made redundant by later ones in the same
+// buffer: duplicate mutations, and writes of a row superseded by a later write of
+// the same kind to the same columns, with no other mutation of the row between
+// them. The remaining rows are regrouped into as few mutations as possible while
+// preserving their order.
+func (s *Session) dedupeMutations(ctx context.Context, muts []*spanner.Mutation) []*spanner.Mutation {
+	var (
+		rows []*mutationRow
+		// last holds the index in rows of the last mutation of each row, by table
+		last = map[string]map[string]int{}
+	)
+	add := func(r *mutationRow) {
+		if r.key == "" {
+			// the row can't be matched, so nothing before it may be merged
+			delete(last, r.table)
+			rows = append(rows, r)
+			return
+		}
+		byKey := last[r.table]
+		if byKey == nil {
+			byKey = map[string]int{}
+			last[r.table] = byKey
+		}
+		if i, ok := byKey[r.key]; ok && r.supersedes(rows[i]) {
+			rows[i] = nil
+		}
+		byKey[r.key] = len(rows)
+		rows = append(rows, r)
+	}
+	for _, m := range muts {
+		table := mutationTable(m)
+		if m.Delete != nil {
+			ks := m.Delete.KeySet
+			if ks == nil || ks.All || len(ks.Ranges) > 0 {
+				add(&mutationRow{table: table, raw: m})
+				continue
+			}
+			for _, k := range ks.Keys {
+				add(&mutationRow{op: "delete", table: table, values: k, key: encodeKey(k)})
+			}
+			continue
+		}
+		w, op := mutationWrite(m)
+		if w == nil {
+			continue
+		}
+		idx := s.keyColumns(ctx, table, w.Columns)
+		for _, vals := range w.Values {
+			r := &mutationRow{op: op, table: table, columns: w.Columns, values: vals}
+			if idx != nil {
+				k := make([]interface{}, len(idx))
+				for i, j := range idx {
+					k[i] = vals[j]
+				}
+				r.key = encodeKey(k)
+			}
+			add(r)
+		}
+	}
+
+	var (
+		out  []*spanner.Mutation
+		prev *mutationRow
+	)
+	for _, r := range rows {
+		switch {
+		case r == nil:
+			continue
+		case r.raw != nil:
+			out = append(out, r.raw)
+			prev = nil
+			continue
+		case prev != nil && prev.op == r.op && prev.table == r.table &&
+			strings.Join(prev.columns, "\x00") == strings.Join(r.columns, "\x00"):
+			m := out[len(out)-1]
+			if r.op == "delete" {
+				m.Delete.KeySet.Keys = append(m.Delete.KeySet.Keys, r.values)
+			} else {
+				w, _ := mutationWrite(m)
+				w.Values = append(w.Values, r.values)
+			}
+			continue
+		}
+		var m *spanner.Mutation
+		if r.op == "delete" {
+			m = &spanner.Mutation{Delete: &spanner.Delete{
+				Table:  r.table,
+				KeySet: &spanner.KeySet{Keys: [][]interface{}{r.values}},
+			}}
+		} else {
+			w := &spanner.Write{Table: r.table, Columns: r.columns, Values: [][]interface{}{r.values}}
+			switch r.op {
+			case "insert":
+				m = &spanner.Mutation{Insert: w}
+			case "update":
+				m = &spanner.Mutation{Update: w}
+			case "insert_or_update":
+				m = &spanner.Mutation{InsertOrUpdate: w}
+			case "replace":
+				m = &spanner.Mutation{Replace: w}
+			}
+		}
+		out = append(out, m)
+		prev = r
+	}
+	return out
+}
+
+// keyColumns returns the index in columns of each of the table's primary key
+// columns, or nil if the primary key can't be looked up or isn't fully written.
+func (s *Session) keyColumns(ctx context.Context, table string, columns []string) []int {
+	pk, err := s.PrimaryKey(ctx, table)
+	if err != nil {
+		return nil
+	}
+	idx := make([]int, len(pk))
+	for i, col := range pk {
+		idx[i] = -1
+		for j, c := range columns {
+			if strings.EqualFold(c, col) {
+				idx[i] = j
+				break
+			}
+		}
+		if idx[i] < 0 {
+			return nil
+		}
+	}
+	return idx
+}
+
+// encodeKey returns a string uniquely identifying a key with API encoded values.
+func encodeKey(k []interface{}) string {
+	b, err := json.Marshal(k)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
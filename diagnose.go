@@ -0,0 +1,245 @@
+package spannerr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Diagnosis is the report produced by Client.Diagnose.
+type Diagnosis struct {
+	// Database is the full resource name of the Client's database.
+	Database string `json:"database"`
+	// Checks are the checks run, in order. A failed check skips the checks
+	// that depend on it.
+	Checks []*DiagnosticCheck `json:"checks"`
+	// Latency is the median round trip time of a trivial query on a new
+	// session, if it could be measured.
+	Latency time.Duration `json:"latency"`
+}
+
+// DiagnosticCheck is the outcome of a single Diagnose check.
+type DiagnosticCheck struct {
+	// Name is the name of the check: "credentials", "scopes", "database",
+	// "session" or "latency".
+	Name string `json:"name"`
+	// Status is one of "ok", "failed" or "skipped".
+	Status string `json:"status"`
+	// Detail describes what was found, or why the check was skipped.
+	Detail string `json:"detail,omitempty"`
+	// Err is the error that failed the check.
+	Err     string        `json:"error,omitempty"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// OK reports whether no check failed.
+func (d *Diagnosis) OK() bool {
+	for _, ch := range d.Checks {
+		if ch.Status == "failed" {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the report for logs and terminals, one check per line.
+func (d *Diagnosis) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "spanner diagnosis for %s:\n", d.Database)
+	for _, ch := range d.Checks {
+		fmt.Fprintf(&b, "  %-11s %-7s %s", ch.Name, ch.Status, ch.Detail)
+		if ch.Err != "" {
+			fmt.Fprintf(&b, ": %s", ch.Err)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// latencySamples is the number of queries timed by Diagnose.
+const latencySamples = 5
+
+// tokenInfoURL is used to look up the scopes granted to an access token.
+var tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// Diagnose checks, step by step, that the Client can reach its database: that
+// credentials can be resolved, that they carry a scope allowing Spanner data
+// access, that the database exists, that the credentials may create sessions,
+// and how long a round trip takes. It never fails; problems are reported in the
+// returned Diagnosis, which is meant to be logged or served from a debug
+// handler. The session it creates is deleted again and the pool is not used.
+func (c *Client) Diagnose(ctx context.Context) *Diagnosis {
+	d := &Diagnosis{Database: c.conn}
+	check := func(name string, fn func(ch *DiagnosticCheck) error) bool {
+		ch := &DiagnosticCheck{Name: name, Status: "ok"}
+		d.Checks = append(d.Checks, ch)
+		start := time.Now()
+		if err := protect(func() error { return fn(ch) }); err != nil {
+			ch.Status = "failed"
+			ch.Err = err.Error()
+		}
+		ch.Elapsed = time.Since(start)
+		return ch.Status == "ok"
+	}
+	skip := func(name, why string) {
+		d.Checks = append(d.Checks, &DiagnosticCheck{Name: name, Status: "skipped", Detail: why})
+	}
+
+	var token *oauth2.Token
+	credsOK := check("credentials", func(ch *DiagnosticCheck) error {
+		ts, source, err := c.dataTokenSource(ctx)
+		ch.Detail = source
+		if err != nil || ts == nil {
+			return err
+		}
+		if token, err = ts.Token(); err != nil {
+			return errors.Wrap(err, "unable to get token")
+		}
+		if !token.Expiry.IsZero() {
+			ch.Detail += fmt.Sprintf("; token expires in %s", time.Until(token.Expiry).Round(time.Second))
+		}
+		return nil
+	})
+	switch {
+	case !credsOK:
+		skip("scopes", "no credentials")
+	case token == nil:
+		skip("scopes", "credentials are not managed by the Client")
+	default:
+		check("scopes", func(ch *DiagnosticCheck) error {
+			scopes, err := c.tokenScopes(ctx, token.AccessToken)
+			if err != nil {
+				return err
+			}
+			ch.Detail = "granted " + strings.Join(scopes, " ")
+			for _, s := range scopes {
+				if s == spanner.SpannerDataScope || s == spanner.CloudPlatformScope {
+					return nil
+				}
+			}
+			return errors.Errorf("token lacks the %s or %s scope", spanner.SpannerDataScope, spanner.CloudPlatformScope)
+		})
+	}
+
+	check("database", func(ch *DiagnosticCheck) error {
+		if c.NewService != nil {
+			ch.Status = "skipped"
+			ch.Detail = "admin calls don't use Client.NewService"
+			return nil
+		}
+		svc, err := c.adminSpanner(ctx)
+		if err != nil {
+			return err
+		}
+		db, err := svc.Projects.Instances.Databases.Get(c.conn).Context(ctx).Do()
+		switch ErrorCode(err) {
+		case "":
+			ch.Detail = "state " + db.State
+			if db.DatabaseDialect != "" {
+				ch.Detail += ", dialect " + db.DatabaseDialect
+			}
+			if db.State != "READY" && db.State != "READY_OPTIMIZING" {
+				return errors.Errorf("database is %s", db.State)
+			}
+			return nil
+		case "PERMISSION_DENIED", "UNAUTHENTICATED":
+			// data credentials often can't read database metadata; creating a
+			// session tells whether it exists
+			ch.Status = "skipped"
+			ch.Detail = "no permission to get database metadata"
+			return nil
+		case "NOT_FOUND":
+			return errors.New("database does not exist")
+		}
+		return err
+	})
+
+	var sess *Session
+	if !check("session", func(ch *DiagnosticCheck) error {
+		var err error
+		sess, err = c.newSession(ctx)
+		switch ErrorCode(err) {
+		case "":
+			ch.Detail = "created " + sess.name
+		case "NOT_FOUND":
+			return errors.Wrap(err, "database or instance does not exist")
+		case "PERMISSION_DENIED":
+			return errors.Wrap(err, "credentials need spanner.sessions.create, such as from roles/spanner.databaseUser")
+		}
+		return err
+	}) {
+		skip("latency", "no session")
+		return d
+	}
+	defer sess.rpc(ctx).Delete(sess.name).Context(ctx).Do()
+
+	check("latency", func(ch *DiagnosticCheck) error {
+		var rtts []time.Duration
+		for i := 0; i < latencySamples; i++ {
+			start := time.Now()
+			_, err := sess.rpc(ctx).ExecuteSql(sess.name, &spanner.ExecuteSqlRequest{Sql: "SELECT 1"}).Context(ctx).Do()
+			if err != nil {
+				return errors.Wrap(err, "unable to query")
+			}
+			rtts = append(rtts, time.Since(start))
+		}
+		sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+		d.Latency = rtts[len(rtts)/2]
+		ch.Detail = fmt.Sprintf("median %s, min %s, max %s over %d queries",
+			d.Latency.Round(time.Microsecond), rtts[0].Round(time.Microsecond), rtts[len(rtts)-1].Round(time.Microsecond), len(rtts))
+		return nil
+	})
+	return d
+}
+
+// dataTokenSource resolves the credentials used for data calls the same way
+// httpClient does, describing where they come from. It returns a nil
+// TokenSource if the Client's HTTP client or service authenticates on its own.
+func (c *Client) dataTokenSource(ctx context.Context) (oauth2.TokenSource, string, error) {
+	switch {
+	case c.NewService != nil:
+		return nil, "Client.NewService", nil
+	case c.HTTPClient != nil:
+		return nil, "Client.HTTPClient", nil
+	}
+	return c.tokenSource(ctx, c.dataScopes())
+}
+
+// tokenScopes returns the scopes granted to an access token, looked up with the
+// Client's HTTPClient or else the client oauth2 uses for ctx, such as urlfetch on
+// App Engine.
+func (c *Client) tokenScopes(ctx context.Context, accessToken string) ([]string, error) {
+	req, err := http.NewRequest(http.MethodGet, tokenInfoURL+"?access_token="+url.QueryEscape(accessToken), nil)
+	if err != nil {
+		return nil, err
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = oauth2.NewClient(ctx, nil)
+	}
+	res, err := hc.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to look up token")
+	}
+	defer res.Body.Close()
+	var info struct {
+		Scope string `json:"scope"`
+		Error string `json:"error_description"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return nil, errors.Wrap(err, "unable to decode token info")
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("token rejected: %s", info.Error)
+	}
+	return strings.Fields(info.Scope), nil
+}
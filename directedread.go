@@ -0,0 +1,44 @@
+package spannerr
+
+import (
+	"context"
+
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// directedReadable reports whether directed reads may be used with tx. Spanner
+// only allows them in read-only transactions.
+func directedReadable(tx *spanner.TransactionSelector) bool {
+	switch {
+	case tx == nil:
+		// the default is a strong single-use read-only transaction
+		return true
+	case tx.SingleUse != nil:
+		return tx.SingleUse.ReadOnly != nil
+	case tx.Begin != nil:
+		return tx.Begin.ReadOnly != nil
+	}
+	// the mode of an existing transaction is unknown
+	return false
+}
+
+// withDirectedReads calls fn with each of the Client's ReplicaPreferences in turn,
+// moving on to the next when fn fails with UNAVAILABLE. Once all preferences are
+// exhausted, fn is called one last time with Spanner's default routing.
+func (c *Client) withDirectedReads(ctx context.Context, tx *spanner.TransactionSelector, fn func(*spanner.DirectedReadOptions) error) error {
+	if len(c.ReplicaPreferences) == 0 || !directedReadable(tx) {
+		return fn(nil)
+	}
+	for _, sel := range c.ReplicaPreferences {
+		err := fn(&spanner.DirectedReadOptions{
+			IncludeReplicas: &spanner.IncludeReplicas{
+				ReplicaSelections: []*spanner.ReplicaSelection{sel},
+			},
+		})
+		if ErrorCode(err) != "UNAVAILABLE" {
+			return err
+		}
+		c.logf(ctx, "replica %s %s unavailable, trying next preference", sel.Location, sel.Type)
+	}
+	return fn(nil)
+}
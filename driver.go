@@ -0,0 +1,413 @@
+package spannerr
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// DriverName is the name of the database/sql driver registered by this package.
+// Its data source names are database names, and connect with a Client made by
+// NewClient without options:
+//
+//	db, err := sql.Open(spannerr.DriverName, "projects/p/instances/i/databases/d")
+//
+// To use a configured Client, open it with sql.OpenDB(c.Connector()) instead.
+//
+// Args are passed by name with sql.Named, or by position as the params @p1, @p2,
+// ... ($1, $2, ... in the PostgreSQL dialect). "?" placeholders are rewritten to
+// positional params. Outside a sql.Tx, queries run as strong single-use reads and
+// DML in a read-write transaction of its own, retried if aborted. A sql.Tx is a
+// read-write transaction, or a strong read-only snapshot if opened with ReadOnly,
+// and is not retried if aborted. DDL is not supported, and neither is
+// LastInsertId, as Spanner has no auto-increment columns; use THEN RETURN.
+const DriverName = "spannerr"
+
+var dsnRE = regexp.MustCompile(`^projects/([^/]+)/instances/([^/]+)/databases/([^/]+)$`)
+
+func init() {
+	sql.Register(DriverName, sqlDriver{})
+}
+
+type sqlDriver struct{}
+
+// Open implements driver.Driver.
+func (d sqlDriver) Open(name string) (driver.Conn, error) {
+	cn, err := d.OpenConnector(name)
+	if err != nil {
+		return nil, err
+	}
+	return cn.Connect(context.Background())
+}
+
+// OpenConnector implements driver.DriverContext, so a sql.DB shares a single
+// Client between its connections.
+func (sqlDriver) OpenConnector(name string) (driver.Connector, error) {
+	m := dsnRE.FindStringSubmatch(name)
+	if m == nil {
+		return nil, errors.Errorf("invalid data source name %q, want projects/<p>/instances/<i>/databases/<d>", name)
+	}
+	return NewClient(m[1], m[2], m[3]).Connector(), nil
+}
+
+// Connector returns a database/sql connector whose connections use the Client
+// and share its session pool. See DriverName.
+//
+//	db := sql.OpenDB(c.Connector())
+func (c *Client) Connector() driver.Connector {
+	return sqlConnector{c}
+}
+
+type sqlConnector struct {
+	c *Client
+}
+
+func (cn sqlConnector) Connect(context.Context) (driver.Conn, error) {
+	return &sqlConn{c: cn.c}, nil
+}
+
+func (sqlConnector) Driver() driver.Driver {
+	return sqlDriver{}
+}
+
+// sqlConn is a database/sql connection. It only holds a pooled session while a
+// transaction is open on it.
+type sqlConn struct {
+	c    *Client
+	txn  *Txn
+	snap *Snapshot
+}
+
+func (c *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	return &sqlStmt{conn: c, query: query}, nil
+}
+
+func (c *sqlConn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	return c.Prepare(query)
+}
+
+func (c *sqlConn) Close() error {
+	ctx := context.Background()
+	if c.txn != nil {
+		c.txn.Rollback(ctx)
+	}
+	c.end(ctx)
+	return nil
+}
+
+func (c *sqlConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.txn != nil || c.snap != nil {
+		return nil, errors.New("transaction already open on connection")
+	}
+	switch level := sql.IsolationLevel(opts.Isolation); level {
+	case sql.LevelDefault, sql.LevelSerializable:
+	default:
+		return nil, errors.Errorf("unsupported isolation level %s", level)
+	}
+	if opts.ReadOnly {
+		snap, err := c.c.Snapshot(ctx, &spanner.ReadOnly{Strong: true})
+		if err != nil {
+			return nil, err
+		}
+		c.snap = snap
+		return sqlTx{c}, nil
+	}
+	sess, err := c.c.AcquireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	txn, err := sess.BeginReadWrite(ctx)
+	if err != nil {
+		c.c.ReleaseSession(ctx, *sess)
+		return nil, err
+	}
+	c.txn = txn
+	return sqlTx{c}, nil
+}
+
+// end releases the session of the open transaction, if any.
+func (c *sqlConn) end(ctx context.Context) {
+	if c.txn != nil {
+		c.c.ReleaseSession(ctx, *c.txn.Session)
+		c.txn = nil
+	}
+	if c.snap != nil {
+		c.snap.Close(ctx)
+		c.snap = nil
+	}
+}
+
+func (c *sqlConn) Ping(ctx context.Context) error {
+	return c.c.Connect(ctx)
+}
+
+// CheckNamedValue implements driver.NamedValueChecker, passing args through to
+// be encoded like Param values rather than converted by database/sql.
+func (c *sqlConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if rv := reflect.ValueOf(nv.Value); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		nv.Value = nil
+		return nil
+	}
+	if v, ok := nv.Value.(driver.Valuer); ok {
+		val, err := v.Value()
+		nv.Value = val
+		return err
+	}
+	return nil
+}
+
+func (c *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := c.execute(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return sqlResult{res}, nil
+}
+
+func (c *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res, err := c.execute(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlRows{res: res}, nil
+}
+
+// execute runs query in the connection's open transaction, or else as a
+// single-use read or, for DML, in a read-write transaction of its own.
+func (c *sqlConn) execute(ctx context.Context, query string, args []driver.NamedValue) (*spanner.ResultSet, error) {
+	stmt, params := bindArgs(query, args)
+	switch {
+	case c.txn != nil:
+		return c.txn.ExecuteSQL(ctx, params, stmt)
+	case c.snap != nil:
+		return c.snap.ExecuteSQL(ctx, params, stmt)
+	case isQuery(stmt):
+		sess, err := c.c.AcquireSession(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer c.c.ReleaseSession(ctx, *sess)
+		return sess.ExecuteSQL(ctx, params, stmt, QueryModeNormal, nil)
+	}
+	var res *spanner.ResultSet
+	_, err := c.c.ReadWriteTransaction(ctx, func(ctx context.Context, txn *Txn) error {
+		var err error
+		res, err = txn.ExecuteSQL(ctx, params, stmt)
+		return err
+	})
+	return res, err
+}
+
+// bindArgs returns query with any "?" placeholders rewritten to positional
+// params, along with the params for args. Positional args are named p1, p2, ...
+func bindArgs(query string, args []driver.NamedValue) (string, []*Param) {
+	params := make([]*Param, len(args))
+	for i, a := range args {
+		name := a.Name
+		if name == "" {
+			name = "p" + strconv.Itoa(a.Ordinal)
+		}
+		params[i] = valueParam(name, a.Value)
+	}
+
+	rs := []rune(query)
+	var (
+		b    strings.Builder
+		last int
+		n    int
+	)
+	for _, t := range lexSQL(query) {
+		if t.text != "?" {
+			continue
+		}
+		n++
+		b.WriteString(string(rs[last:t.pos]))
+		b.WriteString("@p" + strconv.Itoa(n))
+		last = t.end
+	}
+	if n == 0 {
+		return query, params
+	}
+	b.WriteString(string(rs[last:]))
+	return b.String(), params
+}
+
+type sqlTx struct {
+	conn *sqlConn
+}
+
+func (t sqlTx) Commit() error {
+	ctx := context.Background()
+	defer t.conn.end(ctx)
+	if t.conn.txn == nil {
+		// read-only
+		return nil
+	}
+	_, err := t.conn.txn.Commit(ctx)
+	return errors.Wrap(err, "unable to commit transaction")
+}
+
+func (t sqlTx) Rollback() error {
+	ctx := context.Background()
+	defer t.conn.end(ctx)
+	if t.conn.txn == nil {
+		return nil
+	}
+	return t.conn.txn.Rollback(ctx)
+}
+
+type sqlStmt struct {
+	conn  *sqlConn
+	query string
+}
+
+func (s *sqlStmt) Close() error {
+	return nil
+}
+
+// NumInput implements driver.Stmt. Statements aren't parsed, so the number of
+// args is not checked.
+func (s *sqlStmt) NumInput() int {
+	return -1
+}
+
+func (s *sqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *sqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func (s *sqlStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return s.conn.CheckNamedValue(nv)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	nvs := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		nvs[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return nvs
+}
+
+type sqlResult struct {
+	res *spanner.ResultSet
+}
+
+func (sqlResult) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId is not supported by Spanner, use THEN RETURN")
+}
+
+func (r sqlResult) RowsAffected() (int64, error) {
+	if r.res.Stats == nil {
+		return 0, nil
+	}
+	return r.res.Stats.RowCountExact, nil
+}
+
+type sqlRows struct {
+	res  *spanner.ResultSet
+	next int
+}
+
+func (r *sqlRows) fields() []*spanner.Field {
+	if r.res.Metadata == nil || r.res.Metadata.RowType == nil {
+		return nil
+	}
+	return r.res.Metadata.RowType.Fields
+}
+
+func (r *sqlRows) Columns() []string {
+	fields := r.fields()
+	cols := make([]string, len(fields))
+	for i, f := range fields {
+		cols[i] = f.Name
+	}
+	return cols
+}
+
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName.
+func (r *sqlRows) ColumnTypeDatabaseTypeName(i int) string {
+	return r.fields()[i].Type.Code
+}
+
+func (r *sqlRows) Close() error {
+	return nil
+}
+
+func (r *sqlRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.res.Rows) {
+		return io.EOF
+	}
+	row := r.res.Rows[r.next]
+	r.next++
+	for i, f := range r.fields() {
+		v, err := driverValue(row[i], f.Type)
+		if err != nil {
+			return errors.Wrapf(err, "unable to decode column %s", f.Name)
+		}
+		dest[i] = v
+	}
+	return nil
+}
+
+// driverValue converts a value of type t as returned by Spanner to a
+// driver.Value. DATEs are returned as midnight UTC, NUMERICs and other string
+// encoded types as strings and arrays and structs as JSON.
+func driverValue(val interface{}, t *spanner.Type) (driver.Value, error) {
+	if val == nil {
+		return nil, nil
+	}
+	var dst interface{}
+	switch TypeCode(t.Code) {
+	case TypeBool:
+		dst = new(bool)
+	case TypeInt64, TypeEnum:
+		dst = new(int64)
+	case TypeFloat32, TypeFloat64:
+		dst = new(float64)
+	case TypeBytes, TypeProto:
+		dst = new([]byte)
+	case TypeTimestamp:
+		dst = new(time.Time)
+	case TypeDate:
+		var d civil.Date
+		if err := decodeValue(val, t, reflect.ValueOf(&d).Elem()); err != nil {
+			return nil, err
+		}
+		return d.In(time.UTC), nil
+	case TypeArray, TypeStruct:
+		return json.Marshal(val)
+	default:
+		dst = new(string)
+	}
+	v := reflect.ValueOf(dst).Elem()
+	if err := decodeValue(val, t, v); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
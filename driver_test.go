@@ -0,0 +1,332 @@
+package spannerr
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+func TestBindArgs(t *testing.T) {
+	tests := []struct {
+		sql, want string
+	}{
+		{"SELECT * FROM T WHERE a = ? AND b = ?", "SELECT * FROM T WHERE a = @p1 AND b = @p2"},
+		{"SELECT '?' FROM T WHERE a = ? -- ?", "SELECT '?' FROM T WHERE a = @p1 -- ?"},
+		{"SELECT * FROM T WHERE a = @p1", "SELECT * FROM T WHERE a = @p1"},
+		{"SELECT \"é\" FROM T WHERE a=?", "SELECT \"é\" FROM T WHERE a=@p1"},
+	}
+	for _, tt := range tests {
+		if got, _ := bindArgs(tt.sql, nil); got != tt.want {
+			t.Errorf("bindArgs(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	_, params := bindArgs("", []driver.NamedValue{
+		{Ordinal: 1, Value: int64(7)},
+		{Name: "name", Ordinal: 2, Value: "x"},
+	})
+	if params[0].Name != "p1" || params[0].Type != TypeInt64 || params[1].Name != "name" || params[1].Type != TypeString {
+		t.Errorf("unexpected params %+v %+v", params[0], params[1])
+	}
+}
+
+func TestDriverValue(t *testing.T) {
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		val  interface{}
+		typ  TypeCode
+		want driver.Value
+	}{
+		{"12", TypeInt64, int64(12)},
+		{1.5, TypeFloat64, 1.5},
+		{true, TypeBool, true},
+		{"eA==", TypeBytes, []byte("x")},
+		{"2020-01-02T03:04:05Z", TypeTimestamp, ts},
+		{"2020-01-02", TypeDate, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"1.25", TypeNumeric, "1.25"},
+		{[]interface{}{"1", "2"}, TypeArray, []byte(`["1","2"]`)},
+		{nil, TypeString, nil},
+	}
+	for _, tt := range tests {
+		got, err := driverValue(tt.val, &spanner.Type{Code: string(tt.typ)})
+		if err != nil {
+			t.Errorf("driverValue(%v, %s) failed: %s", tt.val, tt.typ, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("driverValue(%v, %s) = %#v, want %#v", tt.val, tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestDriver(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		reqs []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req spanner.ExecuteSqlRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		method := path.Base(r.URL.Path)
+		method = method[strings.Index(method, ":")+1:]
+		mu.Lock()
+		reqs = append(reqs, method+" "+req.Sql)
+		mu.Unlock()
+		switch {
+		case method == "sessions":
+			w.Write([]byte(`{"name":"projects/p/instances/i/databases/d/sessions/s"}`))
+		case method == "beginTransaction":
+			w.Write([]byte(`{"id":"tx"}`))
+		case method == "commit":
+			w.Write([]byte(`{"commitTimestamp":"2020-01-01T00:00:00Z"}`))
+		case strings.HasPrefix(req.Sql, "SELECT"):
+			if req.Sql != "SELECT Name, Age FROM Users WHERE Id = @p1" || string(req.Params) != `{"p1":"7"}` {
+				t.Errorf("unexpected query %q %s", req.Sql, req.Params)
+			}
+			w.Write([]byte(`{"metadata":{"rowType":{"fields":[{"name":"Name","type":{"code":"STRING"}},{"name":"Age","type":{"code":"INT64"}}]}},"rows":[["ann","41"]]}`))
+		default:
+			w.Write([]byte(`{"stats":{"rowCountExact":"2"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient("p", "i", "d", WithEndpoint(srv.URL+"/"), WithHTTPClient(http.DefaultClient), WithMaxSessions(1))
+	db := sql.OpenDB(c.Connector())
+	defer db.Close()
+	ctx := context.Background()
+
+	var (
+		name string
+		age  int
+	)
+	if err := db.QueryRowContext(ctx, "SELECT Name, Age FROM Users WHERE Id = ?", 7).Scan(&name, &age); err != nil {
+		t.Fatal(err)
+	}
+	if name != "ann" || age != 41 {
+		t.Errorf("got %q, %d", name, age)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE Users SET Age = Age + 1 WHERE true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Errorf("got %d rows affected, want 2", n)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if st := c.PoolStats(); st.InUse != 0 {
+		t.Errorf("%d sessions still in use", st.InUse)
+	}
+
+	want := []string{
+		"sessions ",
+		"executeSql SELECT Name, Age FROM Users WHERE Id = @p1",
+		"beginTransaction ",
+		"executeSql UPDATE Users SET Age = Age + 1 WHERE true",
+		"commit ",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(reqs, want) {
+		t.Errorf("got requests %q, want %q", reqs, want)
+	}
+}
+
+// driverServer fakes the Spanner calls made through the driver, recording each
+// as its method followed by the statement or, for beginTransaction, the mode.
+type driverServer struct {
+	*httptest.Server
+	// abort reports whether the nth commit, counting from 1, is aborted.
+	abort func(n int) bool
+
+	mu      sync.Mutex
+	reqs    []string
+	commits int
+}
+
+func newDriverServer(abort func(n int) bool) *driverServer {
+	s := &driverServer{abort: abort}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Sql     string
+			Options *spanner.TransactionOptions
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		method := path.Base(r.URL.Path)
+		method = method[strings.Index(method, ":")+1:]
+		if method == "sessions" {
+			w.Write([]byte(`{"name":"projects/p/instances/i/databases/d/sessions/s"}`))
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch method {
+		case "beginTransaction":
+			if req.Options != nil && req.Options.ReadOnly != nil {
+				s.reqs = append(s.reqs, method+" ro")
+				w.Write([]byte(`{"id":"tx","readTimestamp":"2020-01-01T00:00:00Z"}`))
+				return
+			}
+			s.reqs = append(s.reqs, method+" rw")
+			w.Write([]byte(`{"id":"tx"}`))
+		case "commit":
+			s.reqs = append(s.reqs, method)
+			s.commits++
+			if s.abort != nil && s.abort(s.commits) {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"error":{"code":409,"status":"ABORTED","message":"Transaction was aborted."}}`))
+				return
+			}
+			w.Write([]byte(`{"commitTimestamp":"2020-01-01T00:00:00Z"}`))
+		case "rollback":
+			s.reqs = append(s.reqs, method)
+			w.Write([]byte(`{}`))
+		default:
+			s.reqs = append(s.reqs, method+" "+req.Sql)
+			if strings.HasPrefix(req.Sql, "SELECT") {
+				w.Write([]byte(`{"metadata":{"rowType":{"fields":[{"name":"","type":{"code":"INT64"}}]}},"rows":[["1"]]}`))
+				return
+			}
+			w.Write([]byte(`{"stats":{"rowCountExact":"1"}}`))
+		}
+	}))
+	return s
+}
+
+// requests returns and clears the recorded requests.
+func (s *driverServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reqs := s.reqs
+	s.reqs = nil
+	return reqs
+}
+
+func (s *driverServer) db(t *testing.T) (*Client, *sql.DB) {
+	c := NewClient("p", "i", "d", WithEndpoint(s.URL+"/"), WithHTTPClient(http.DefaultClient), WithMaxSessions(1))
+	c.Logf = func(context.Context, string, ...interface{}) {}
+	db := sql.OpenDB(c.Connector())
+	t.Cleanup(func() { db.Close() })
+	return c, db
+}
+
+func TestDriverTx(t *testing.T) {
+	srv := newDriverServer(nil)
+	defer srv.Close()
+	c, db := srv.db(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		opts *sql.TxOptions
+		stmt string
+		end  func(*sql.Tx) error
+		want []string
+	}{
+		{
+			name: "commit",
+			stmt: "UPDATE T SET a = 1 WHERE true",
+			end:  (*sql.Tx).Commit,
+			want: []string{"beginTransaction rw", "executeSql UPDATE T SET a = 1 WHERE true", "commit"},
+		},
+		{
+			name: "rollback",
+			stmt: "UPDATE T SET a = 1 WHERE true",
+			end:  (*sql.Tx).Rollback,
+			want: []string{"beginTransaction rw", "executeSql UPDATE T SET a = 1 WHERE true", "rollback"},
+		},
+		{
+			name: "read-only",
+			opts: &sql.TxOptions{ReadOnly: true},
+			stmt: "SELECT 1",
+			end:  (*sql.Tx).Commit,
+			want: []string{"beginTransaction ro", "executeSql SELECT 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := db.BeginTx(ctx, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tx.ExecContext(ctx, tt.stmt); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.end(tx); err != nil {
+				t.Fatal(err)
+			}
+			if got := srv.requests(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got requests %q, want %q", got, tt.want)
+			}
+			if st := c.PoolStats(); st.InUse != 0 {
+				t.Errorf("%d sessions still in use", st.InUse)
+			}
+		})
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err == nil {
+		tx.Rollback()
+		t.Error("expected an error for an unsupported isolation level")
+	}
+}
+
+func TestDriverAbort(t *testing.T) {
+	// the first commit of each test aborts
+	srv := newDriverServer(func(n int) bool { return n%2 == 1 })
+	defer srv.Close()
+	c, db := srv.db(t)
+	ctx := context.Background()
+
+	// DML outside a transaction runs in one of its own, retried if aborted
+	res, err := db.ExecContext(ctx, "UPDATE T SET a = 1 WHERE true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Errorf("got %d rows affected, want 1", n)
+	}
+	want := []string{
+		"beginTransaction rw", "executeSql UPDATE T SET a = 1 WHERE true", "commit",
+		"beginTransaction rw", "executeSql UPDATE T SET a = 1 WHERE true", "commit",
+	}
+	if got := srv.requests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got requests %q, want %q", got, want)
+	}
+
+	// a sql.Tx is not retried, and its abort is reported to the caller
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE T SET a = 2 WHERE true"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); !IsAborted(err) {
+		t.Errorf("got commit error %v, want an abort", err)
+	}
+	want = []string{"beginTransaction rw", "executeSql UPDATE T SET a = 2 WHERE true", "commit"}
+	if got := srv.requests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got requests %q, want %q", got, want)
+	}
+	if st := c.PoolStats(); st.InUse != 0 {
+		t.Errorf("%d sessions still in use", st.InUse)
+	}
+}
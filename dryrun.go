@@ -0,0 +1,76 @@
+package spannerr
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+type dryRunKey struct{}
+
+// DryRunContext returns a copy of ctx in which writes are validated and logged but
+// never applied, as if the Client had DryRun set. See Client.DryRun.
+func DryRunContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// isDryRun reports whether writes made with ctx must not be applied.
+func (c *Client) isDryRun(ctx context.Context) bool {
+	if c.DryRun {
+		return true
+	}
+	dr, _ := ctx.Value(dryRunKey{}).(bool)
+	return dr
+}
+
+// dryRunCommit validates and logs mutations in place of committing them. If the
+// commit belongs to a read-write transaction, the transaction is rolled back so
+// any DML executed within it is discarded.
+func (s *Session) dryRunCommit(ctx context.Context, mutations []*spanner.Mutation, txID string) (*spanner.CommitResponse, error) {
+	if err := validateMutations(mutations); err != nil {
+		return nil, err
+	}
+	s.client.logf(ctx, "dry run: commit not applied: %s%s",
+		strings.Join(s.client.redactMutations(mutations), "; "), s.client.metadataString(ctx))
+	if txID != "" {
+		if err := s.Rollback(ctx, txID); err != nil {
+			return nil, errors.Wrap(err, "unable to roll back dry run transaction")
+		}
+	}
+	return &spanner.CommitResponse{}, nil
+}
+
+// validateMutations checks the mutations are well formed, as far as is possible
+// without sending them to Spanner.
+func validateMutations(muts []*spanner.Mutation) error {
+	for i, m := range muts {
+		table := mutationTable(m)
+		if table == "" {
+			return errors.Errorf("mutation %d has no operation", i)
+		}
+		if _, err := quoteIdent(table); err != nil {
+			return errors.Wrapf(err, "mutation %d", i)
+		}
+		if m.Delete != nil {
+			if m.Delete.KeySet == nil {
+				return errors.Errorf("mutation %d deletes from %s without a key set", i, table)
+			}
+			continue
+		}
+		w, _ := mutationWrite(m)
+		for _, col := range w.Columns {
+			if _, err := quoteIdent(col); err != nil {
+				return errors.Wrapf(err, "mutation %d", i)
+			}
+		}
+		for j, row := range w.Values {
+			if len(row) != len(w.Columns) {
+				return errors.Errorf("mutation %d row %d of %s has %d values for %d columns",
+					i, j, table, len(row), len(w.Columns))
+			}
+		}
+	}
+	return nil
+}
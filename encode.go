@@ -0,0 +1,149 @@
+package spannerr
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// encodeValue converts a Go value into the JSON representation Spanner expects
+// for keys, mutations and parameters: INT64 values as decimal strings, BYTES as
+// base64, TIMESTAMP as RFC 3339 and NaN/infinite floats as strings.
+func encodeValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case string, bool:
+		return t
+	case []byte:
+		if t == nil {
+			return nil
+		}
+		return base64.StdEncoding.EncodeToString(t)
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case big.Rat:
+		return t.FloatString(9)
+	case *big.Rat:
+		if t == nil {
+			return nil
+		}
+		return t.FloatString(9)
+	case Interval:
+		return t.String()
+	case civil.Date:
+		return t.String()
+	case float32:
+		// format with float32 precision so 0.1 isn't sent as 0.10000000149011612
+		if !math.IsNaN(float64(t)) && !math.IsInf(float64(t), 0) {
+			return json.Number(strconv.FormatFloat(float64(t), 'g', -1, 32))
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return encodeValue(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		switch {
+		case math.IsNaN(f):
+			return "NaN"
+		case math.IsInf(f, 1):
+			return "Infinity"
+		case math.IsInf(f, -1):
+			return "-Infinity"
+		}
+		return f
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = encodeValue(rv.Index(i).Interface())
+		}
+		return out
+	case reflect.Struct:
+		fs := structFields(rv.Type())
+		out := make([]interface{}, len(fs))
+		for i, f := range fs {
+			out[i] = encodeValue(rv.FieldByIndex(f.index).Interface())
+		}
+		return out
+	}
+	return v
+}
+
+// typeCode returns the Spanner type code matching the Go type of v, or an empty
+// string if there is no obvious mapping.
+func typeCode(v interface{}) TypeCode {
+	switch v.(type) {
+	case []byte:
+		return TypeBytes
+	case time.Time, *time.Time:
+		return TypeTimestamp
+	case big.Rat, *big.Rat:
+		return TypeNumeric
+	case Interval, *Interval:
+		return TypeInterval
+	case civil.Date, *civil.Date:
+		return TypeDate
+	}
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return ""
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return TypeInt64
+	case reflect.Float32:
+		return TypeFloat32
+	case reflect.Float64:
+		return TypeFloat64
+	case reflect.String:
+		return TypeString
+	case reflect.Bool:
+		return TypeBool
+	case reflect.Slice, reflect.Array:
+		return TypeArray
+	case reflect.Struct:
+		return TypeStruct
+	}
+	return ""
+}
+
+// valueParam returns a Param for v with its type inferred from its Go type and
+// its value encoded for the Spanner API.
+func valueParam(name string, v interface{}) *Param {
+	p := &Param{Name: name, Value: encodeValue(v), Type: typeCode(v)}
+	if p.Type == TypeArray {
+		et := reflect.TypeOf(v)
+		for et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+		p.ArrayElementType = typeCode(reflect.Zero(et.Elem()).Interface())
+	}
+	return p
+}
@@ -0,0 +1,39 @@
+// Package entspannerr runs ent on Spanner through the database/sql driver of
+// package spannerr:
+//
+//	drv, err := entspannerr.Open(ctx, c)
+//	if err != nil {
+//		return err
+//	}
+//	client := ent.NewClient(ent.Driver(drv))
+//
+// ent has no Spanner dialect, so GoogleSQL databases are driven with its MySQL
+// dialect, whose backtick quoting and "?" placeholders GoogleSQL shares, and
+// PostgreSQL-dialect databases with its PostgreSQL dialect. Queries and DML work
+// as long as the SQL ent generates is valid Spanner SQL; migrations do not, as
+// the driver can't run DDL. Spanner has no auto-increment columns, so IDs must be
+// set by the application, for example with a UUID default in the ent schema.
+package entspannerr
+
+import (
+	"context"
+	"database/sql"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/jprobinson/spannerr"
+)
+
+// Open returns an ent driver for c, choosing the ent dialect matching the
+// database's dialect.
+func Open(ctx context.Context, c *spannerr.Client) (*entsql.Driver, error) {
+	f, err := c.Features(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := dialect.MySQL
+	if f.Supports(spannerr.FeaturePostgreSQL) {
+		name = dialect.Postgres
+	}
+	return entsql.OpenDB(name, sql.OpenDB(c.Connector())), nil
+}
@@ -0,0 +1,46 @@
+package entspannerr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/jprobinson/spannerr"
+)
+
+var _ dialect.Driver = (*entsql.Driver)(nil)
+
+func TestOpen(t *testing.T) {
+	for _, tt := range []struct {
+		dbDialect, want string
+	}{
+		{"GOOGLE_STANDARD_SQL", dialect.MySQL},
+		{"POSTGRESQL", dialect.Postgres},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/sessions"):
+				w.Write([]byte(`{"name":"projects/p/instances/i/databases/d/sessions/s"}`))
+			case r.Method == http.MethodDelete:
+				w.Write([]byte(`{}`))
+			default:
+				fmt.Fprintf(w, `{"rows":[[%q]]}`, tt.dbDialect)
+			}
+		}))
+		c := spannerr.NewClient("p", "i", "d", spannerr.WithEndpoint(srv.URL+"/"), spannerr.WithHTTPClient(http.DefaultClient))
+		drv, err := Open(context.Background(), c)
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := drv.Dialect(); got != tt.want {
+			t.Errorf("%s database: got ent dialect %q, want %q", tt.dbDialect, got, tt.want)
+		}
+		drv.Close()
+	}
+}
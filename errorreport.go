@@ -0,0 +1,161 @@
+package spannerr
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/pkg/errors"
+	errorreporting "google.golang.org/api/clouderrorreporting/v1beta1"
+)
+
+// Severity classifies failed calls reported to Client.OnError.
+type Severity int
+
+const (
+	// SeverityWarning is used for errors usually caused by the caller, such as
+	// invalid statements, missing rows and failed preconditions.
+	SeverityWarning Severity = iota
+	// SeverityError is used for errors caused by the environment, such as
+	// denied permissions and exhausted quota.
+	SeverityError
+	// SeverityCritical is used for errors indicating a fault in Spanner itself,
+	// such as INTERNAL and DATA_LOSS.
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "WARNING"
+	case SeverityError:
+		return "ERROR"
+	case SeverityCritical:
+		return "CRITICAL"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// ErrorReport describes a non-retryable failed Spanner call, as passed to
+// Client.OnError.
+type ErrorReport struct {
+	// Op is the operation that failed, such as "execute sql" or "commit".
+	Op string
+	// Statement is the whitespace-normalized SQL of the statement, if any.
+	// Values are passed as params, so it contains no user data.
+	Statement string
+	// Code is the canonical status of the error, such as "INVALID_ARGUMENT".
+	Code     string
+	Severity Severity
+	Err      error
+	// Metadata is the request metadata of the call, formatted with the values
+	// of sensitive keys redacted.
+	Metadata string
+	// Stack is the stack trace of the goroutine that made the call.
+	Stack []byte
+}
+
+// errorSeverity returns the severity of a non-retryable error code, and false for
+// codes that are retried or are not Spanner errors.
+func errorSeverity(code string) (Severity, bool) {
+	switch code {
+	case "", "ABORTED", "UNAVAILABLE", "DEADLINE_EXCEEDED", "RESOURCE_EXHAUSTED", "CANCELLED":
+		return 0, false
+	case "INTERNAL", "DATA_LOSS", "UNKNOWN":
+		return SeverityCritical, true
+	case "PERMISSION_DENIED", "UNAUTHENTICATED", "UNIMPLEMENTED":
+		return SeverityError, true
+	}
+	return SeverityWarning, true
+}
+
+// reportError passes a failed call to the Client's OnError hook if the error is
+// not retryable.
+func (c *Client) reportError(ctx context.Context, op, sql string, err error) {
+	if c == nil || c.OnError == nil || err == nil {
+		return
+	}
+	code := ErrorCode(err)
+	sev, ok := errorSeverity(code)
+	if !ok {
+		return
+	}
+	r := &ErrorReport{
+		Op:        op,
+		Statement: fingerprint(sql),
+		Code:      code,
+		Severity:  sev,
+		Err:       err,
+		Metadata:  c.metadataString(ctx),
+		Stack:     debug.Stack(),
+	}
+	if perr := protect(func() error {
+		c.OnError(ctx, r)
+		return nil
+	}); perr != nil {
+		c.logf(ctx, "OnError hook failed: %s", perr)
+	}
+}
+
+// ErrorReporter forwards the reports passed to Client.OnError to Cloud Error
+// Reporting:
+//
+//	r := &spannerr.ErrorReporter{Client: c, Project: "my-project", Service: "api"}
+//	c.OnError = r.Report
+type ErrorReporter struct {
+	// Client is the Client whose credentials are used to send reports.
+	Client *Client
+	// Project is the ID of the project to report errors to.
+	Project string
+	// Service and Version identify the reporting service in Error Reporting,
+	// such as the App Engine service and version.
+	Service string
+	Version string
+	// Severities lists the severities to report. If empty, every non-retryable
+	// error is reported.
+	Severities []Severity
+}
+
+// Report sends r to Error Reporting. Failures are logged through the Client's
+// logger, as there is no caller to return them to.
+func (e *ErrorReporter) Report(ctx context.Context, r *ErrorReport) {
+	if !e.reports(r.Severity) {
+		return
+	}
+	if err := e.send(ctx, r); err != nil {
+		e.Client.logf(ctx, "unable to report error: %s", err)
+	}
+}
+
+func (e *ErrorReporter) reports(sev Severity) bool {
+	if len(e.Severities) == 0 {
+		return true
+	}
+	for _, s := range e.Severities {
+		if s == sev {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *ErrorReporter) send(ctx context.Context, r *ErrorReport) error {
+	svc, err := errorreporting.New(e.Client.httpClient(errorreporting.CloudPlatformScope))
+	if err != nil {
+		return errors.Wrap(err, "unable to init error reporting service")
+	}
+	msg := fmt.Sprintf("spannerr: %s failed (%s, %s): %s", r.Op, r.Code, r.Severity, r.Err)
+	if r.Statement != "" {
+		msg += "\nstatement: " + r.Statement
+	}
+	msg += r.Metadata
+	// Error Reporting groups Go errors by the stack trace following the message
+	msg += "\n\n" + string(r.Stack)
+	_, err = svc.Projects.Events.Report("projects/"+e.Project, &errorreporting.ReportedErrorEvent{
+		EventTime:      time.Now().UTC().Format(time.RFC3339Nano),
+		Message:        msg,
+		ServiceContext: &errorreporting.ServiceContext{Service: e.Service, Version: e.Version},
+	}).Context(ctx).Do()
+	return errors.Wrap(err, "unable to send error report")
+}
@@ -0,0 +1,60 @@
+package spannerr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// ResultShapeError is returned when a query expected to produce a single value
+// returns a different number of rows or columns, or a value of the wrong type.
+type ResultShapeError struct {
+	Rows    int
+	Columns int
+	Type    string
+}
+
+func (e *ResultShapeError) Error() string {
+	return fmt.Sprintf("expected a single INT64 value, got %d row(s) of %d column(s) (type %q)",
+		e.Rows, e.Columns, e.Type)
+}
+
+// Exists reports whether a row with the given primary key exists in table. It reads
+// only the first key column of the table.
+func (s *Session) Exists(ctx context.Context, table string, key Key) (bool, error) {
+	pk, err := s.PrimaryKey(ctx, table)
+	if err != nil {
+		return false, err
+	}
+	res, err := s.readLive(ctx, table, keySet(key), pk[:1])
+	if err != nil {
+		return false, err
+	}
+	return len(res.Rows) > 0, nil
+}
+
+// Count executes a query that returns a single INT64 value, such as
+// "SELECT COUNT(*) FROM Users WHERE Age > @age", and returns that value.
+// A *ResultShapeError is returned if the query does not produce exactly one
+// INT64 value.
+func (s *Session) Count(ctx context.Context, sql string, params []*Param) (int64, error) {
+	res, err := s.ExecuteSQL(ctx, params, sql, QueryModeNormal, nil)
+	if err != nil {
+		return 0, err
+	}
+	shape := &ResultShapeError{Rows: len(res.Rows)}
+	if res.Metadata != nil && res.Metadata.RowType != nil {
+		shape.Columns = len(res.Metadata.RowType.Fields)
+		if shape.Columns > 0 && res.Metadata.RowType.Fields[0].Type != nil {
+			shape.Type = res.Metadata.RowType.Fields[0].Type.Code
+		}
+	}
+	if shape.Rows != 1 || shape.Columns != 1 || shape.Type != "INT64" {
+		return 0, errors.WithStack(shape)
+	}
+	var n int64
+	err = decodeValue(res.Rows[0][0], res.Metadata.RowType.Fields[0].Type, reflect.ValueOf(&n).Elem())
+	return n, errors.Wrap(err, "unable to decode count")
+}
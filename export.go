@@ -0,0 +1,263 @@
+package spannerr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// ExportFormat is the file format written by Export.
+type ExportFormat string
+
+const (
+	// ExportJSON writes newline delimited JSON objects, one per row, keyed by
+	// column name. Values use Spanner's JSON encoding, which BigQuery accepts.
+	ExportJSON ExportFormat = "json"
+	// ExportCSV writes CSV with a header row. ARRAY and STRUCT values are written
+	// as JSON.
+	ExportCSV ExportFormat = "csv"
+)
+
+func (f ExportFormat) contentType() string {
+	if f == ExportCSV {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+// exportConcurrency is the number of partitions Export uploads at once.
+const exportConcurrency = 8
+
+// ExportManifest describes the files written by Export. It is also written
+// alongside them as manifest.json.
+type ExportManifest struct {
+	SQL           string       `json:"sql"`
+	ReadTimestamp string       `json:"read_timestamp"`
+	Format        ExportFormat `json:"format"`
+	Files         []ExportFile `json:"files"`
+}
+
+// ExportFile is a single file written by Export.
+type ExportFile struct {
+	URI  string `json:"uri"`
+	Rows int64  `json:"rows"`
+}
+
+// Export partitions the given query and writes each partition to its own Cloud
+// Storage object under gcsPrefix (e.g. "gs://bucket/exports/users/"), several at
+// a time, followed by a manifest listing every file. All files are read from the
+// same snapshot, so together they form a consistent export that can be loaded
+// into BigQuery with a wildcard URI.
+func (c *Client) Export(ctx context.Context, sql string, params []*Param, gcsPrefix string, format ExportFormat) (*ExportManifest, error) {
+	if format != ExportJSON && format != ExportCSV {
+		return nil, errors.Errorf("invalid export format %q", string(format))
+	}
+	bucket, prefix, err := parseGCSPrefix(gcsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	gcs, err := storage.New(c.httpClient(storage.DevstorageReadWriteScope))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to init storage service")
+	}
+
+	q, err := c.PartitionQuery(ctx, sql, params, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.CloseBatch(ctx, q)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		manifest = &ExportManifest{
+			SQL:           sql,
+			ReadTimestamp: q.ReadTimestamp,
+			Format:        format,
+			Files:         make([]ExportFile, len(q.Tokens)),
+		}
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, exportConcurrency)
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i, token := range q.Tokens {
+		name := fmt.Sprintf("%spart-%05d-of-%05d.%s", prefix, i, len(q.Tokens), format)
+		manifest.Files[i].URI = "gs://" + bucket + "/" + name
+		wg.Add(1)
+		go func(i int, token, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+			rows, err := c.exportPartition(ctx, gcs, q, token, bucket, name, format)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = errors.Wrapf(err, "unable to export partition %d", i)
+					cancel()
+				})
+				return
+			}
+			manifest.Files[i].Rows = rows
+		}(i, token, name)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encode manifest")
+	}
+	_, err = gcs.Objects.Insert(bucket, &storage.Object{
+		Name:        prefix + "manifest.json",
+		ContentType: "application/json",
+	}).Media(bytes.NewReader(b)).Context(ctx).Do()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to write manifest")
+	}
+	return manifest, nil
+}
+
+// exportPartition streams the rows of a single partition into a Cloud Storage
+// object, returning the number of rows written.
+func (c *Client) exportPartition(ctx context.Context, gcs *storage.Service, q *BatchQuery, token, bucket, name string, format ExportFormat) (int64, error) {
+	it, err := c.ExecutePartition(ctx, q, token, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+
+	var (
+		rows   int64
+		pr, pw = io.Pipe()
+		werrc  = make(chan error, 1)
+	)
+	go func() {
+		err := writeRows(pw, it, format, &rows)
+		pw.CloseWithError(err)
+		werrc <- err
+	}()
+	_, err = gcs.Objects.Insert(bucket, &storage.Object{
+		Name:        name,
+		ContentType: format.contentType(),
+	}).Media(pr).Context(ctx).Do()
+	// unblock the writer if the upload stopped early
+	pr.CloseWithError(errors.New("upload stopped"))
+	if werr := <-werrc; werr != nil {
+		return 0, werr
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to upload export file")
+	}
+	return rows, nil
+}
+
+// writeRows writes every row of it to w in the given format.
+func writeRows(w io.Writer, it *RowIterator, format ExportFormat, n *int64) error {
+	bw := bufio.NewWriter(w)
+	cw := csv.NewWriter(bw)
+	var (
+		names  []string
+		header = func() error {
+			if names != nil || it.Metadata() == nil {
+				return nil
+			}
+			names = []string{}
+			for _, f := range it.Metadata().RowType.Fields {
+				names = append(names, f.Name)
+			}
+			if format == ExportCSV {
+				return cw.Write(names)
+			}
+			return nil
+		}
+	)
+	for it.Next() {
+		if err := header(); err != nil {
+			return errors.Wrap(err, "unable to write header")
+		}
+		row := it.Row()
+		switch format {
+		case ExportCSV:
+			rec := make([]string, len(row))
+			for i, v := range row {
+				s, err := csvValue(v)
+				if err != nil {
+					return err
+				}
+				rec[i] = s
+			}
+			if err := cw.Write(rec); err != nil {
+				return errors.Wrap(err, "unable to write row")
+			}
+		default:
+			obj := make(map[string]interface{}, len(row))
+			for i, v := range row {
+				obj[names[i]] = v
+			}
+			b, err := json.Marshal(obj)
+			if err != nil {
+				return errors.Wrap(err, "unable to encode row")
+			}
+			bw.Write(b)
+			bw.WriteByte('\n')
+		}
+		*n++
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if err := header(); err != nil {
+		return errors.Wrap(err, "unable to write header")
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return errors.Wrap(err, "unable to write rows")
+	}
+	return errors.Wrap(bw.Flush(), "unable to write rows")
+}
+
+func csvValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), errors.Wrap(err, "unable to encode value")
+}
+
+// parseGCSPrefix splits a gs:// URI into its bucket and an object name prefix
+// ending in "/", or empty for the bucket root.
+func parseGCSPrefix(uri string) (bucket, prefix string, err error) {
+	if !strings.HasPrefix(uri, "gs://") {
+		return "", "", errors.Errorf("invalid cloud storage prefix %q", uri)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(uri, "gs://"), "/", 2)
+	if parts[0] == "" {
+		return "", "", errors.Errorf("invalid cloud storage prefix %q", uri)
+	}
+	if len(parts) == 2 && parts[1] != "" {
+		prefix = strings.TrimSuffix(parts[1], "/") + "/"
+	}
+	return parts[0], prefix, nil
+}
@@ -0,0 +1,323 @@
+package spannerr
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// maxFakeCells bounds the number of cells written by each commit of FakeData.Load,
+// well under Spanner's per-commit mutation limit.
+const maxFakeCells = 20000
+
+// FakeData generates realistic fake rows for the tables of a database schema and
+// loads them, for load testing and seeding staging environments. Values are
+// generated according to each column's type, with column names such as "Email" or
+// "Country" producing plausible values. Primary keys are unique and interleaved
+// tables get rows under each of their parent rows. Foreign keys and check
+// constraints are not taken into account.
+type FakeData struct {
+	Client *Client
+	// Rows is the number of rows generated for each root table.
+	Rows int
+	// ChildRows is the number of rows generated per parent row for each
+	// interleaved table.
+	ChildRows int
+	// Rand is the source of randomness. If nil, one seeded with the current
+	// time is used.
+	Rand *rand.Rand
+	// Generators overrides the values generated for specific columns, keyed by
+	// "Table.Column". Values are Go values as accepted by the mutation builders.
+	Generators map[string]func(r *rand.Rand) interface{}
+	// Now returns the time that generated DATE and TIMESTAMP values precede.
+	// If nil, time.Now is used. Set it and Rand for reproducible data.
+	Now func() time.Time
+}
+
+// Load generates rows for the given tables, which must be ordered with parents
+// first as returned by Client.Schema, and writes them to the database. If tables
+// is empty the whole schema is loaded.
+func (f *FakeData) Load(ctx context.Context, tables ...*TableSchema) error {
+	if len(tables) == 0 {
+		var err error
+		if tables, err = f.Client.Schema(ctx); err != nil {
+			return err
+		}
+	}
+	rows, err := f.Generate(tables)
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		var (
+			batch []*spanner.Mutation
+			cells int
+		)
+		for _, m := range rows[t.Name] {
+			batch = append(batch, m)
+			cells += len(m.Insert.Columns)
+			if cells >= maxFakeCells {
+				if err := f.Client.Apply(ctx, batch...); err != nil {
+					return errors.Wrapf(err, "unable to load %q", t.Name)
+				}
+				batch, cells = nil, 0
+			}
+		}
+		if len(batch) > 0 {
+			if err := f.Client.Apply(ctx, batch...); err != nil {
+				return errors.Wrapf(err, "unable to load %q", t.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Generate returns insert mutations for fake rows of each table, keyed by table
+// name. Tables must be ordered with parents first.
+func (f *FakeData) Generate(tables []*TableSchema) (map[string][]*spanner.Mutation, error) {
+	r := f.Rand
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	now := time.Now()
+	if f.Now != nil {
+		now = f.Now()
+	}
+	var (
+		out  = map[string][]*spanner.Mutation{}
+		keys = map[string][]map[string]interface{}{}
+	)
+	for _, t := range tables {
+		parents := []map[string]interface{}{nil}
+		n := f.Rows
+		if t.Parent != "" {
+			var ok bool
+			if parents, ok = keys[t.Parent]; !ok {
+				return nil, errors.Errorf("parent table %q of %q must precede it", t.Parent, t.Name)
+			}
+			n = f.ChildRows
+		}
+		for _, parent := range parents {
+			for i := 0; i < n; i++ {
+				row, err := f.row(r, now, t, parent, i)
+				if err != nil {
+					return nil, err
+				}
+				key := map[string]interface{}{}
+				for _, k := range t.PrimaryKey {
+					key[k] = row[k]
+				}
+				keys[t.Name] = append(keys[t.Name], key)
+
+				w := &spanner.Write{Table: t.Name, Values: [][]interface{}{nil}}
+				for _, c := range t.Columns {
+					if v, ok := row[c.Name]; ok {
+						w.Columns = append(w.Columns, c.Name)
+						w.Values[0] = append(w.Values[0], encodeValue(v))
+					}
+				}
+				out[t.Name] = append(out[t.Name], &spanner.Mutation{Insert: w})
+			}
+		}
+	}
+	return out, nil
+}
+
+// row generates the values of a single row. Key columns shared with the parent
+// are copied from it and the remaining key columns are made unique using seq.
+func (f *FakeData) row(r *rand.Rand, now time.Time, t *TableSchema, parent map[string]interface{}, seq int) (map[string]interface{}, error) {
+	row := map[string]interface{}{}
+	for _, c := range t.Columns {
+		if c.Generated {
+			continue
+		}
+		if v, ok := parent[c.Name]; ok {
+			row[c.Name] = v
+			continue
+		}
+		if gen, ok := f.Generators[t.Name+"."+c.Name]; ok {
+			row[c.Name] = gen(r)
+			continue
+		}
+		if isKeyColumn(t, c.Name) {
+			v, err := fakeKey(r, c.Type, seq)
+			if err != nil {
+				return nil, errors.Wrapf(err, "column %s.%s", t.Name, c.Name)
+			}
+			row[c.Name] = v
+			continue
+		}
+		if !c.NotNull && r.Intn(10) == 0 {
+			continue
+		}
+		v, err := fakeValue(r, now, c.Name, c.Type)
+		if err != nil {
+			return nil, errors.Wrapf(err, "column %s.%s", t.Name, c.Name)
+		}
+		row[c.Name] = v
+	}
+	return row, nil
+}
+
+func isKeyColumn(t *TableSchema, name string) bool {
+	for _, k := range t.PrimaryKey {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeKey returns a unique key value of the given type for sequence number seq.
+func fakeKey(r *rand.Rand, typ string, seq int) (interface{}, error) {
+	switch baseType(typ) {
+	case "INT64":
+		// bit reversing the sequence keeps keys unique while spreading them
+		// across the key space to avoid hotspots
+		return BitReverse(int64(seq)), nil
+	case "STRING", "BYTES":
+		k := fakeHex(r, 16)
+		if max := typeLength(typ); max > 0 && len(k) > max {
+			// too short for random keys to stay unique; use the sequence
+			if k = strconv.FormatInt(int64(seq), 36); len(k) > max {
+				return nil, errors.Errorf("key %d does not fit in %s", seq, typ)
+			}
+		}
+		if baseType(typ) == "BYTES" {
+			return []byte(k), nil
+		}
+		return k, nil
+	case "TIMESTAMP":
+		return time.Unix(0, 0).Add(time.Duration(seq) * time.Second).Add(time.Duration(r.Int63n(int64(time.Second)))), nil
+	case "DATE":
+		return time.Unix(0, 0).AddDate(0, 0, seq).Format("2006-01-02"), nil
+	}
+	return nil, errors.Errorf("unsupported key type %q", typ)
+}
+
+var (
+	fakeFirstNames = []string{"Ada", "Grace", "Alan", "Edsger", "Barbara", "Ken", "Margaret", "Linus", "Radia", "Dennis"}
+	fakeLastNames  = []string{"Lovelace", "Hopper", "Turing", "Dijkstra", "Liskov", "Thompson", "Hamilton", "Torvalds", "Perlman", "Ritchie"}
+	fakeCountries  = []string{"US", "GB", "DE", "FR", "JP", "BR", "IN", "CA", "AU", "NG"}
+	fakeCities     = []string{"New York", "London", "Berlin", "Paris", "Tokyo", "São Paulo", "Mumbai", "Toronto", "Sydney", "Lagos"}
+	fakeWords      = []string{"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do"}
+)
+
+// fakeValue returns a plausible value for a column based on its name and type.
+// Generated dates and timestamps precede now.
+func fakeValue(r *rand.Rand, now time.Time, name, typ string) (interface{}, error) {
+	if strings.HasPrefix(strings.ToUpper(typ), "ARRAY<") {
+		elem := typ[len("ARRAY<") : len(typ)-1]
+		out := make([]interface{}, r.Intn(4))
+		for i := range out {
+			v, err := fakeValue(r, now, name, elem)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+	pick := func(vals []string) string { return vals[r.Intn(len(vals))] }
+	lname := strings.ToLower(name)
+	switch baseType(typ) {
+	case "BOOL":
+		return r.Intn(2) == 0, nil
+	case "INT64":
+		if strings.Contains(lname, "age") {
+			return int64(18 + r.Intn(70)), nil
+		}
+		return r.Int63n(1000000), nil
+	case "FLOAT64", "FLOAT32":
+		if strings.Contains(lname, "lat") {
+			return r.Float64()*180 - 90, nil
+		}
+		if strings.Contains(lname, "lon") || strings.Contains(lname, "lng") {
+			return r.Float64()*360 - 180, nil
+		}
+		return r.Float64() * 1000, nil
+	case "NUMERIC":
+		return strconv.FormatFloat(r.Float64()*10000, 'f', 2, 64), nil
+	case "DATE":
+		return now.AddDate(0, 0, -r.Intn(3650)).Format("2006-01-02"), nil
+	case "TIMESTAMP":
+		return now.Add(-time.Duration(r.Int63n(int64(365 * 24 * time.Hour)))), nil
+	case "BYTES":
+		n := 16
+		if max := typeLength(typ); max > 0 && max < n {
+			n = max
+		}
+		b := make([]byte, n)
+		r.Read(b)
+		return b, nil
+	case "JSON":
+		return fmt.Sprintf(`{"%s":%d}`, pick(fakeWords), r.Intn(100)), nil
+	case "STRING":
+		var s string
+		switch {
+		case strings.Contains(lname, "email"):
+			s = strings.ToLower(pick(fakeFirstNames)+"."+pick(fakeLastNames)) + strconv.Itoa(r.Intn(1000)) + "@example.com"
+		case strings.Contains(lname, "firstname") || strings.Contains(lname, "first_name"):
+			s = pick(fakeFirstNames)
+		case strings.Contains(lname, "lastname") || strings.Contains(lname, "last_name"):
+			s = pick(fakeLastNames)
+		case strings.Contains(lname, "name"):
+			s = pick(fakeFirstNames) + " " + pick(fakeLastNames)
+		case strings.Contains(lname, "phone"):
+			s = fmt.Sprintf("+1-555-%03d-%04d", r.Intn(1000), r.Intn(10000))
+		case strings.Contains(lname, "country"):
+			s = pick(fakeCountries)
+		case strings.Contains(lname, "city"):
+			s = pick(fakeCities)
+		case strings.Contains(lname, "url"):
+			s = "https://example.com/" + pick(fakeWords) + "/" + fakeHex(r, 4)
+		case strings.HasSuffix(lname, "id"):
+			s = fakeHex(r, 16)
+		default:
+			words := make([]string, 1+r.Intn(6))
+			for i := range words {
+				words[i] = pick(fakeWords)
+			}
+			s = strings.Join(words, " ")
+		}
+		// STRING lengths count characters, not bytes
+		if max := typeLength(typ); max > 0 && utf8.RuneCountInString(s) > max {
+			s = string([]rune(s)[:max])
+		}
+		return s, nil
+	}
+	return nil, errors.Errorf("unsupported column type %q", typ)
+}
+
+// baseType returns the type without its length, e.g. "STRING" for "STRING(MAX)".
+func baseType(typ string) string {
+	if i := strings.IndexByte(typ, '('); i >= 0 {
+		typ = typ[:i]
+	}
+	return strings.ToUpper(typ)
+}
+
+// typeLength returns the declared length of a STRING or BYTES type, in characters
+// or bytes respectively, or 0 for MAX.
+func typeLength(typ string) int {
+	i := strings.IndexByte(typ, '(')
+	if i < 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSuffix(typ[i+1:], ")"))
+	return n
+}
+
+func fakeHex(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	r.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,72 @@
+package spannerr
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// FanOutResult is the outcome of a statement run on one database by FanOut.
+type FanOutResult struct {
+	// Database is the ID of the database.
+	Database string
+	// Rows is a pointer to a slice, of the same type as the dst passed to FanOut,
+	// holding the rows returned by the database.
+	Rows interface{}
+	// Err is the error returned by the database, if any.
+	Err error
+}
+
+// FanOut runs the same statement on the database of each Client, such as one per
+// tenant, at most concurrency at a time and each using its own Client's pooled
+// sessions. The rows of every database are appended to dst, a pointer to a slice
+// of structs, in the order of clients, and also returned per database. All
+// databases are queried even if some fail; the returned error is that of the
+// first failed database, in the order of clients.
+func FanOut(ctx context.Context, clients []*Client, concurrency int, sql string, params []*Param, dst interface{}) ([]*FanOutResult, error) {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.Elem().Kind() != reflect.Slice {
+		return nil, errors.Errorf("destination must be a pointer to a slice, got %T", dst)
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var (
+		results = make([]*FanOutResult, len(clients))
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, concurrency)
+	)
+	for i, c := range clients {
+		results[i] = &FanOutResult{Database: c.databaseID()}
+		wg.Add(1)
+		go func(c *Client, res *FanOutResult) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			rows := reflect.New(dv.Elem().Type())
+			if err := ctx.Err(); err != nil {
+				res.Err = err
+				return
+			}
+			res.Err = c.Query(ctx, sql, params, rows.Interface())
+			res.Rows = rows.Interface()
+		}(c, results[i])
+	}
+	wg.Wait()
+
+	var firstErr error
+	out := dv.Elem()
+	for _, res := range results {
+		if res.Err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(res.Err, "unable to query database %q", res.Database)
+			}
+			continue
+		}
+		out = reflect.AppendSlice(out, reflect.ValueOf(res.Rows).Elem())
+	}
+	dv.Elem().Set(out)
+	return results, firstErr
+}
@@ -0,0 +1,172 @@
+package spannerr
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Feature is a database capability that helpers depend on.
+type Feature string
+
+const (
+	// FeatureGoogleSQL is the GoogleSQL dialect, which helpers generating SQL
+	// write.
+	FeatureGoogleSQL Feature = "the GoogleSQL dialect"
+	// FeaturePostgreSQL is the PostgreSQL dialect.
+	FeaturePostgreSQL Feature = "the PostgreSQL dialect"
+	// FeatureSequences are sequences, see NextSequenceValues.
+	FeatureSequences Feature = "sequences"
+	// FeatureSearchIndexes are search indexes and full-text search functions,
+	// see Search.
+	FeatureSearchIndexes Feature = "search indexes"
+	// FeatureChangeStreams are change streams, see ChangeStream.
+	FeatureChangeStreams Feature = "change streams"
+)
+
+// Features describes the capabilities of a database, as detected by
+// Client.Features.
+type Features struct {
+	// Dialect is "GOOGLE_STANDARD_SQL" or "POSTGRESQL".
+	Dialect       string `json:"dialect"`
+	Sequences     bool   `json:"sequences"`
+	SearchIndexes bool   `json:"search_indexes"`
+	ChangeStreams bool   `json:"change_streams"`
+}
+
+// Supports reports whether the database supports feat.
+func (f *Features) Supports(feat Feature) bool {
+	switch feat {
+	case FeatureGoogleSQL:
+		return f.Dialect != "POSTGRESQL"
+	case FeaturePostgreSQL:
+		return f.Dialect == "POSTGRESQL"
+	case FeatureSequences:
+		return f.Sequences
+	case FeatureSearchIndexes:
+		return f.SearchIndexes
+	case FeatureChangeStreams:
+		return f.ChangeStreams
+	}
+	return false
+}
+
+// UnsupportedFeatureError is returned by helpers that depend on a feature the
+// database does not support, instead of the INVALID_ARGUMENT error Spanner would
+// return for the statements they generate.
+type UnsupportedFeatureError struct {
+	Feature  Feature
+	Database string
+	Dialect  string
+}
+
+func (e *UnsupportedFeatureError) Error() string {
+	return "database " + e.Database + " (" + e.Dialect + ") does not support " + string(e.Feature)
+}
+
+// featureProbes are statements that fail with a "not found" or "does not exist"
+// error on databases without a feature, keyed by dialect. Other errors mean the
+// feature exists.
+var featureProbes = map[Feature]map[string]string{
+	FeatureSequences: {
+		"GOOGLE_STANDARD_SQL": "SELECT 1 FROM INFORMATION_SCHEMA.SEQUENCES LIMIT 1",
+		"POSTGRESQL":          "SELECT 1 FROM information_schema.sequences LIMIT 1",
+	},
+	FeatureSearchIndexes: {
+		"GOOGLE_STANDARD_SQL": "SELECT TOKENIZE_FULLTEXT('probe') IS NULL",
+		"POSTGRESQL":          "SELECT spanner.tokenize_fulltext('probe') IS NULL",
+	},
+	FeatureChangeStreams: {
+		"GOOGLE_STANDARD_SQL": "SELECT 1 FROM INFORMATION_SCHEMA.CHANGE_STREAMS LIMIT 1",
+		"POSTGRESQL":          "SELECT 1 FROM information_schema.change_streams LIMIT 1",
+	},
+}
+
+// Features returns the capabilities of the Client's database, detected with a
+// few probing queries on a dedicated session the first time it is called and
+// cached afterwards, so helpers can fail fast with an *UnsupportedFeatureError
+// on older databases or the emulator.
+func (c *Client) Features(ctx context.Context) (*Features, error) {
+	c.featMu.Lock()
+	defer c.featMu.Unlock()
+	if c.features != nil {
+		return c.features, nil
+	}
+	sess, err := c.newSession(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to detect database features")
+	}
+	defer sess.rpc(ctx).Delete(sess.name).Context(ctx).Do()
+
+	f := &Features{Dialect: "GOOGLE_STANDARD_SQL"}
+	// unquoted identifiers match in both dialects
+	res, err := sess.rpc(ctx).ExecuteSql(sess.name, &spanner.ExecuteSqlRequest{
+		Sql: "SELECT OPTION_VALUE FROM INFORMATION_SCHEMA.DATABASE_OPTIONS WHERE OPTION_NAME = 'database_dialect'",
+	}).Context(ctx).Do()
+	// databases predating the option are GoogleSQL
+	if ok, err := probeResult(err); err != nil {
+		return nil, errors.Wrap(err, "unable to detect database dialect")
+	} else if ok && len(res.Rows) > 0 && len(res.Rows[0]) > 0 {
+		if d, _ := res.Rows[0][0].(string); d == "POSTGRESQL" {
+			f.Dialect = d
+		}
+	}
+	for feat, probes := range featureProbes {
+		_, err := sess.rpc(ctx).ExecuteSql(sess.name, &spanner.ExecuteSqlRequest{
+			Sql:       probes[f.Dialect],
+			QueryMode: string(QueryModePlan),
+		}).Context(ctx).Do()
+		supported, err := probeResult(err)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to detect support for %s", feat)
+		}
+		switch feat {
+		case FeatureSequences:
+			f.Sequences = supported
+		case FeatureSearchIndexes:
+			f.SearchIndexes = supported
+		case FeatureChangeStreams:
+			f.ChangeStreams = supported
+		}
+	}
+	c.features = f
+	return f, nil
+}
+
+// probeResult interprets the error of a feature probe.
+func probeResult(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	if ErrorCode(err) != "INVALID_ARGUMENT" {
+		return false, err
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "not found") || strings.Contains(msg, "does not exist") {
+		return false, nil
+	}
+	// the feature exists, but the probe isn't valid use of it
+	return true, nil
+}
+
+// requireFeatures returns an *UnsupportedFeatureError for the first of feats the
+// database does not support. If the features can't be detected the failure is
+// logged and nil returned, leaving Spanner to reject the call if need be.
+func (c *Client) requireFeatures(ctx context.Context, feats ...Feature) error {
+	if c == nil {
+		return nil
+	}
+	f, err := c.Features(ctx)
+	if err != nil {
+		c.logf(ctx, "%s", err)
+		return nil
+	}
+	for _, feat := range feats {
+		if !f.Supports(feat) {
+			return errors.WithStack(&UnsupportedFeatureError{Feature: feat, Database: c.conn, Dialect: f.Dialect})
+		}
+	}
+	return nil
+}
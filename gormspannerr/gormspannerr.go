@@ -0,0 +1,119 @@
+// Package gormspannerr is a GORM dialector for GoogleSQL Spanner databases,
+// running on the database/sql driver of package spannerr:
+//
+//	db, err := gorm.Open(gormspannerr.New(c), &gorm.Config{})
+//
+// Migrations are not supported, as the driver can't run DDL; apply schema
+// changes with the database admin API. Spanner has no auto-increment columns, so
+// primary keys must be set by the application, for example to UUIDs.
+package gormspannerr
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jprobinson/spannerr"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// Dialector is a gorm.Dialector for Spanner.
+type Dialector struct {
+	// Client is the Client connections are made with.
+	Client *spannerr.Client
+	// Conn, if set, is used instead of a connection pool opened on Client, such
+	// as a *sql.DB opened with spannerr.DriverName.
+	Conn gorm.ConnPool
+}
+
+// New returns a Dialector for c.
+func New(c *spannerr.Client) gorm.Dialector {
+	return &Dialector{Client: c}
+}
+
+// Name implements gorm.Dialector.
+func (Dialector) Name() string {
+	return "spanner"
+}
+
+// Initialize implements gorm.Dialector.
+func (d Dialector) Initialize(db *gorm.DB) error {
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{
+		CreateClauses: []string{"INSERT", "VALUES"},
+		UpdateClauses: []string{"UPDATE", "SET", "WHERE"},
+		DeleteClauses: []string{"DELETE", "FROM", "WHERE"},
+	})
+	db.ConnPool = d.Conn
+	if db.ConnPool == nil {
+		db.ConnPool = sql.OpenDB(d.Client.Connector())
+	}
+	return nil
+}
+
+// Migrator implements gorm.Dialector. Its schema changes fail, see the package
+// documentation.
+func (d Dialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return migrator.Migrator{Config: migrator.Config{DB: db, Dialector: d}}
+}
+
+// DataTypeOf implements gorm.Dialector.
+func (Dialector) DataTypeOf(field *schema.Field) string {
+	size := func() string {
+		if field.Size > 0 {
+			return strconv.Itoa(field.Size)
+		}
+		return "MAX"
+	}
+	switch field.DataType {
+	case schema.Bool:
+		return "BOOL"
+	case schema.Int, schema.Uint:
+		return "INT64"
+	case schema.Float:
+		return "FLOAT64"
+	case schema.String:
+		return fmt.Sprintf("STRING(%s)", size())
+	case schema.Bytes:
+		return fmt.Sprintf("BYTES(%s)", size())
+	case schema.Time:
+		return "TIMESTAMP"
+	}
+	return string(field.DataType)
+}
+
+// DefaultValueOf implements gorm.Dialector.
+func (Dialector) DefaultValueOf(*schema.Field) clause.Expression {
+	return clause.Expr{SQL: "DEFAULT"}
+}
+
+// BindVarTo implements gorm.Dialector, binding each var to a positional param.
+func (Dialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	writer.WriteString("@p")
+	writer.WriteString(strconv.Itoa(len(stmt.Vars)))
+}
+
+// QuoteTo implements gorm.Dialector.
+func (Dialector) QuoteTo(writer clause.Writer, str string) {
+	for i, part := range strings.Split(str, ".") {
+		if i > 0 {
+			writer.WriteByte('.')
+		}
+		writer.WriteByte('`')
+		writer.WriteString(strings.ReplaceAll(part, "`", "\\`"))
+		writer.WriteByte('`')
+	}
+}
+
+var paramRE = regexp.MustCompile(`@p(\d+)`)
+
+// Explain implements gorm.Dialector.
+func (Dialector) Explain(sql string, vars ...interface{}) string {
+	return logger.ExplainSQL(sql, paramRE, `'`, vars...)
+}
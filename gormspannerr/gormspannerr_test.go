@@ -0,0 +1,61 @@
+package gormspannerr
+
+import (
+	"testing"
+
+	"github.com/jprobinson/spannerr"
+	"gorm.io/gorm"
+)
+
+var _ gorm.Dialector = Dialector{}
+
+type singer struct {
+	ID   int64 `gorm:"primaryKey"`
+	Name string
+}
+
+func TestStatements(t *testing.T) {
+	c := spannerr.NewClient("p", "i", "d")
+	db, err := gorm.Open(New(c), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		run  func(*gorm.DB) *gorm.DB
+		want string
+	}{
+		{
+			name: "query",
+			run:  func(db *gorm.DB) *gorm.DB { return db.Where("name = ?", "ann").Find(&[]singer{}) },
+			want: "SELECT * FROM `singers` WHERE name = @p1",
+		},
+		{
+			name: "insert",
+			run:  func(db *gorm.DB) *gorm.DB { return db.Create(&singer{ID: 1, Name: "ann"}) },
+			want: "INSERT INTO `singers` (`name`,`id`) VALUES (@p1,@p2)",
+		},
+		{
+			name: "update",
+			run:  func(db *gorm.DB) *gorm.DB { return db.Model(&singer{ID: 1}).Update("name", "bo") },
+			want: "UPDATE `singers` SET `name`=@p1 WHERE `id` = @p2",
+		},
+		{
+			name: "delete",
+			run:  func(db *gorm.DB) *gorm.DB { return db.Delete(&singer{ID: 1}) },
+			want: "DELETE FROM `singers` WHERE `singers`.`id` = @p1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.run(db.Session(&gorm.Session{}))
+			if res.Error != nil {
+				t.Fatal(res.Error)
+			}
+			if got := res.Statement.SQL.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
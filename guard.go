@@ -0,0 +1,95 @@
+package spannerr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ConcurrentUseError is returned when a Session, or a Txn on it, is used by one
+// goroutine while a call from another is still in flight. Spanner sessions
+// execute one request at a time, so concurrent use fails or interleaves
+// unpredictably on the server; use a session per goroutine instead.
+type ConcurrentUseError struct {
+	Session string
+	// Op is the call that was rejected.
+	Op string
+	// InUseBy is the call in flight and Since how long it has been running.
+	InUseBy string
+	Since   time.Duration
+}
+
+func (e *ConcurrentUseError) Error() string {
+	return fmt.Sprintf("session %s used concurrently: %s rejected while %s has been in flight for %s; "+
+		"sessions and transactions must not be shared between goroutines", e.Session, e.Op, e.InUseBy,
+		e.Since.Round(time.Millisecond))
+}
+
+// sessionGuard tracks the call in flight on a session. It is shared by copies of
+// a Session, such as the one passed to ReleaseSession.
+type sessionGuard struct {
+	mu    sync.Mutex
+	op    string
+	since time.Time
+	// background is set while the call in flight is made by this package, such
+	// as a transaction heartbeat, which callers wait for instead of failing.
+	background bool
+	done       chan struct{}
+}
+
+type sharedSessionKey struct{}
+
+// sharedSession returns a copy of ctx whose calls may run concurrently on a
+// session, for the reads of read-only transactions, which Spanner allows.
+func sharedSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sharedSessionKey{}, true)
+}
+
+// enter marks op as in flight on the session until the returned function is
+// called, returning a *ConcurrentUseError if another call already is.
+func (s *Session) enter(ctx context.Context, op string) (exit func(), err error) {
+	return s.guardCall(ctx, op, false)
+}
+
+// enterBackground is enter for calls made by this package in the background.
+func (s *Session) enterBackground(ctx context.Context, op string) (exit func(), err error) {
+	return s.guardCall(ctx, op, true)
+}
+
+func (s *Session) guardCall(ctx context.Context, op string, background bool) (func(), error) {
+	g := s.guard
+	if shared, _ := ctx.Value(sharedSessionKey{}).(bool); g == nil || shared {
+		return func() {}, nil
+	}
+	for {
+		g.mu.Lock()
+		if g.op == "" {
+			g.op, g.since, g.background = op, time.Now(), background
+			done := make(chan struct{})
+			g.done = done
+			g.mu.Unlock()
+			var once sync.Once
+			return func() {
+				once.Do(func() {
+					g.mu.Lock()
+					g.op = ""
+					close(done)
+					g.mu.Unlock()
+				})
+			}, nil
+		}
+		if background || !g.background {
+			err := &ConcurrentUseError{Session: s.name, Op: op, InUseBy: g.op, Since: time.Since(g.since)}
+			g.mu.Unlock()
+			return nil, err
+		}
+		done := g.done
+		g.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package spannerr
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Hint is a Cloud Spanner query hint. Hints should be constructed with the
+// functions below and rendered with FormatHints, StatementHints or TableHints.
+// More details can be found here: https://cloud.google.com/spanner/docs/reference/standard-sql/query-syntax#sql_syntax
+type Hint struct {
+	Key   string
+	Value string
+}
+
+// hintValues lists the valid hint keys along with their allowed values. A nil
+// value list allows any valid identifier.
+var hintValues = map[string][]string{
+	"FORCE_INDEX":                  nil,
+	"GROUPBY_SCAN_OPTIMIZATION":    {"TRUE", "FALSE"},
+	"SCAN_METHOD":                  {"AUTO", "BATCH", "ROW"},
+	"INDEX_STRATEGY":               {"FORCE_INDEX_UNION"},
+	"JOIN_METHOD":                  {"HASH_JOIN", "APPLY_JOIN", "MERGE_JOIN", "PUSH_BROADCAST_HASH_JOIN"},
+	"FORCE_JOIN_ORDER":             {"TRUE", "FALSE"},
+	"HASH_JOIN_BUILD_SIDE":         {"BUILD_LEFT", "BUILD_RIGHT"},
+	"BATCH_MODE":                   {"TRUE", "FALSE"},
+	"OPTIMIZER_VERSION":            nil,
+	"OPTIMIZER_STATISTICS_PACKAGE": nil,
+	"USE_ADDITIONAL_PARALLELISM":   {"TRUE", "FALSE"},
+	"LOCK_SCANNED_RANGES":          {"exclusive", "shared"},
+	"ALLOW_DISTRIBUTED_MERGE":      {"TRUE", "FALSE"},
+}
+
+// ForceIndex returns a table hint forcing the use of the given secondary index.
+// Use "_BASE_TABLE" to force a scan of the base table.
+func ForceIndex(index string) Hint { return Hint{Key: "FORCE_INDEX", Value: index} }
+
+// JoinMethod returns a join hint selecting the join algorithm, such as "HASH_JOIN"
+// or "APPLY_JOIN".
+func JoinMethod(method string) Hint { return Hint{Key: "JOIN_METHOD", Value: method} }
+
+// HashJoinBuildSide returns a join hint selecting the build side of a hash join,
+// either "BUILD_LEFT" or "BUILD_RIGHT".
+func HashJoinBuildSide(side string) Hint { return Hint{Key: "HASH_JOIN_BUILD_SIDE", Value: side} }
+
+// ForceJoinOrder returns a hint that forces the join order written in the query.
+func ForceJoinOrder() Hint { return Hint{Key: "FORCE_JOIN_ORDER", Value: "TRUE"} }
+
+// ScanMethod returns a table hint selecting the scan method, one of "AUTO",
+// "BATCH" or "ROW".
+func ScanMethod(method string) Hint { return Hint{Key: "SCAN_METHOD", Value: method} }
+
+// OptimizerVersion returns a statement hint pinning the query optimizer version.
+func OptimizerVersion(v int) Hint {
+	return Hint{Key: "OPTIMIZER_VERSION", Value: strconv.Itoa(v)}
+}
+
+// UseAdditionalParallelism returns a statement hint allowing the query to use
+// additional parallelism.
+func UseAdditionalParallelism() Hint {
+	return Hint{Key: "USE_ADDITIONAL_PARALLELISM", Value: "TRUE"}
+}
+
+func (h Hint) validate() error {
+	allowed, ok := hintValues[h.Key]
+	if !ok {
+		return errors.Errorf("unknown hint %q", h.Key)
+	}
+	if allowed == nil {
+		if identRE.MatchString(h.Value) || isDigits(h.Value) {
+			return nil
+		}
+		return errors.Errorf("invalid value %q for hint %s", h.Value, h.Key)
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(a, h.Value) {
+			return nil
+		}
+	}
+	return errors.Errorf("invalid value %q for hint %s, expected one of %s",
+		h.Value, h.Key, strings.Join(allowed, ", "))
+}
+
+// FormatHints validates the given hints and renders them in Spanner's
+// "@{KEY=VALUE,...}" syntax. It returns an empty string if no hints are given.
+func FormatHints(hints ...Hint) (string, error) {
+	if len(hints) == 0 {
+		return "", nil
+	}
+	parts := make([]string, len(hints))
+	for i, h := range hints {
+		if err := h.validate(); err != nil {
+			return "", err
+		}
+		parts[i] = h.Key + "=" + h.Value
+	}
+	return "@{" + strings.Join(parts, ",") + "}", nil
+}
+
+// StatementHints prefixes sql with the given statement hints.
+func StatementHints(sql string, hints ...Hint) (string, error) {
+	hs, err := FormatHints(hints...)
+	if err != nil || hs == "" {
+		return sql, err
+	}
+	return hs + " " + sql, nil
+}
+
+// TableHints returns the quoted table name followed by the given table hints,
+// for use in a FROM clause (e.g. "FROM " + TableHints("Singers", ForceIndex("SingersByName"))).
+func TableHints(table string, hints ...Hint) (string, error) {
+	qt, err := quoteIdent(table)
+	if err != nil {
+		return "", err
+	}
+	hs, err := FormatHints(hints...)
+	return qt + hs, err
+}
+
+// JoinHints returns a JOIN keyword followed by the given join hints, for
+// use between two table expressions (e.g. "JOIN@{JOIN_METHOD=HASH_JOIN}").
+func JoinHints(hints ...Hint) (string, error) {
+	hs, err := FormatHints(hints...)
+	return "JOIN" + hs, err
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
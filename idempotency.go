@@ -0,0 +1,79 @@
+package spannerr
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ErrDuplicateRequest is returned by Idempotency.Do when the request ID has
+// already been applied.
+var ErrDuplicateRequest = errors.New("request already applied")
+
+// Idempotency makes writes safe to retry by recording a request ID in a dedupe
+// table in the same transaction as the write, so retried handlers and task queue
+// deliveries apply each write at most once.
+//
+// The dedupe table is expected to have the following schema, with a row deletion
+// policy longer than any request is retried for:
+//
+//	CREATE TABLE IdempotencyKeys (
+//		RequestId STRING(MAX) NOT NULL,
+//		CreatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+//	) PRIMARY KEY (RequestId),
+//	  ROW DELETION POLICY (OLDER_THAN(CreatedAt, INTERVAL 7 DAY))
+type Idempotency struct {
+	Client *Client
+	// Table is the name of the dedupe table.
+	Table string
+}
+
+// idempotencyKey is a row in the dedupe table.
+type idempotencyKey struct {
+	RequestID string `spanner:"RequestId,pk"`
+}
+
+// Do runs fn in a read-write transaction that also records requestID. If
+// requestID has already been recorded, fn is not run and ErrDuplicateRequest is
+// returned. The transaction is run by Client.ReadWriteTransaction, so a
+// concurrent delivery of the same request that aborts this one is retried, finds
+// the recorded key and returns ErrDuplicateRequest; fn must be safe to run more
+// than once. The transaction is passed to fn and carried by its ctx, so
+// Client.Query and Client.Apply participate in it. If ctx already carries a
+// transaction it is used instead and left for the caller to commit.
+func (i *Idempotency) Do(ctx context.Context, requestID string, fn func(ctx context.Context, txn *Txn) error) error {
+	_, err := i.Client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *Txn) error {
+		return i.do(ctx, txn, requestID, fn)
+	})
+	if ErrorCode(err) == "ALREADY_EXISTS" {
+		// a concurrent attempt recorded the request first
+		return ErrDuplicateRequest
+	}
+	return err
+}
+
+func (i *Idempotency) do(ctx context.Context, txn *Txn, requestID string, fn func(context.Context, *Txn) error) error {
+	qt, err := quoteIdent(i.Table)
+	if err != nil {
+		return err
+	}
+	// reading the key within the transaction locks it, so concurrent attempts
+	// are serialized
+	res, err := txn.ExecuteSQL(ctx, []*Param{{Name: "id", Value: requestID, Type: TypeString}},
+		"SELECT 1 FROM "+qt+" WHERE RequestId = @id")
+	if err != nil {
+		return errors.Wrap(err, "unable to check request id")
+	}
+	if len(res.Rows) > 0 {
+		return ErrDuplicateRequest
+	}
+	if err := protect(func() error { return fn(ctx, txn) }); err != nil {
+		return err
+	}
+	m, err := Conventions{CreatedAt: "CreatedAt"}.Insert(i.Table, &idempotencyKey{RequestID: requestID})
+	if err != nil {
+		return err
+	}
+	txn.BufferWrite(m)
+	return nil
+}
@@ -0,0 +1,18 @@
+package spannerr
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+var identRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// quoteIdent validates the given table, column or index name and returns it quoted
+// for safe inclusion in SQL.
+func quoteIdent(name string) (string, error) {
+	if !identRE.MatchString(name) {
+		return "", errors.Errorf("invalid identifier %q", name)
+	}
+	return "`" + name + "`", nil
+}
@@ -0,0 +1,98 @@
+package spannerr
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+)
+
+// IDSource generates the random values behind NewUUID, NewUUIDBytes and auto key
+// columns. Tests can install a deterministic IDSource with SetIDSource so the
+// keys they write are reproducible.
+type IDSource interface {
+	// UUIDBytes returns the 16 bytes of a new UUID. The version and variant
+	// bits are overwritten by the caller.
+	UUIDBytes() []byte
+	// Int64Key returns a new positive INT64 key.
+	Int64Key() int64
+}
+
+var (
+	idMu  sync.RWMutex
+	idSrc IDSource = randomIDs{}
+)
+
+// SetIDSource replaces the package's IDSource, returning a func that restores the
+// previous one. It is meant for tests; a nil src restores the default source of
+// cryptographically random values.
+//
+// The source is process-wide, as auto keys are populated by the package-level
+// mutation builders, so it affects every Client and goroutine. Tests that call
+// SetIDSource must not run in parallel with each other or with tests that depend
+// on random keys, and should not call t.Parallel.
+//
+//	defer spannerr.SetIDSource(&spannerr.SequentialIDs{})()
+func SetIDSource(src IDSource) (restore func()) {
+	if src == nil {
+		src = randomIDs{}
+	}
+	idMu.Lock()
+	prev := idSrc
+	idSrc = src
+	idMu.Unlock()
+	return func() {
+		idMu.Lock()
+		idSrc = prev
+		idMu.Unlock()
+	}
+}
+
+func currentIDSource() IDSource {
+	idMu.RLock()
+	defer idMu.RUnlock()
+	return idSrc
+}
+
+// randomIDs is the default IDSource.
+type randomIDs struct{}
+
+func (randomIDs) UUIDBytes() []byte {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return b
+}
+
+func (randomIDs) Int64Key() int64 {
+	var b [8]byte
+	rand.Read(b[:])
+	return int64(binary.BigEndian.Uint64(b[:]) >> 1)
+}
+
+// SequentialIDs is an IDSource generating keys from a counter, starting at 1, so
+// tests get the same keys on every run. INT64 keys are bit reversed, as they are
+// by a bit_reversed_positive sequence, and UUIDs hold the counter in their last
+// eight bytes. It is safe for concurrent use, although concurrent callers get
+// keys in no particular order.
+type SequentialIDs struct {
+	mu sync.Mutex
+	n  int64
+}
+
+func (s *SequentialIDs) next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n++
+	return s.n
+}
+
+// UUIDBytes implements IDSource.
+func (s *SequentialIDs) UUIDBytes() []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b[8:], uint64(s.next()))
+	return b
+}
+
+// Int64Key implements IDSource.
+func (s *SequentialIDs) Int64Key() int64 {
+	return BitReverse(s.next())
+}
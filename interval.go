@@ -0,0 +1,164 @@
+package spannerr
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Interval is a Spanner INTERVAL value. Its parts are kept separate, as in
+// Spanner, because the length of a month or day depends on the timestamp it is
+// applied to.
+type Interval struct {
+	Months int32
+	Days   int32
+	// Nanoseconds is the time part of the interval. Intervals whose time part
+	// exceeds about 292 years can't be represented.
+	Nanoseconds int64
+}
+
+// String returns the interval in the ISO 8601 duration format used by Spanner,
+// e.g. "P1Y2M3DT4H5M6.5S".
+func (iv Interval) String() string {
+	var b strings.Builder
+	b.WriteString("P")
+	part := func(n int64, unit byte) {
+		if n != 0 {
+			b.WriteString(strconv.FormatInt(n, 10))
+			b.WriteByte(unit)
+		}
+	}
+	part(int64(iv.Months/12), 'Y')
+	part(int64(iv.Months%12), 'M')
+	part(int64(iv.Days), 'D')
+	if ns := iv.Nanoseconds; ns != 0 {
+		b.WriteString("T")
+		h := ns / int64(time.Hour)
+		ns -= h * int64(time.Hour)
+		m := ns / int64(time.Minute)
+		ns -= m * int64(time.Minute)
+		part(h, 'H')
+		part(m, 'M')
+		if ns != 0 {
+			if ns < 0 {
+				b.WriteString("-")
+				ns = -ns
+			}
+			b.WriteString(strconv.FormatInt(ns/int64(time.Second), 10))
+			if frac := ns % int64(time.Second); frac != 0 {
+				b.WriteString(".")
+				b.WriteString(strings.TrimRight(strconv.FormatInt(frac+int64(time.Second), 10)[1:], "0"))
+			}
+			b.WriteString("S")
+		}
+	}
+	if b.Len() == 1 {
+		return "P0Y"
+	}
+	return b.String()
+}
+
+// ParseInterval parses an interval in the ISO 8601 duration format used by
+// Spanner, where each part may carry its own sign, e.g. "P1Y-2M3DT-4H5.25S".
+func ParseInterval(s string) (Interval, error) {
+	var iv Interval
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return iv, errors.Errorf("invalid interval %q", s)
+	}
+	var (
+		rest    = s[1:]
+		inTime  bool
+		months  int64
+		days    int64
+		nanos   int64
+		checked = func(v, max int64) bool { return v <= max && v >= -max }
+	)
+	for rest != "" {
+		if rest[0] == 'T' {
+			if inTime {
+				return iv, errors.Errorf("invalid interval %q", s)
+			}
+			inTime = true
+			rest = rest[1:]
+			continue
+		}
+		i := 0
+		if rest[0] == '-' || rest[0] == '+' {
+			i++
+		}
+		for i < len(rest) && (rest[i] >= '0' && rest[i] <= '9' || rest[i] == '.' || rest[i] == ',') {
+			i++
+		}
+		if i == len(rest) {
+			return iv, errors.Errorf("invalid interval %q: missing unit", s)
+		}
+		num, unit := strings.Replace(rest[:i], ",", ".", 1), rest[i]
+		rest = rest[i+1:]
+		if unit == 'S' && inTime {
+			d, err := parseSeconds(num)
+			if err != nil {
+				return iv, errors.Wrapf(err, "invalid interval %q", s)
+			}
+			nanos += d
+			continue
+		}
+		n, err := strconv.ParseInt(num, 10, 64)
+		if err != nil {
+			return iv, errors.Wrapf(err, "invalid interval %q", s)
+		}
+		switch {
+		case !inTime && unit == 'Y':
+			months += n * 12
+		case !inTime && unit == 'M':
+			months += n
+		case !inTime && unit == 'W':
+			days += n * 7
+		case !inTime && unit == 'D':
+			days += n
+		case inTime && unit == 'H':
+			nanos += n * int64(time.Hour)
+		case inTime && unit == 'M':
+			nanos += n * int64(time.Minute)
+		default:
+			return iv, errors.Errorf("invalid interval %q: unexpected unit %c", s, unit)
+		}
+	}
+	if !checked(months, 1<<31-1) || !checked(days, 1<<31-1) {
+		return iv, errors.Errorf("interval %q out of range", s)
+	}
+	return Interval{Months: int32(months), Days: int32(days), Nanoseconds: nanos}, nil
+}
+
+// parseSeconds parses a decimal number of seconds with up to nine fractional
+// digits into nanoseconds.
+func parseSeconds(s string) (int64, error) {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if len(frac) > 9 {
+		return 0, errors.Errorf("more than nanosecond precision in %q", s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	secs, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	var ns int64
+	if frac != "" {
+		if ns, err = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64); err != nil {
+			return 0, err
+		}
+	}
+	total := secs*int64(time.Second) + ns
+	if neg {
+		total = -total
+	}
+	return total, nil
+}
@@ -0,0 +1,76 @@
+package spannerr
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// KeepAlive pings the Client's idle pooled sessions every interval until the
+// returned function is called, so that Spanner doesn't delete them after an hour
+// of inactivity and the pool doesn't evict them. Sessions found to have expired
+// anyway are dropped and replaced, so AcquireSession never hands out a dead
+// session. It also renews the Client's SessionRegistry leases. An interval of a
+// few minutes to half an hour is typical, shorter than any registry lease, and a
+// non-positive interval defaults to ten minutes; failures are logged through the
+// Client's logger.
+//
+// It suits instances with background work enabled, such as App Engine flexible
+// or manual scaling. Elsewhere, run Maintain from cron; see MaintenanceHandler.
+func (c *Client) KeepAlive(ctx context.Context, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		tick := time.NewTicker(tickInterval(interval, 10*time.Minute))
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+			_, dead, err := c.pingIdle(ctx)
+			if err != nil {
+				c.logf(ctx, "unable to keep sessions alive: %s", err)
+			}
+			if dead > 0 {
+				c.replenish(ctx, dead)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
+
+// tickInterval returns interval, or def if interval is not positive, as
+// time.NewTicker panics on non-positive intervals.
+func tickInterval(interval, def time.Duration) time.Duration {
+	if interval <= 0 {
+		return def
+	}
+	return interval
+}
+
+// replenish creates up to n idle sessions to replace expired ones, without
+// growing the pool past its maximum size.
+func (c *Client) replenish(ctx context.Context, n int) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	for i := 0; i < n && len(c.sessions) < c.maxSessions; i++ {
+		sess, err := c.createSession(ctx)
+		if err != nil {
+			if err != ErrSessionCap {
+				c.logf(ctx, "unable to replace expired session: %s", err)
+			}
+			return
+		}
+		c.sessions[sess.name].inUse = false
+		c.sessions[sess.name].lastUsed = time.Now().UTC()
+	}
+}
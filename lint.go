@@ -0,0 +1,170 @@
+package spannerr
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// LintMode controls how a Client treats SQL containing inline literals.
+type LintMode int
+
+const (
+	// LintOff disables SQL linting. This is the default.
+	LintOff LintMode = iota
+	// LintWarn logs any suspicious literals but still executes the statement.
+	LintWarn
+	// LintStrict rejects statements containing suspicious literals with a *LintError.
+	LintStrict
+)
+
+// LintError is returned in LintStrict mode when a SQL statement contains a literal
+// value or pattern that should be passed in as a Param instead.
+type LintError struct {
+	// SQL is the offending statement.
+	SQL string
+	// Literal is the offending piece of the statement.
+	Literal string
+	// Reason describes why the literal was flagged.
+	Reason string
+}
+
+func (e *LintError) Error() string {
+	return e.Reason + " (" + e.Literal + "); use a Param instead"
+}
+
+// LintSQL inspects the given SQL for interpolated literals and obvious injection
+// patterns. Any literals found in the allow list (compared exactly as written in
+// the SQL, including quotes) are ignored. It returns nil if nothing suspicious was found.
+func LintSQL(sql string, allow ...string) *LintError {
+	allowed := func(lit string) bool {
+		for _, a := range allow {
+			if a == lit {
+				return true
+			}
+		}
+		return false
+	}
+	var (
+		rs        = []rune(sql)
+		lastToken string
+	)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '@' && i+1 < len(rs) && rs[i+1] == '{':
+			// skip over query hints
+			for i < len(rs) && rs[i] != '}' {
+				i++
+			}
+			lastToken = "}"
+		case r == '\'' || r == '"':
+			end := endOfQuoted(rs, i)
+			lit := string(rs[i:end])
+			i = end - 1
+			if allowed(lit) {
+				lastToken = lit
+				continue
+			}
+			return &LintError{SQL: sql, Literal: lit, Reason: "string literal in SQL"}
+		case r == '`':
+			end := endOfQuoted(rs, i)
+			lastToken = string(rs[i:end])
+			i = end - 1
+		case r == '-' && i+1 < len(rs) && rs[i+1] == '-',
+			r == '/' && i+1 < len(rs) && rs[i+1] == '*',
+			r == '#':
+			return &LintError{SQL: sql, Literal: string(rs[i:]), Reason: "comment in SQL"}
+		case r == ';':
+			if rest := strings.TrimSpace(string(rs[i+1:])); rest != "" {
+				return &LintError{SQL: sql, Literal: rest, Reason: "multiple statements in SQL"}
+			}
+		case unicode.IsDigit(r) && !isIdentRune(prevRune(rs, i)):
+			end := i
+			for end < len(rs) && (isIdentRune(rs[end]) || rs[end] == '.') {
+				end++
+			}
+			lit := string(rs[i:end])
+			i = end - 1
+			if isComparison(lastToken) && !allowed(lit) {
+				return &LintError{SQL: sql, Literal: lit, Reason: "numeric literal in comparison"}
+			}
+			lastToken = lit
+		case isIdentRune(r):
+			end := i
+			for end < len(rs) && isIdentRune(rs[end]) {
+				end++
+			}
+			lastToken = string(rs[i:end])
+			i = end - 1
+		case strings.ContainsRune("=<>!", r):
+			end := i
+			for end < len(rs) && strings.ContainsRune("=<>!", rs[end]) {
+				end++
+			}
+			lastToken = string(rs[i:end])
+			i = end - 1
+		case unicode.IsSpace(r):
+		default:
+			lastToken = string(r)
+		}
+	}
+	return nil
+}
+
+// lint runs LintSQL according to the Client's LintMode.
+func (c *Client) lint(ctx context.Context, sql string) error {
+	if c == nil || c.LintMode == LintOff {
+		return nil
+	}
+	lerr := LintSQL(sql, c.LintAllow...)
+	if lerr == nil {
+		return nil
+	}
+	if c.LintMode == LintStrict {
+		return errors.WithStack(lerr)
+	}
+	c.logf(ctx, "sql lint: %s: %q", lerr, sql)
+	return nil
+}
+
+// endOfQuoted returns the index just past the closing quote of the quoted section
+// starting at i, handling triple quotes and backslash escapes.
+func endOfQuoted(rs []rune, i int) int {
+	q := rs[i]
+	delim := []rune{q}
+	if i+2 < len(rs) && rs[i+1] == q && rs[i+2] == q {
+		delim = []rune{q, q, q}
+	}
+	for j := i + len(delim); j < len(rs); j++ {
+		if rs[j] == '\\' {
+			j++
+			continue
+		}
+		if j+len(delim) <= len(rs) && string(rs[j:j+len(delim)]) == string(delim) {
+			return j + len(delim)
+		}
+	}
+	return len(rs)
+}
+
+func prevRune(rs []rune, i int) rune {
+	if i == 0 {
+		return ' '
+	}
+	return rs[i-1]
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '@' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isComparison(tok string) bool {
+	switch strings.ToUpper(tok) {
+	case "=", "!=", "<>", "<", ">", "<=", ">=", "LIKE", "IN":
+		return true
+	}
+	return false
+}
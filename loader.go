@@ -0,0 +1,153 @@
+package spannerr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Loader coalesces point lookups by a single key column that occur within a short
+// window into one "IN UNNEST" query, handing each caller back its own row. This is
+// the dataloader pattern, and is particularly useful for GraphQL-style resolvers
+// that look up many rows one at a time.
+type Loader struct {
+	client   *Client
+	table    string
+	key      string
+	columns  []string
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	pending *loaderBatch
+}
+
+type loaderBatch struct {
+	ctx   context.Context
+	keys  []interface{}
+	seen  map[string]bool
+	done  chan struct{}
+	rows  map[string][]interface{}
+	field []*spanner.Field
+	err   error
+}
+
+// NewLoader returns a Loader reading the given columns from table, looking rows up
+// by the key column. Lookups are batched for up to wait, or until maxBatch keys are
+// pending.
+func NewLoader(c *Client, table, keyColumn string, columns []string, wait time.Duration, maxBatch int) *Loader {
+	cols := columns
+	var hasKey bool
+	for _, col := range columns {
+		if strings.EqualFold(col, keyColumn) {
+			hasKey = true
+		}
+	}
+	if !hasKey {
+		cols = append([]string{keyColumn}, columns...)
+	}
+	return &Loader{client: c, table: table, key: keyColumn, columns: cols, wait: wait, maxBatch: maxBatch}
+}
+
+// Load looks up the row with the given key and decodes it into dst, a pointer to a
+// struct. ErrRowNotFound is returned if no row exists. The batched query runs with
+// the context of the first caller in the batch.
+func (l *Loader) Load(ctx context.Context, key interface{}, dst interface{}) error {
+	k := loaderKey(key)
+	l.mu.Lock()
+	b := l.pending
+	if b == nil {
+		b = &loaderBatch{ctx: ctx, seen: map[string]bool{}, done: make(chan struct{})}
+		l.pending = b
+		time.AfterFunc(l.wait, func() { l.flush(b) })
+	}
+	if !b.seen[k] {
+		b.seen[k] = true
+		b.keys = append(b.keys, key)
+	}
+	full := l.maxBatch > 0 && len(b.keys) >= l.maxBatch
+	l.mu.Unlock()
+	if full {
+		l.flush(b)
+	}
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if b.err != nil {
+		return b.err
+	}
+	row, ok := b.rows[k]
+	if !ok {
+		return ErrRowNotFound
+	}
+	return errors.Wrap(decodeStruct(b.field, row, dst), "unable to decode row")
+}
+
+// flush executes the batch if it is still pending.
+func (l *Loader) flush(b *loaderBatch) {
+	l.mu.Lock()
+	if l.pending != b {
+		l.mu.Unlock()
+		return
+	}
+	l.pending = nil
+	l.mu.Unlock()
+
+	b.err = l.run(b)
+	close(b.done)
+}
+
+func (l *Loader) run(b *loaderBatch) error {
+	qt, err := quoteIdent(l.table)
+	if err != nil {
+		return err
+	}
+	qk, err := quoteIdent(l.key)
+	if err != nil {
+		return err
+	}
+	cols := make([]string, len(l.columns))
+	keyIdx := -1
+	for i, c := range l.columns {
+		if cols[i], err = quoteIdent(c); err != nil {
+			return err
+		}
+		if strings.EqualFold(c, l.key) {
+			keyIdx = i
+		}
+	}
+	keys := valueParam("keys", b.keys)
+	keys.ArrayElementType = typeCode(b.keys[0])
+
+	sess, err := l.client.AcquireSession(b.ctx)
+	if err != nil {
+		return err
+	}
+	defer l.client.ReleaseSession(b.ctx, *sess)
+	res, err := sess.ExecuteSQL(b.ctx, []*Param{keys},
+		"SELECT "+strings.Join(cols, ", ")+" FROM "+qt+" WHERE "+qk+" IN UNNEST(@keys)", QueryModeNormal, nil)
+	if err != nil {
+		return err
+	}
+	if res.Metadata != nil && res.Metadata.RowType != nil {
+		b.field = res.Metadata.RowType.Fields
+	}
+	b.rows = make(map[string][]interface{}, len(res.Rows))
+	for _, row := range res.Rows {
+		b.rows[fmt.Sprint(row[keyIdx])] = row
+	}
+	return nil
+}
+
+// loaderKey returns the string form of the key as it appears in query results.
+func loaderKey(key interface{}) string {
+	return fmt.Sprint(encodeValue(key))
+}
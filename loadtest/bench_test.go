@@ -0,0 +1,120 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jprobinson/spannerr"
+	"github.com/jprobinson/spannerr/spannerrtest"
+)
+
+// BenchmarkSimulate simulates 200 QPS of 30ms queries, steady and in bursts,
+// against pools of several sizes that wait up to 100ms for a session, reporting
+// the reject rate, peak sessions in use and mean wait of each.
+func BenchmarkSimulate(b *testing.B) {
+	patterns := []struct {
+		name string
+		p    Pattern
+	}{
+		{"steady", Steady{QPS: 200}},
+		{"bursty", Bursty{Steady: Steady{QPS: 100}, Size: 100, Every: time.Second, Spread: 100 * time.Millisecond}},
+	}
+	for _, pt := range patterns {
+		for _, max := range []int{5, 10, 20, 40} {
+			b.Run(fmt.Sprintf("%s/max=%d", pt.name, max), func(b *testing.B) {
+				cfg := PoolSim{
+					MaxSessions:    max,
+					Wait:           true,
+					AcquireTimeout: 100 * time.Millisecond,
+					Latency:        30 * time.Millisecond,
+					LatencyStdDev:  10 * time.Millisecond,
+					CreateLatency:  50 * time.Millisecond,
+					Duration:       time.Minute,
+				}
+				var rejects, peak, wait float64
+				for i := 0; i < b.N; i++ {
+					cfg.Seed = int64(i)
+					res := Simulate(pt.p, cfg)
+					rejects += res.RejectRate()
+					peak += float64(res.PeakInUse)
+					wait += float64(res.MeanWait) / float64(time.Millisecond)
+				}
+				b.ReportMetric(rejects/float64(b.N), "reject-rate")
+				b.ReportMetric(peak/float64(b.N), "peak-sessions")
+				b.ReportMetric(wait/float64(b.N), "wait-ms")
+			})
+		}
+	}
+}
+
+// BenchmarkPool acquires a session, runs a query on it and releases it from many
+// goroutines at once, against a fake Spanner holding each query for a
+// millisecond, with and without faults injected by spannerrtest.FaultTransport.
+func BenchmarkPool(b *testing.B) {
+	cases := []struct {
+		max  int
+		wait bool
+		rate float64
+	}{
+		{max: 10},
+		{max: 10, wait: true},
+		{max: 10, wait: true, rate: 0.05},
+		{max: 50, wait: true},
+	}
+	for _, bc := range cases {
+		name := fmt.Sprintf("max=%d/wait=%t/faults=%g", bc.max, bc.wait, bc.rate)
+		b.Run(name, func(b *testing.B) {
+			srv := fakeSpanner(time.Millisecond)
+			defer srv.Close()
+			ft := &spannerrtest.FaultTransport{Base: http.DefaultTransport, Rate: bc.rate}
+			c := spannerr.NewClient("p", "i", "d",
+				spannerr.WithEndpoint(srv.URL+"/"),
+				spannerr.WithHTTPClient(&http.Client{Transport: ft}),
+				spannerr.WithMaxSessions(bc.max))
+			c.WaitForSession = bc.wait
+			c.Logf = func(context.Context, string, ...interface{}) {}
+			ctx := context.Background()
+
+			var failed int64
+			b.SetParallelism(4)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					sess, err := c.AcquireSession(ctx)
+					if err != nil {
+						atomic.AddInt64(&failed, 1)
+						continue
+					}
+					if _, err := sess.ExecuteSQL(ctx, nil, "SELECT 1", spannerr.QueryModeNormal, nil); err != nil {
+						atomic.AddInt64(&failed, 1)
+					}
+					c.ReleaseSession(ctx, *sess)
+				}
+			})
+			b.ReportMetric(float64(failed)/float64(b.N), "errors/op")
+		})
+	}
+}
+
+// fakeSpanner serves the session and query calls a Client makes, holding each
+// query for latency.
+func fakeSpanner(latency time.Duration) *httptest.Server {
+	var n int64
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/sessions"):
+			fmt.Fprintf(w, `{"name":"projects/p/instances/i/databases/d/sessions/s%d"}`, atomic.AddInt64(&n, 1))
+		case r.Method == http.MethodDelete:
+			w.Write([]byte(`{}`))
+		default:
+			time.Sleep(latency)
+			w.Write([]byte(`{"metadata":{"rowType":{"fields":[{"name":"","type":{"code":"INT64"}}]}},"rows":[["1"]]}`))
+		}
+	}))
+}
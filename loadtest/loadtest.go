@@ -0,0 +1,264 @@
+// Package loadtest replays a weighted mix of statements against a Cloud Spanner
+// database through a spannerr.Client at a fixed rate, reporting latency
+// percentiles and an error breakdown. It is useful for validating session pool
+// sizing and instance capacity before deploying.
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jprobinson/spannerr"
+	"github.com/pkg/errors"
+)
+
+// Statement is a statement in the replayed mix.
+type Statement struct {
+	// Name identifies the statement in the Report.
+	Name string
+	SQL  string
+	// Params, if set, returns the params for each execution so that runs can
+	// vary their keys. It must be safe for concurrent use.
+	Params func(r *rand.Rand) []*spannerr.Param
+	// Weight is the relative frequency of the statement in the mix. It
+	// defaults to 1.
+	Weight int
+	// ReadWrite executes the statement in a read-write transaction, as
+	// required for DML.
+	ReadWrite bool
+}
+
+// Config configures a load test.
+type Config struct {
+	Client *spannerr.Client
+	Mix    []*Statement
+	// QPS is the target rate of statement executions per second.
+	QPS float64
+	// Concurrency is the maximum number of executions in flight. Executions
+	// that would exceed it are skipped and counted in Report.Skipped.
+	Concurrency int
+	// Duration is how long to run the test.
+	Duration time.Duration
+}
+
+// Report summarizes a load test.
+type Report struct {
+	Duration time.Duration
+	// Requests is the number of executions attempted.
+	Requests int
+	// Skipped is the number of executions not attempted because Concurrency
+	// executions were already in flight, meaning the target QPS was not met.
+	Skipped int
+	Latency Latency
+	// Errors counts failed executions by Spanner error code, or "CLIENT" for
+	// errors raised before reaching Spanner, such as an exhausted session pool.
+	Errors      map[string]int
+	ByStatement map[string]*StatementReport
+}
+
+// StatementReport summarizes the executions of a single statement.
+type StatementReport struct {
+	Requests int
+	Errors   int
+	Latency  Latency
+}
+
+// Latency holds latency percentiles of successful executions.
+type Latency struct {
+	P50, P90, P99, Max time.Duration
+}
+
+// Run executes the statement mix until cfg.Duration elapses or ctx is done.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.Client == nil || len(cfg.Mix) == 0 {
+		return nil, errors.New("a client and statement mix are required")
+	}
+	if cfg.QPS <= 0 || cfg.Concurrency <= 0 {
+		return nil, errors.New("qps and concurrency must be positive")
+	}
+	var (
+		weights []int
+		total   int
+	)
+	for _, st := range cfg.Mix {
+		w := st.Weight
+		if w <= 0 {
+			w = 1
+		}
+		total += w
+		weights = append(weights, total)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	var (
+		rec     = newRecorder()
+		sem     = make(chan struct{}, cfg.Concurrency)
+		wg      sync.WaitGroup
+		r       = rand.New(rand.NewSource(time.Now().UnixNano()))
+		ticker  = time.NewTicker(time.Duration(float64(time.Second) / cfg.QPS))
+		start   = time.Now()
+		skipped int
+	)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+		}
+		n := r.Intn(total)
+		st := cfg.Mix[sort.SearchInts(weights, n+1)]
+		var params []*spannerr.Param
+		if st.Params != nil {
+			params = st.Params(r)
+		}
+		select {
+		case sem <- struct{}{}:
+		default:
+			skipped++
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			t := time.Now()
+			err := execute(ctx, cfg.Client, st, params)
+			if ctx.Err() != nil && err != nil {
+				// don't count executions cut off by the end of the test
+				return
+			}
+			rec.record(st.Name, time.Since(t), err)
+		}()
+	}
+	wg.Wait()
+	rep := rec.report()
+	rep.Duration = time.Since(start)
+	rep.Skipped = skipped
+	return rep, nil
+}
+
+func execute(ctx context.Context, c *spannerr.Client, st *Statement, params []*spannerr.Param) error {
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.ReleaseSession(ctx, *sess)
+	if !st.ReadWrite {
+		_, err = sess.ExecuteSQL(ctx, params, st.SQL, spannerr.QueryModeNormal, nil)
+		return err
+	}
+	txn, err := sess.BeginReadWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := txn.ExecuteSQL(ctx, params, st.SQL); err != nil {
+		txn.Rollback(ctx)
+		return err
+	}
+	_, err = txn.Commit(ctx)
+	return err
+}
+
+type recorder struct {
+	mu        sync.Mutex
+	all       []time.Duration
+	errors    map[string]int
+	latencies map[string][]time.Duration
+	stmts     map[string]*StatementReport
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		errors:    map[string]int{},
+		latencies: map[string][]time.Duration{},
+		stmts:     map[string]*StatementReport{},
+	}
+}
+
+func (r *recorder) record(name string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, ok := r.stmts[name]
+	if !ok {
+		st = &StatementReport{}
+		r.stmts[name] = st
+	}
+	st.Requests++
+	if err != nil {
+		st.Errors++
+		code := spannerr.ErrorCode(err)
+		if code == "" {
+			code = "CLIENT"
+		}
+		r.errors[code]++
+		return
+	}
+	r.all = append(r.all, d)
+	r.latencies[name] = append(r.latencies[name], d)
+}
+
+func (r *recorder) report() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := &Report{
+		Latency:     percentiles(r.all),
+		Errors:      r.errors,
+		ByStatement: r.stmts,
+	}
+	for name, st := range r.stmts {
+		rep.Requests += st.Requests
+		st.Latency = percentiles(r.latencies[name])
+	}
+	return rep
+}
+
+func percentiles(ds []time.Duration) Latency {
+	if len(ds) == 0 {
+		return Latency{}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	at := func(p float64) time.Duration {
+		return ds[int(p*float64(len(ds)-1))]
+	}
+	return Latency{P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: ds[len(ds)-1]}
+}
+
+// String formats the report as a human readable summary.
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d requests in %s (%.1f qps), %d skipped\n",
+		r.Requests, r.Duration.Round(time.Millisecond),
+		float64(r.Requests)/r.Duration.Seconds(), r.Skipped)
+	fmt.Fprintf(&b, "latency: %s\n", r.Latency)
+	names := make([]string, 0, len(r.ByStatement))
+	for name := range r.ByStatement {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		st := r.ByStatement[name]
+		fmt.Fprintf(&b, "  %s: %d requests, %d errors, %s\n", name, st.Requests, st.Errors, st.Latency)
+	}
+	codes := make([]string, 0, len(r.Errors))
+	for code := range r.Errors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(&b, "errors %s: %d\n", code, r.Errors[code])
+	}
+	return b.String()
+}
+
+func (l Latency) String() string {
+	return fmt.Sprintf("p50=%s p90=%s p99=%s max=%s", l.P50, l.P90, l.P99, l.Max)
+}
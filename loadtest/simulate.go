@@ -0,0 +1,206 @@
+package loadtest
+
+import (
+	"container/heap"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Pattern generates the arrival times of session acquisitions.
+type Pattern interface {
+	Arrivals(r *rand.Rand, d time.Duration) []time.Duration
+}
+
+// Steady is a constant average rate of acquisitions with Poisson arrivals.
+type Steady struct {
+	QPS float64
+}
+
+// Arrivals implements Pattern.
+func (s Steady) Arrivals(r *rand.Rand, d time.Duration) []time.Duration {
+	var out []time.Duration
+	for t := time.Duration(0); ; {
+		t += time.Duration(r.ExpFloat64() / s.QPS * float64(time.Second))
+		if t >= d {
+			return out
+		}
+		out = append(out, t)
+	}
+}
+
+// Bursty is a Steady base rate with periodic bursts of acquisitions on top, such
+// as those caused by cron jobs or fan-out requests.
+type Bursty struct {
+	Steady
+	// Size is the number of acquisitions in each burst.
+	Size int
+	// Every is the time between the start of bursts.
+	Every time.Duration
+	// Spread is the window over which each burst's acquisitions arrive.
+	Spread time.Duration
+}
+
+// Arrivals implements Pattern.
+func (b Bursty) Arrivals(r *rand.Rand, d time.Duration) []time.Duration {
+	var out []time.Duration
+	if b.QPS > 0 {
+		out = b.Steady.Arrivals(r, d)
+	}
+	for start := time.Duration(0); b.Every > 0 && start < d; start += b.Every {
+		for i := 0; i < b.Size; i++ {
+			t := start
+			if b.Spread > 0 {
+				t += time.Duration(r.Int63n(int64(b.Spread)))
+			}
+			if t < d {
+				out = append(out, t)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// PoolSim configures a simulation of the spannerr session pool. The pool is
+// modelled as it behaves in spannerr.Client: sessions are created on demand up to
+// MaxSessions, and once all of them are in use an acquisition fails immediately
+// or, with Wait, waits for a session to be released.
+type PoolSim struct {
+	MaxSessions int
+	// Wait models spannerr.Client.WaitForSession: acquisitions wait, first come
+	// first served, for a session rather than failing, and only fail once
+	// AcquireTimeout passes, if it is set.
+	Wait           bool
+	AcquireTimeout time.Duration
+	// Latency is the mean time a session is held per acquisition, and
+	// LatencyStdDev its standard deviation.
+	Latency       time.Duration
+	LatencyStdDev time.Duration
+	// CreateLatency is the time taken to create a new session.
+	CreateLatency time.Duration
+	// Duration is the simulated time span.
+	Duration time.Duration
+	// Seed seeds the simulation, making results reproducible.
+	Seed int64
+}
+
+// SimResult is the outcome of a pool simulation.
+type SimResult struct {
+	Acquisitions int
+	// Rejected is the number of acquisitions that failed because the pool
+	// was exhausted or, with Wait, timed out waiting.
+	Rejected int
+	// Waited is the number of acquisitions that waited for a session, and
+	// MeanWait and MaxWait how long they waited.
+	Waited   int
+	MeanWait time.Duration
+	MaxWait  time.Duration
+	// PeakInUse is the maximum number of sessions in use at once.
+	PeakInUse int
+	// Utilization is the mean fraction of MaxSessions in use.
+	Utilization float64
+}
+
+// RejectRate returns the fraction of acquisitions that were rejected.
+func (r SimResult) RejectRate() float64 {
+	if r.Acquisitions == 0 {
+		return 0
+	}
+	return float64(r.Rejected) / float64(r.Acquisitions)
+}
+
+// Simulate runs the acquisition pattern against the simulated pool.
+func Simulate(p Pattern, cfg PoolSim) SimResult {
+	r := rand.New(rand.NewSource(cfg.Seed))
+	var (
+		res     SimResult
+		busy    releases
+		created int
+		busyNs  float64
+		waited  time.Duration
+	)
+	for _, at := range p.Arrivals(r, cfg.Duration) {
+		res.Acquisitions++
+		for busy.Len() > 0 && busy[0] <= at {
+			heap.Pop(&busy)
+		}
+		hold := cfg.Latency
+		if cfg.LatencyStdDev > 0 {
+			hold += time.Duration(r.NormFloat64() * float64(cfg.LatencyStdDev))
+		}
+		if hold < 0 {
+			hold = 0
+		}
+		start := at
+		switch {
+		case busy.Len() < created:
+		case created < cfg.MaxSessions:
+			created++
+			hold += cfg.CreateLatency
+		case !cfg.Wait || busy.Len() == 0:
+			res.Rejected++
+			continue
+		default:
+			// earlier waiters have already claimed the sessions released
+			// before the earliest remaining release
+			wait := busy[0] - at
+			if cfg.AcquireTimeout > 0 && wait > cfg.AcquireTimeout {
+				res.Rejected++
+				continue
+			}
+			heap.Pop(&busy)
+			start += wait
+			res.Waited++
+			waited += wait
+			if wait > res.MaxWait {
+				res.MaxWait = wait
+			}
+		}
+		heap.Push(&busy, start+hold)
+		busyNs += float64(hold)
+		if busy.Len() > res.PeakInUse {
+			res.PeakInUse = busy.Len()
+		}
+	}
+	if res.Waited > 0 {
+		res.MeanWait = waited / time.Duration(res.Waited)
+	}
+	if cfg.MaxSessions > 0 && cfg.Duration > 0 {
+		res.Utilization = busyNs / (float64(cfg.Duration) * float64(cfg.MaxSessions))
+	}
+	return res
+}
+
+// SizePool returns the smallest MaxSessions, up to limit, for which the simulated
+// reject rate is at most maxRejectRate, answering questions such as "how many
+// sessions do I need for 200 QPS of 30ms queries?". With cfg.Wait, only
+// acquisitions timing out count as rejected. It returns limit if no smaller pool
+// suffices.
+func SizePool(p Pattern, cfg PoolSim, maxRejectRate float64, limit int) int {
+	lo, hi := 1, limit
+	for lo < hi {
+		mid := (lo + hi) / 2
+		cfg.MaxSessions = mid
+		if Simulate(p, cfg).RejectRate() <= maxRejectRate {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo
+}
+
+// releases is a min-heap of the times sessions in use are released.
+type releases []time.Duration
+
+func (h releases) Len() int            { return len(h) }
+func (h releases) Less(i, j int) bool  { return h[i] < h[j] }
+func (h releases) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *releases) Push(x interface{}) { *h = append(*h, x.(time.Duration)) }
+func (h *releases) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,155 @@
+package spannerr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+	"google.golang.org/appengine"
+)
+
+// sessionLabels are attached to every session the Client creates so maintenance
+// only ever touches sessions created by this package.
+var sessionLabels = map[string]string{"client": "spannerr"}
+
+// MaintenanceReport describes the work done by Maintain.
+type MaintenanceReport struct {
+	// Pinged is the number of idle pooled sessions kept alive.
+	Pinged int `json:"pinged"`
+	// Dead is the number of pooled sessions found expired and dropped.
+	Dead int `json:"dead"`
+	// OrphansDeleted is the number of abandoned sessions deleted.
+	OrphansDeleted int `json:"orphans_deleted"`
+}
+
+// Maintain keeps the Client's idle pooled sessions alive, renews its
+// SessionRegistry leases and deletes orphaned sessions, such as those left behind
+// by instances that shut down without calling Close. It is meant to be run periodically; see MaintenanceHandler.
+func (c *Client) Maintain(ctx context.Context) (*MaintenanceReport, error) {
+	var rep MaintenanceReport
+	var err error
+	rep.Pinged, rep.Dead, err = c.pingIdle(ctx)
+	if err != nil {
+		return &rep, err
+	}
+	rep.OrphansDeleted, err = c.DeleteOrphanSessions(ctx)
+	return &rep, err
+}
+
+// pingIdle runs a trivial query on every idle pooled session, resetting both the
+// server-side and local idle timers, and drops sessions that no longer exist.
+func (c *Client) pingIdle(ctx context.Context) (pinged, dead int, err error) {
+	c.smu.Lock()
+	c.renewSlots(ctx)
+	var idle []string
+	for name, info := range c.sessions {
+		if !info.inUse {
+			// hold the session while it is pinged
+			c.hold(info)
+			idle = append(idle, name)
+		}
+	}
+	c.smu.Unlock()
+
+	for _, name := range idle {
+		sess, serr := c.session(ctx, name)
+		if serr == nil {
+			_, serr = sess.rpc(ctx).ExecuteSql(name, &spanner.ExecuteSqlRequest{Sql: "SELECT 1"}).Context(ctx).Do()
+		}
+		c.smu.Lock()
+		info := c.sessions[name]
+		switch {
+		case info == nil:
+		case ErrorCode(serr) == "NOT_FOUND":
+			delete(c.sessions, name)
+			c.freeSlot(ctx, info)
+			c.wakeWaiter()
+			dead++
+		default:
+			info.inUse = false
+			c.wakeWaiter()
+			if serr == nil {
+				info.lastUsed = time.Now().UTC()
+				pinged++
+			} else if err == nil {
+				err = errors.Wrap(serr, "unable to ping session")
+			}
+		}
+		c.smu.Unlock()
+	}
+	return pinged, dead, err
+}
+
+// DeleteOrphanSessions deletes sessions created by this package that are not in
+// the Client's pool and have been idle for longer than any pool would keep them,
+// returning the number deleted.
+// This function wraps https://godoc.org/google.golang.org/api/spanner/v1#ProjectsInstancesDatabasesSessionsService.List
+func (c *Client) DeleteOrphanSessions(ctx context.Context) (int, error) {
+	svc, err := c.newSpanner(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to init spanner service")
+	}
+	sessions := svc.Projects.Instances.Databases.Sessions
+
+	var orphans []string
+	cutoff := time.Now().Add(-idleTimeout)
+	err = sessions.List(c.conn).Filter("labels.client:spannerr").Pages(ctx, func(res *spanner.ListSessionsResponse) error {
+		c.smu.Lock()
+		defer c.smu.Unlock()
+		for _, s := range res.Sessions {
+			if _, pooled := c.sessions[s.Name]; pooled {
+				continue
+			}
+			last, err := time.Parse(time.RFC3339Nano, s.ApproximateLastUseTime)
+			if err == nil && last.Before(cutoff) {
+				orphans = append(orphans, s.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to list sessions")
+	}
+	var deleted int
+	for _, name := range orphans {
+		_, err := sessions.Delete(name).Context(ctx).Do()
+		if err != nil && ErrorCode(err) != "NOT_FOUND" {
+			return deleted, errors.Wrap(err, "unable to delete orphaned session")
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// MaintenanceHandler returns an http.Handler to be mounted as an App Engine cron
+// route that runs Maintain and, if flush is not nil, passes the Client's Stats to
+// it for export. Requests that did not come from App Engine cron are rejected.
+//
+//	cron:
+//	- description: spanner session maintenance
+//	  url: /_spannerr/maintenance
+//	  schedule: every 10 minutes
+func (c *Client) MaintenanceHandler(flush func(context.Context, Stats) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Appengine-Cron") != "true" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		ctx := appengine.NewContext(r)
+		rep, err := c.Maintain(ctx)
+		if err == nil && flush != nil {
+			err = protect(func() error { return flush(ctx, c.Stats()) })
+			err = errors.Wrap(err, "unable to flush stats")
+		}
+		if err != nil {
+			c.logf(ctx, "maintenance failed: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rep)
+	})
+}
@@ -0,0 +1,52 @@
+package spannerr
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+type metadataKey struct{}
+
+// WithMetadata returns a copy of ctx carrying the given key/value pair of request
+// metadata, such as an end user ID or feature flag, in addition to any metadata
+// already in ctx. Metadata is included in statement logs and is available to
+// anything handed the ctx of a call, such as Logf, via Metadata.
+func WithMetadata(ctx context.Context, key, value string) context.Context {
+	md := map[string]string{key: value}
+	for k, v := range Metadata(ctx) {
+		if k != key {
+			md[k] = v
+		}
+	}
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// Metadata returns the request metadata carried by ctx. The returned map must not
+// be modified.
+func Metadata(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(metadataKey{}).(map[string]string)
+	return md
+}
+
+// metadataString formats the metadata in ctx for logging, redacting the values of
+// keys listed in the Client's Redact list.
+func (c *Client) metadataString(ctx context.Context) string {
+	md := Metadata(ctx)
+	if len(md) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		v := md[k]
+		if c.isSensitive("", k) {
+			v = Redacted
+		}
+		keys[i] = k + "=" + v
+	}
+	return " metadata: " + strings.Join(keys, " ")
+}
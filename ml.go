@@ -0,0 +1,63 @@
+package spannerr
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PredictSQL builds an ML.PREDICT statement running the given model over inputs,
+// which must be a slice of structs whose `spanner` tags name the model's input
+// columns. Each input row is passed in through parameters.
+// More details can be found here: https://cloud.google.com/spanner/docs/ml
+func PredictSQL(model string, inputs interface{}) (string, []*Param, error) {
+	qm, err := quoteIdent(model)
+	if err != nil {
+		return "", nil, err
+	}
+	rv := reflect.ValueOf(inputs)
+	if rv.Kind() != reflect.Slice {
+		return "", nil, errors.Errorf("inputs must be a slice of structs, got %T", inputs)
+	}
+	if rv.Len() == 0 {
+		return "", nil, errors.New("no inputs given for prediction")
+	}
+	var (
+		rows   []string
+		params []*Param
+	)
+	for i := 0; i < rv.Len(); i++ {
+		sv, err := structValue(rv.Index(i).Interface())
+		if err != nil {
+			return "", nil, err
+		}
+		var cols []string
+		for j, f := range structFields(sv.Type()) {
+			qc, err := quoteIdent(f.name)
+			if err != nil {
+				return "", nil, err
+			}
+			name := "in" + strconv.Itoa(i) + "_" + strconv.Itoa(j)
+			cols = append(cols, "@"+name+" AS "+qc)
+			params = append(params, valueParam(name, sv.FieldByIndex(f.index).Interface()))
+		}
+		rows = append(rows, "SELECT "+strings.Join(cols, ", "))
+	}
+	return "SELECT * FROM ML.PREDICT(MODEL " + qm + ", (" +
+		strings.Join(rows, " UNION ALL ") + "))", params, nil
+}
+
+// Predict runs ML.PREDICT over the given model for each element of inputs, a slice
+// of structs mapping the model's input columns, and decodes the predictions into
+// dst, a pointer to a slice of structs mapping the model's output columns. Nested
+// STRUCT outputs decode into nested Go structs.
+func (s *Session) Predict(ctx context.Context, model string, inputs, dst interface{}) error {
+	sql, params, err := PredictSQL(model, inputs)
+	if err != nil {
+		return err
+	}
+	return s.query(ctx, sql, params, nil, dst)
+}
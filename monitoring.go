@@ -0,0 +1,167 @@
+package spannerr
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	monitoring "google.golang.org/api/monitoring/v3"
+)
+
+// maxTimeSeries is the maximum number of time series in a single
+// CreateTimeSeries call.
+const maxTimeSeries = 200
+
+// MonitoringExporter pushes a Client's Stats to Cloud Monitoring as custom
+// metrics, for App Engine standard services with no Prometheus scraper. Pass
+// Export to MaintenanceHandler to export from a cron job, or call Start to export
+// on a timer from instances that allow background work:
+//
+//	pool/sessions, pool/in_use, pool/max_sessions     (gauge)
+//	txn/commits, txn/aborts, txn/failures, txn/retries (cumulative, by tag)
+//	txn/avg_latency_ms, txn/max_latency_ms             (gauge, by tag)
+type MonitoringExporter struct {
+	// Client is the Client whose credentials are used to write the metrics and,
+	// for Start, whose Stats are exported.
+	Client *Client
+	// Project is the ID of the project the metrics are written to.
+	Project string
+	// Prefix is prepended to every metric type. It defaults to
+	// "custom.googleapis.com/spannerr/".
+	Prefix string
+	// Labels are added to every time series. Cloud Monitoring rejects points
+	// written to the same series more often than every five seconds, so each
+	// exporting instance needs distinct labels; if Labels has no "instance"
+	// label, a random one is added per exporter.
+	Labels map[string]string
+
+	once     sync.Once
+	instance string
+	start    string
+}
+
+// Start exports the Client's Stats every interval, or every minute if interval is
+// not positive, until the returned function is called. Failed exports are logged through the Client's logger.
+func (e *MonitoringExporter) Start(ctx context.Context, interval time.Duration) (stop func()) {
+	c := e.Client
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		tick := time.NewTicker(tickInterval(interval, time.Minute))
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+			if err := e.Export(ctx, c.Stats()); err != nil {
+				c.logf(ctx, "unable to export stats: %s", err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
+
+// Export writes st to Cloud Monitoring. Its signature matches the flush function
+// taken by MaintenanceHandler.
+func (e *MonitoringExporter) Export(ctx context.Context, st Stats) error {
+	e.once.Do(func() {
+		e.instance = newEventID()
+		// cumulative metrics count from when the exporter was created
+		e.start = time.Now().UTC().Format(time.RFC3339Nano)
+	})
+	svc, err := monitoring.New(e.Client.httpClient(monitoring.MonitoringWriteScope))
+	if err != nil {
+		return errors.Wrap(err, "unable to init monitoring service")
+	}
+	series := e.timeSeries(st, time.Now().UTC().Format(time.RFC3339Nano))
+	for len(series) > 0 {
+		n := len(series)
+		if n > maxTimeSeries {
+			n = maxTimeSeries
+		}
+		_, err := svc.Projects.TimeSeries.Create("projects/"+e.Project, &monitoring.CreateTimeSeriesRequest{
+			TimeSeries: series[:n],
+		}).Context(ctx).Do()
+		if err != nil {
+			return errors.Wrap(err, "unable to write time series")
+		}
+		series = series[n:]
+	}
+	return nil
+}
+
+// timeSeries converts st into time series with points at now.
+func (e *MonitoringExporter) timeSeries(st Stats, now string) []*monitoring.TimeSeries {
+	prefix := e.Prefix
+	if prefix == "" {
+		prefix = "custom.googleapis.com/spannerr/"
+	}
+	resource := &monitoring.MonitoredResource{
+		Type:   "global",
+		Labels: map[string]string{"project_id": e.Project},
+	}
+	point := func(name, kind, tag string, v *monitoring.TypedValue) *monitoring.TimeSeries {
+		labels := map[string]string{"instance": e.instance}
+		for k, v := range e.Labels {
+			labels[k] = v
+		}
+		if strings.HasPrefix(name, "txn/") {
+			labels["tag"] = tag
+		}
+		interval := &monitoring.TimeInterval{EndTime: now}
+		valueType := "INT64"
+		if v.DoubleValue != nil {
+			valueType = "DOUBLE"
+		}
+		if kind == "CUMULATIVE" {
+			interval.StartTime = e.start
+		}
+		return &monitoring.TimeSeries{
+			Metric:     &monitoring.Metric{Type: prefix + name, Labels: labels},
+			Resource:   resource,
+			MetricKind: kind,
+			ValueType:  valueType,
+			Points:     []*monitoring.Point{{Interval: interval, Value: v}},
+		}
+	}
+	count := func(n int64) *monitoring.TypedValue { return &monitoring.TypedValue{Int64Value: &n} }
+	millis := func(d time.Duration) *monitoring.TypedValue {
+		ms := float64(d) / float64(time.Millisecond)
+		return &monitoring.TypedValue{DoubleValue: &ms}
+	}
+
+	series := []*monitoring.TimeSeries{
+		point("pool/sessions", "GAUGE", "", count(int64(st.Pool.Sessions))),
+		point("pool/in_use", "GAUGE", "", count(int64(st.Pool.InUse))),
+		point("pool/max_sessions", "GAUGE", "", count(int64(st.Pool.MaxSessions))),
+	}
+	tags := make([]string, 0, len(st.Transactions))
+	for tag := range st.Transactions {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		ts := st.Transactions[tag]
+		series = append(series,
+			point("txn/commits", "CUMULATIVE", tag, count(ts.Commits)),
+			point("txn/aborts", "CUMULATIVE", tag, count(ts.Aborts)),
+			point("txn/failures", "CUMULATIVE", tag, count(ts.Failures)),
+			point("txn/retries", "CUMULATIVE", tag, count(ts.Retries)),
+			point("txn/avg_latency_ms", "GAUGE", tag, millis(ts.AvgLatency())),
+			point("txn/max_latency_ms", "GAUGE", tag, millis(ts.MaxLatency)),
+		)
+	}
+	return series
+}
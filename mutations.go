@@ -0,0 +1,354 @@
+package spannerr
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Struct fields used by the mutation builders may carry the following `spanner`
+// tag options:
+//
+//	pk            the column is part of the table's primary key.
+//	parent=Table  the column is part of the primary key of the parent table the
+//	              row is interleaved in. Implies pk.
+//	sensitive     the column holds PII or secrets and its values are redacted
+//	              from logs.
+//	auto          the key column is populated with a new random key by
+//	              InsertStruct, InsertOrUpdateStruct and ReplaceStruct when it is
+//	              zero: a UUID for string and []byte fields, or a random positive
+//	              integer for int64 fields. The struct must be passed by pointer
+//	              so the generated key is visible to the caller.
+//	generated     the column is filled in by Spanner, such as an IDENTITY column
+//	              or one defaulting to GET_NEXT_SEQUENCE_VALUE, and is omitted
+//	              from writes when zero. See Txn.InsertReturning.
+//
+// Key columns are always written first, with parent key columns leading, to match
+// the key layout of interleaved tables.
+//
+//	type Album struct {
+//		SingerID int64  `spanner:"SingerId,parent=Singers"`
+//		AlbumID  string `spanner:"AlbumId,pk,auto"`
+//		Title    string `spanner:"AlbumTitle"`
+//	}
+
+// TableRegistry records the interleaved parents of tables and their sensitive
+// columns, as discovered from struct tags and the schema. Table names are only
+// unique within a database, so Clients of different databases whose tables share
+// names should each have their own; see Client.Tables.
+type TableRegistry struct {
+	// parents maps table names to their parent table.
+	parents sync.Map // map[string]string
+	// sensitive holds the lower case "table.column" names of sensitive columns.
+	sensitive sync.Map // map[string]bool
+}
+
+// DefaultTables is the registry used by the package-level struct mutation
+// builders, RegisterInterleave and SortParentFirst, and by Clients without
+// Tables. It is the only registry the struct mutation builders record struct
+// tags in.
+var DefaultTables = &TableRegistry{}
+
+// RegisterInterleave records that table is interleaved in parent.
+func (r *TableRegistry) RegisterInterleave(table, parent string) {
+	r.parents.Store(table, parent)
+}
+
+// RegisterStruct records the parent= and sensitive options of the struct tags of
+// v, a struct or pointer to one written to table.
+func (r *TableRegistry) RegisterStruct(table string, v interface{}) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return
+	}
+	for _, f := range structFields(t) {
+		if p := f.parentTable(); p != "" {
+			r.parents.Store(table, p)
+		}
+		if f.hasOpt("sensitive") {
+			r.sensitive.Store(strings.ToLower(table+"."+f.name), true)
+		}
+	}
+}
+
+// isSensitive reports whether the column of table is registered as sensitive.
+func (r *TableRegistry) isSensitive(table, column string) bool {
+	_, ok := r.sensitive.Load(strings.ToLower(table + "." + column))
+	return ok
+}
+
+// tables returns the Client's TableRegistry.
+func (c *Client) tables() *TableRegistry {
+	if c == nil || c.Tables == nil {
+		return DefaultTables
+	}
+	return c.Tables
+}
+
+func (f fieldInfo) parentTable() string {
+	for _, o := range f.opts {
+		if strings.HasPrefix(o, "parent=") {
+			return strings.TrimPrefix(o, "parent=")
+		}
+	}
+	return ""
+}
+
+func (f fieldInfo) isKey() bool {
+	return f.hasOpt("pk") || f.parentTable() != ""
+}
+
+// keyFirst returns the fields of t ordered parent key columns first, then the
+// remaining primary key columns, then all other columns.
+func keyFirst(t reflect.Type) []fieldInfo {
+	fs := append([]fieldInfo(nil), structFields(t)...)
+	rank := func(f fieldInfo) int {
+		switch {
+		case f.parentTable() != "":
+			return 0
+		case f.isKey():
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(fs, func(i, j int) bool { return rank(fs[i]) < rank(fs[j]) })
+	return fs
+}
+
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return rv, errors.New("nil struct given to mutation builder")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return rv, errors.Errorf("expected a struct, got %T", v)
+	}
+	return rv, nil
+}
+
+// structWrite builds a Write for table from the mapped fields of v.
+func structWrite(table string, v interface{}) (*spanner.Write, error) {
+	return structWriteMask(table, v, func(fieldInfo, reflect.Value) bool { return true })
+}
+
+// structWriteMask builds a Write for table from the key fields of v and any other
+// mapped fields for which include returns true.
+func structWriteMask(table string, v interface{}, include func(fieldInfo, reflect.Value) bool) (*spanner.Write, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	DefaultTables.RegisterStruct(table, v)
+	w := &spanner.Write{Table: table, Values: [][]interface{}{nil}}
+	for _, f := range keyFirst(rv.Type()) {
+		fv := rv.FieldByIndex(f.index)
+		if skipGenerated(f, fv) {
+			continue
+		}
+		if !f.isKey() && !include(f, fv) {
+			continue
+		}
+		w.Columns = append(w.Columns, f.name)
+		w.Values[0] = append(w.Values[0], encodeValue(fv.Interface()))
+	}
+	return w, nil
+}
+
+// FieldMask lists the columns a partial write should touch. Key columns are always
+// written. A nil FieldMask selects all fields with non-zero values.
+type FieldMask []string
+
+func (m FieldMask) include(f fieldInfo, v reflect.Value) bool {
+	if m == nil {
+		return !v.IsZero()
+	}
+	for _, c := range m {
+		if strings.EqualFold(c, f.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// PartialUpdate returns a mutation updating only the columns of v selected by mask,
+// leaving all other columns of the existing row untouched.
+func PartialUpdate(table string, v interface{}, mask FieldMask) (*spanner.Mutation, error) {
+	w, err := structWriteMask(table, v, mask.include)
+	if err != nil {
+		return nil, err
+	}
+	return &spanner.Mutation{Update: w}, nil
+}
+
+// PartialUpsert returns a mutation writing only the columns of v selected by mask.
+// If the row already exists its other columns are left untouched, otherwise they
+// are set to NULL or their default values.
+func PartialUpsert(table string, v interface{}, mask FieldMask) (*spanner.Mutation, error) {
+	w, err := structWriteMask(table, v, mask.include)
+	if err != nil {
+		return nil, err
+	}
+	return &spanner.Mutation{InsertOrUpdate: w}, nil
+}
+
+// InsertStruct returns a mutation inserting v into table.
+func InsertStruct(table string, v interface{}) (*spanner.Mutation, error) {
+	if err := populateKeys(v); err != nil {
+		return nil, err
+	}
+	w, err := structWrite(table, v)
+	if err != nil {
+		return nil, err
+	}
+	return &spanner.Mutation{Insert: w}, nil
+}
+
+// UpdateStruct returns a mutation updating the row in table matching the key of v.
+func UpdateStruct(table string, v interface{}) (*spanner.Mutation, error) {
+	w, err := structWrite(table, v)
+	if err != nil {
+		return nil, err
+	}
+	return &spanner.Mutation{Update: w}, nil
+}
+
+// InsertOrUpdateStruct returns a mutation inserting v into table, or updating the
+// existing row if one exists.
+func InsertOrUpdateStruct(table string, v interface{}) (*spanner.Mutation, error) {
+	if err := populateKeys(v); err != nil {
+		return nil, err
+	}
+	w, err := structWrite(table, v)
+	if err != nil {
+		return nil, err
+	}
+	return &spanner.Mutation{InsertOrUpdate: w}, nil
+}
+
+// ReplaceStruct returns a mutation inserting v into table, replacing any existing row.
+func ReplaceStruct(table string, v interface{}) (*spanner.Mutation, error) {
+	if err := populateKeys(v); err != nil {
+		return nil, err
+	}
+	w, err := structWrite(table, v)
+	if err != nil {
+		return nil, err
+	}
+	return &spanner.Mutation{Replace: w}, nil
+}
+
+// DeleteKeys returns a mutation deleting the rows with the given keys from table.
+func DeleteKeys(table string, keys ...Key) *spanner.Mutation {
+	return &spanner.Mutation{Delete: &spanner.Delete{Table: table, KeySet: keySet(keys...)}}
+}
+
+// DeleteStruct returns a mutation deleting the row with the key of v from table.
+func DeleteStruct(table string, v interface{}) (*spanner.Mutation, error) {
+	k, err := KeyOf(v)
+	if err != nil {
+		return nil, err
+	}
+	return DeleteKeys(table, k), nil
+}
+
+// KeyOf returns the primary key of v, built from the fields tagged with pk or
+// parent, with parent key columns first.
+func KeyOf(v interface{}) (Key, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	var k Key
+	for _, f := range keyFirst(rv.Type()) {
+		if !f.isKey() {
+			break
+		}
+		k = append(k, rv.FieldByIndex(f.index).Interface())
+	}
+	if len(k) == 0 {
+		return nil, errors.Errorf("%s has no primary key fields", rv.Type())
+	}
+	return k, nil
+}
+
+// RegisterInterleave records in DefaultTables that table is interleaved in
+// parent, for tables whose relationships cannot be discovered from struct tags.
+func RegisterInterleave(table, parent string) {
+	DefaultTables.RegisterInterleave(table, parent)
+}
+
+// interleaveDepth returns the number of ancestors of table.
+func (r *TableRegistry) interleaveDepth(table string) int {
+	var d int
+	for seen := map[string]bool{}; !seen[table]; d++ {
+		seen[table] = true
+		p, ok := r.parents.Load(table)
+		if !ok {
+			return d
+		}
+		table = p.(string)
+	}
+	return d
+}
+
+// SortParentFirst stably orders mutations so that writes to parent tables come
+// before writes to their interleaved children, and deletes of child rows come
+// before deletes of their parents, using the relationships in DefaultTables:
+// those from the parent= struct tag options seen by the struct mutation builders
+// and from RegisterInterleave. Only consecutive writes, or consecutive deletes,
+// are reordered, so a delete followed by a write of the same key, or the
+// reverse, keeps its meaning.
+func SortParentFirst(muts []*spanner.Mutation) {
+	DefaultTables.SortParentFirst(muts)
+}
+
+// SortParentFirst orders mutations like the package-level SortParentFirst,
+// using the relationships recorded in r.
+func (r *TableRegistry) SortParentFirst(muts []*spanner.Mutation) {
+	for start := 0; start < len(muts); {
+		del := muts[start].Delete != nil
+		end := start + 1
+		for end < len(muts) && (muts[end].Delete != nil) == del {
+			end++
+		}
+		// mutations of the same table have the same depth, so their order is
+		// kept by the stable sort
+		run := muts[start:end]
+		rank := func(m *spanner.Mutation) int {
+			if del {
+				// deepest first
+				return -r.interleaveDepth(m.Delete.Table)
+			}
+			return r.interleaveDepth(mutationTable(m))
+		}
+		sort.SliceStable(run, func(i, j int) bool { return rank(run[i]) < rank(run[j]) })
+		start = end
+	}
+}
+
+// mutationTable returns the table targeted by the mutation.
+func mutationTable(m *spanner.Mutation) string {
+	switch {
+	case m.Insert != nil:
+		return m.Insert.Table
+	case m.Update != nil:
+		return m.Update.Table
+	case m.InsertOrUpdate != nil:
+		return m.InsertOrUpdate.Table
+	case m.Replace != nil:
+		return m.Replace.Table
+	case m.Delete != nil:
+		return m.Delete.Table
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+package spannerr
+
+import (
+	"context"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+type requestScopeKey struct{}
+
+// requestScope tracks the statements executed within a request, and the distinct
+// params each was executed with.
+type requestScope struct {
+	mu     sync.Mutex
+	params map[string]map[string]struct{}
+}
+
+// RequestScope returns a copy of ctx marking the start of a request, such as an
+// incoming HTTP request, for the Client's N+1 query detection. See
+// Client.NPlusOneThreshold.
+func RequestScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestScopeKey{}, &requestScope{params: map[string]map[string]struct{}{}})
+}
+
+// detectNPlusOne counts an execution of sql with the encoded params within the
+// request scope of ctx and logs a warning, with the stack of the caller, once the
+// same statement has been executed with NPlusOneThreshold different params.
+// Repeats with identical params, such as retries, are not counted.
+func (c *Client) detectNPlusOne(ctx context.Context, sql string, params []byte) {
+	if c.NPlusOneThreshold <= 0 {
+		return
+	}
+	scope, ok := ctx.Value(requestScopeKey{}).(*requestScope)
+	if !ok {
+		return
+	}
+	fp := fingerprint(sql)
+	scope.mu.Lock()
+	seen := scope.params[fp]
+	if seen == nil {
+		seen = map[string]struct{}{}
+		scope.params[fp] = seen
+	}
+	seen[string(params)] = struct{}{}
+	n := len(seen)
+	scope.mu.Unlock()
+	if n == c.NPlusOneThreshold {
+		c.logf(ctx, "possible N+1 query: statement executed %d times in one request with different params; "+
+			"consider batching or a JOIN: %q\n%s", n, fp, debug.Stack())
+	}
+}
+
+// fingerprint normalizes the whitespace of sql so statements that differ only in
+// formatting are treated as the same. Values are expected to be passed as params,
+// so the statement text alone identifies the query shape.
+func fingerprint(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
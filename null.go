@@ -0,0 +1,34 @@
+package spannerr
+
+import (
+	"reflect"
+	"strings"
+)
+
+// NullParam returns a Param binding name to a NULL of the given type, such as
+// "STRING", "TIMESTAMP" or "ARRAY<INT64>". Typed NULLs let Spanner resolve the
+// parameter where a bare nil Value is ambiguous, such as in SELECT lists, function
+// arguments and comparisons between parameters.
+func NullParam(name, typeCode string) *Param {
+	t := strings.ToUpper(strings.TrimSpace(typeCode))
+	p := &Param{Name: name, Type: TypeCode(t)}
+	if strings.HasPrefix(t, "ARRAY<") && strings.HasSuffix(t, ">") {
+		p.ArrayElementType = TypeCode(strings.TrimSpace(t[len("ARRAY<") : len(t)-1]))
+		p.Type = TypeArray
+	}
+	return p
+}
+
+// isNull reports whether v encodes as a SQL NULL: nil, a nil pointer, slice, map
+// or interface, or a nil *big.Rat.
+func isNull(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return rv.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package spannerr
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultMaxSessions is the size of a Client's session pool unless set with
+// WithMaxSessions.
+const DefaultMaxSessions = 10
+
+// Option configures a Client created by NewClient.
+type Option func(*Client)
+
+// WithMaxSessions sets the maximum number of sessions in the Client's pool. It
+// defaults to DefaultMaxSessions.
+func WithMaxSessions(n int) Option {
+	return func(c *Client) { c.maxSessions = n }
+}
+
+// WithTokenSource sets the credentials used for all Spanner calls instead of the
+// App Engine service account.
+func WithTokenSource(ts oauth2.TokenSource) Option {
+	return func(c *Client) { c.TokenSource = ts }
+}
+
+// WithHTTPClient sets the HTTP client used for all Spanner calls. It is used as-is,
+// so it must authenticate requests itself unless talking to the emulator or an
+// authenticating proxy. It takes precedence over WithTokenSource.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.HTTPClient = hc }
+}
+
+// WithEndpoint sets the base URL of the Spanner REST API, such as
+// "http://localhost:9020/" for the emulator or the address of a proxy.
+func WithEndpoint(url string) Option {
+	return func(c *Client) {
+		if url != "" && !strings.HasSuffix(url, "/") {
+			url += "/"
+		}
+		c.Endpoint = url
+	}
+}
+
+// WithScopes sets the OAuth scopes requested for data calls when credentials come
+// from the environment. They default to the spanner.data scope.
+func WithScopes(scopes ...string) Option {
+	return func(c *Client) { c.Scopes = scopes }
+}
+
+// WithReadOnly makes the Client reject every write; see Client.ReadOnly.
+func WithReadOnly() Option {
+	return func(c *Client) { c.ReadOnly = true }
+}
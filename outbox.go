@@ -0,0 +1,129 @@
+package spannerr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Outbox implements the transactional outbox pattern: events are committed in the
+// same transaction as the business data they describe and relayed to other
+// services afterwards, so an event is published if and only if its data was written.
+//
+// The outbox table is expected to have the following schema:
+//
+//	CREATE TABLE Outbox (
+//		EventId   STRING(36) NOT NULL,
+//		Topic     STRING(MAX) NOT NULL,
+//		Payload   BYTES(MAX),
+//		CreatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+//	) PRIMARY KEY (EventId)
+type Outbox struct {
+	Client *Client
+	// Table is the name of the outbox table.
+	Table string
+}
+
+// OutboxEvent is a row in the outbox table.
+type OutboxEvent struct {
+	ID        string    `spanner:"EventId,pk"`
+	Topic     string    `spanner:"Topic"`
+	Payload   []byte    `spanner:"Payload"`
+	CreatedAt time.Time `spanner:"CreatedAt"`
+}
+
+// Mutation returns the mutation inserting ev into the outbox, assigning it an ID
+// if it does not have one. CreatedAt is always set to the commit timestamp.
+func (o *Outbox) Mutation(ev *OutboxEvent) (*spanner.Mutation, error) {
+	if ev.ID == "" {
+		ev.ID = newEventID()
+	}
+	return Conventions{CreatedAt: "CreatedAt"}.Insert(o.Table, ev)
+}
+
+// Write atomically applies the business mutations along with the given events.
+// If ctx carries a transaction everything is buffered in it, otherwise it is
+// committed immediately.
+func (o *Outbox) Write(ctx context.Context, muts []*spanner.Mutation, events ...*OutboxEvent) error {
+	all := append([]*spanner.Mutation(nil), muts...)
+	for _, ev := range events {
+		m, err := o.Mutation(ev)
+		if err != nil {
+			return err
+		}
+		all = append(all, m)
+	}
+	return o.Client.Apply(ctx, all...)
+}
+
+// Relay reads up to limit of the oldest events in the outbox, passes each to relay
+// and deletes the ones relayed successfully. It returns the number of events
+// relayed. Relaying stops at the first error, so events are delivered in order
+// at least once.
+func (o *Outbox) Relay(ctx context.Context, limit int64, relay func(context.Context, *OutboxEvent) error) (int, error) {
+	qt, err := quoteIdent(o.Table)
+	if err != nil {
+		return 0, err
+	}
+	var events []*OutboxEvent
+	err = o.Client.Query(ctx, "SELECT EventId, Topic, Payload, CreatedAt FROM "+qt+
+		" ORDER BY CreatedAt LIMIT @limit",
+		[]*Param{{Name: "limit", Value: strconv.FormatInt(limit, 10), Type: TypeInt64}}, &events)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to read outbox")
+	}
+
+	var (
+		done    []Key
+		stopErr error
+	)
+	for _, ev := range events {
+		if stopErr = relay(ctx, ev); stopErr != nil {
+			stopErr = errors.Wrapf(stopErr, "unable to relay event %s", ev.ID)
+			break
+		}
+		done = append(done, Key{ev.ID})
+	}
+	if len(done) > 0 {
+		if err := o.Client.Apply(ctx, DeleteKeys(o.Table, done...)); err != nil {
+			return 0, errors.Wrap(err, "unable to delete relayed events")
+		}
+	}
+	return len(done), stopErr
+}
+
+// Poll calls Relay every interval, or every second if interval is not positive,
+// until ctx is done, reporting errors to the Client's logger.
+func (o *Outbox) Poll(ctx context.Context, interval time.Duration, limit int64, relay func(context.Context, *OutboxEvent) error) {
+	t := time.NewTicker(tickInterval(interval, time.Second))
+	defer t.Stop()
+	for {
+		// drain the outbox before waiting again
+		for {
+			n, err := o.Relay(ctx, limit, relay)
+			if err != nil {
+				o.Client.logf(ctx, "outbox relay: %s", err)
+			}
+			if err != nil || int64(n) < limit {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// newEventID returns a random 128-bit hex identifier.
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,102 @@
+package spannerr
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Pager pages through the results of a query too large for a single ExecuteSql
+// reply using LIMIT/OFFSET windows, reading every page at the same timestamp so
+// paginated exports are internally consistent. A Pager's exported state can be
+// saved between pages, such as in a task payload, and the export continued later
+// as long as Timestamp is within the database's version retention period, one hour
+// by default.
+//
+//	p := &spannerr.Pager{Client: c, SQL: "SELECT * FROM Users ORDER BY UserId", PageSize: 1000}
+//	for {
+//		var users []*User
+//		more, err := p.Next(ctx, &users)
+//		if err != nil {
+//			return err
+//		}
+//		...
+//		if !more {
+//			break
+//		}
+//	}
+type Pager struct {
+	Client *Client `json:"-"`
+	// SQL is the query to page through. It must have an ORDER BY clause that
+	// totally orders the rows, such as by primary key, and no LIMIT or OFFSET.
+	SQL    string   `json:"sql"`
+	Params []*Param `json:"params,omitempty"`
+	// PageSize is the maximum number of rows in a page.
+	PageSize int64 `json:"page_size"`
+
+	// Timestamp is the time every page is read at. If zero, the first page is
+	// read at a strong timestamp, which is recorded here.
+	Timestamp time.Time `json:"timestamp"`
+	// Offset is the number of rows already returned.
+	Offset int64 `json:"offset"`
+	// Done is set once the last page has been returned.
+	Done bool `json:"done"`
+}
+
+// pageLimitParam and pageOffsetParam are the params Pager adds to its SQL.
+const (
+	pageLimitParam  = "spannerr_page_limit"
+	pageOffsetParam = "spannerr_page_offset"
+)
+
+// Next decodes the next page of rows into dst, a pointer to a slice of structs,
+// reporting whether more pages may follow.
+func (p *Pager) Next(ctx context.Context, dst interface{}) (bool, error) {
+	if p.Done {
+		return false, nil
+	}
+	if p.PageSize <= 0 {
+		return false, errors.New("pager page size must be positive")
+	}
+	sql := p.SQL + " LIMIT @" + pageLimitParam + " OFFSET @" + pageOffsetParam
+	params := append(p.Params[:len(p.Params):len(p.Params)],
+		&Param{Name: pageLimitParam, Value: strconv.FormatInt(p.PageSize, 10), Type: TypeInt64},
+		&Param{Name: pageOffsetParam, Value: strconv.FormatInt(p.Offset, 10), Type: TypeInt64})
+	ro := &spanner.ReadOnly{Strong: true, ReturnReadTimestamp: true}
+	if !p.Timestamp.IsZero() {
+		ro = &spanner.ReadOnly{ReadTimestamp: p.Timestamp.UTC().Format(time.RFC3339Nano)}
+	}
+
+	sess, err := p.Client.AcquireSession(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer p.Client.ReleaseSession(ctx, *sess)
+	res, err := sess.ExecuteSQL(ReadOnlyContext(ctx), params, sql, QueryModeNormal, &spanner.TransactionSelector{
+		SingleUse: &spanner.TransactionOptions{ReadOnly: ro},
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "unable to read page")
+	}
+	if p.Timestamp.IsZero() {
+		_, ts, _ := TransactionInfo(res.Metadata)
+		if ts.IsZero() {
+			return false, errors.New("no read timestamp returned for first page")
+		}
+		p.Timestamp = ts
+	}
+	if dv := reflect.ValueOf(dst); dv.Kind() == reflect.Ptr && dv.Elem().Kind() == reflect.Slice {
+		// pages replace, rather than add to, the rows in dst
+		dv.Elem().SetLen(0)
+	}
+	if err := decodeRows(res, dst); err != nil {
+		return false, errors.Wrap(err, "unable to decode page")
+	}
+	p.Offset += int64(len(res.Rows))
+	p.Done = int64(len(res.Rows)) < p.PageSize
+	return !p.Done, nil
+}
@@ -0,0 +1,61 @@
+package spannerr
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ReadTask is a unit of work run by ParallelRead on its own pooled session.
+type ReadTask func(ctx context.Context, sess *Session) error
+
+// ParallelRead runs tasks concurrently, each on a distinct session acquired from
+// c's pool and released when the task returns, so a handler can parallelize
+// independent reads without sharing a session between goroutines. At most as many
+// tasks as the pool has sessions run at once. The first task to fail cancels the
+// ctx of the rest and its error is returned. Tasks run with ReadOnlyContext, so
+// DML is rejected.
+func ParallelRead(ctx context.Context, c *Client, tasks ...ReadTask) error {
+	ctx, cancel := context.WithCancel(ReadOnlyContext(ctx))
+	defer cancel()
+
+	concurrency := c.maxSessions
+	if concurrency <= 0 || concurrency > len(tasks) {
+		concurrency = len(tasks)
+	}
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, concurrency)
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	for i, task := range tasks {
+		wg.Add(1)
+		go func(i int, task ReadTask) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+			sess, err := c.AcquireSession(ctx)
+			if err != nil {
+				fail(errors.Wrapf(err, "unable to acquire session for task %d", i))
+				return
+			}
+			defer c.ReleaseSession(ctx, *sess)
+			if err := protect(func() error { return task(ctx, sess) }); err != nil {
+				fail(err)
+			}
+		}(i, task)
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,109 @@
+package spannerr
+
+import (
+	"math/big"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// ParamBuilder builds a list of Params, each typed and encoded to match the Go
+// value it was given. See Params.
+type ParamBuilder struct {
+	params []*Param
+}
+
+// Params returns an empty ParamBuilder, for building params more concisely and
+// with less room for error than constructing Params by hand:
+//
+//	params := spannerr.Params().Int64("id", 5).String("name", n).Timestamp("ts", t).Build()
+func Params() *ParamBuilder {
+	return &ParamBuilder{}
+}
+
+func (b *ParamBuilder) add(name string, v interface{}, typ TypeCode) *ParamBuilder {
+	b.params = append(b.params, &Param{Name: name, Value: encodeValue(v), Type: typ})
+	return b
+}
+
+// Bool adds a BOOL param.
+func (b *ParamBuilder) Bool(name string, v bool) *ParamBuilder {
+	return b.add(name, v, TypeBool)
+}
+
+// Int64 adds an INT64 param.
+func (b *ParamBuilder) Int64(name string, v int64) *ParamBuilder {
+	return b.add(name, v, TypeInt64)
+}
+
+// Float32 adds a FLOAT32 param.
+func (b *ParamBuilder) Float32(name string, v float32) *ParamBuilder {
+	return b.add(name, v, TypeFloat32)
+}
+
+// Float64 adds a FLOAT64 param.
+func (b *ParamBuilder) Float64(name string, v float64) *ParamBuilder {
+	return b.add(name, v, TypeFloat64)
+}
+
+// Numeric adds a NUMERIC param. A nil v binds NULL.
+func (b *ParamBuilder) Numeric(name string, v *big.Rat) *ParamBuilder {
+	return b.add(name, v, TypeNumeric)
+}
+
+// String adds a STRING param.
+func (b *ParamBuilder) String(name, v string) *ParamBuilder {
+	return b.add(name, v, TypeString)
+}
+
+// Bytes adds a BYTES param. A nil v binds NULL.
+func (b *ParamBuilder) Bytes(name string, v []byte) *ParamBuilder {
+	return b.add(name, v, TypeBytes)
+}
+
+// JSON adds a JSON param from an encoded JSON document.
+func (b *ParamBuilder) JSON(name, doc string) *ParamBuilder {
+	return b.add(name, doc, TypeJSON)
+}
+
+// Date adds a DATE param.
+func (b *ParamBuilder) Date(name string, v civil.Date) *ParamBuilder {
+	return b.add(name, v, TypeDate)
+}
+
+// Timestamp adds a TIMESTAMP param.
+func (b *ParamBuilder) Timestamp(name string, v time.Time) *ParamBuilder {
+	return b.add(name, v, TypeTimestamp)
+}
+
+// Interval adds an INTERVAL param.
+func (b *ParamBuilder) Interval(name string, v Interval) *ParamBuilder {
+	return b.add(name, v, TypeInterval)
+}
+
+// Null adds a NULL param of the given type, as NullParam does.
+func (b *ParamBuilder) Null(name, typeCode string) *ParamBuilder {
+	b.params = append(b.params, NullParam(name, typeCode))
+	return b
+}
+
+// Value adds a param whose type is inferred from the Go type of v, including
+// arrays such as []int64 and []string.
+func (b *ParamBuilder) Value(name string, v interface{}) *ParamBuilder {
+	b.params = append(b.params, valueParam(name, v))
+	return b
+}
+
+// Sensitive marks the most recently added param as sensitive, redacting its value
+// from logs.
+func (b *ParamBuilder) Sensitive() *ParamBuilder {
+	if len(b.params) > 0 {
+		b.params[len(b.params)-1].Sensitive = true
+	}
+	return b
+}
+
+// Build returns the params.
+func (b *ParamBuilder) Build() []*Param {
+	return b.params
+}
@@ -0,0 +1,32 @@
+package spannerr
+
+import "encoding/json"
+
+// ParamsEncoder encodes the params of a statement, keyed by name, into the JSON
+// object sent to Spanner. Its output must match that of encoding/json. Values
+// built with the helpers in this package are already in their API encoding, such
+// as INT64 values as decimal strings, so they are only strings, numbers,
+// booleans, nils, json.Numbers and slices of those.
+type ParamsEncoder interface {
+	EncodeParams(params map[string]interface{}) ([]byte, error)
+}
+
+// ParamsEncoderFunc adapts a function, such as a jsoniter config's Marshal, to a
+// ParamsEncoder:
+//
+//	c.ParamsEncoder = spannerr.ParamsEncoderFunc(func(p map[string]interface{}) ([]byte, error) {
+//		return jsoniter.ConfigFastest.Marshal(p)
+//	})
+type ParamsEncoderFunc func(params map[string]interface{}) ([]byte, error)
+
+// EncodeParams implements ParamsEncoder.
+func (f ParamsEncoderFunc) EncodeParams(params map[string]interface{}) ([]byte, error) {
+	return f(params)
+}
+
+// jsonParamsEncoder is the default ParamsEncoder, using encoding/json.
+type jsonParamsEncoder struct{}
+
+func (jsonParamsEncoder) EncodeParams(params map[string]interface{}) ([]byte, error) {
+	return json.Marshal(params)
+}
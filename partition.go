@@ -0,0 +1,141 @@
+package spannerr
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// BatchQuery is a query split into partitions that can be executed independently,
+// possibly by different processes, against the same read-only snapshot. It is
+// safe to serialize as JSON.
+type BatchQuery struct {
+	// Session is the dedicated session holding the read-only transaction.
+	Session string `json:"session"`
+	// TransactionID is the read-only transaction the partitions belong to.
+	TransactionID string `json:"transaction_id"`
+	// ReadTimestamp is the timestamp of the snapshot being read.
+	ReadTimestamp string `json:"read_timestamp"`
+
+	SQL    string   `json:"sql"`
+	Params []*Param `json:"-"`
+	// ParamTypes and EncodedParams are Params as sent to Spanner, which are
+	// serialized in their place so values such as large INT64s survive being
+	// decoded again.
+	ParamTypes    map[string]spanner.Type `json:"param_types,omitempty"`
+	EncodedParams json.RawMessage         `json:"encoded_params,omitempty"`
+	// Tokens holds one token per partition.
+	Tokens []string `json:"tokens"`
+}
+
+// params returns the query's params, restored from their encoded form if the
+// query was decoded from JSON.
+func (q *BatchQuery) params() ([]*Param, error) {
+	if q.Params != nil || len(q.EncodedParams) == 0 {
+		return q.Params, nil
+	}
+	var vals map[string]json.RawMessage
+	if err := json.Unmarshal(q.EncodedParams, &vals); err != nil {
+		return nil, errors.Wrap(err, "unable to decode batch params")
+	}
+	params := make([]*Param, 0, len(vals))
+	for name, val := range vals {
+		// raw values are sent as they are
+		p := &Param{Name: name, Value: val}
+		if t, ok := q.ParamTypes[name]; ok {
+			p.Type = TypeCode(t.Code)
+			if t.ArrayElementType != nil {
+				p.ArrayElementType = TypeCode(t.ArrayElementType.Code)
+			}
+		}
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+// PartitionQuery begins a strong read-only transaction on a new session outside of
+// the pool and splits the given query into partitions. The query must be root
+// partitionable. Call CloseBatch once every partition has been executed.
+// This function wraps https://godoc.org/google.golang.org/api/spanner/v1#ProjectsInstancesDatabasesSessionsService.PartitionQuery
+func (c *Client) PartitionQuery(ctx context.Context, sql string, params []*Param, opts *spanner.PartitionOptions) (*BatchQuery, error) {
+	sess, err := c.newSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q, err := sess.partitionQuery(ctx, sql, params, opts)
+	if err != nil {
+		sess.rpc(ctx).Delete(sess.name).Context(ctx).Do()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (s *Session) partitionQuery(ctx context.Context, sql string, params []*Param, opts *spanner.PartitionOptions) (*BatchQuery, error) {
+	pTypes, pJSON, err := s.prepareSQL(ctx, sql, params)
+	if err != nil {
+		return nil, err
+	}
+	txn, err := s.BeginTransaction(ctx, &spanner.BeginTransactionRequest{
+		Options: &spanner.TransactionOptions{
+			ReadOnly: &spanner.ReadOnly{Strong: true, ReturnReadTimestamp: true},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to begin batch transaction")
+	}
+	res, err := s.rpc(ctx).PartitionQuery(s.name, &spanner.PartitionQueryRequest{
+		ParamTypes:       pTypes,
+		Params:           pJSON,
+		Sql:              sql,
+		PartitionOptions: opts,
+		Transaction:      &spanner.TransactionSelector{Id: txn.Id},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to partition query")
+	}
+	q := &BatchQuery{
+		Session:       s.name,
+		TransactionID: txn.Id,
+		ReadTimestamp: txn.ReadTimestamp,
+		SQL:           sql,
+		Params:        params,
+		ParamTypes:    pTypes,
+		EncodedParams: pJSON,
+	}
+	for _, p := range res.Partitions {
+		q.Tokens = append(q.Tokens, p.PartitionToken)
+	}
+	return q, nil
+}
+
+// ExecutePartition streams the rows of a single partition of q. The returned
+// iterator must be closed.
+func (c *Client) ExecutePartition(ctx context.Context, q *BatchQuery, token string, opts *StreamOptions) (*RowIterator, error) {
+	sess, err := c.session(ctx, q.Session)
+	if err != nil {
+		return nil, err
+	}
+	params, err := q.params()
+	if err != nil {
+		return nil, err
+	}
+	o := StreamOptions{PartitionToken: token}
+	if opts != nil {
+		o.Prefetch = opts.Prefetch
+	}
+	return sess.ExecuteStreamingSQL(ctx, params, q.SQL, QueryModeNormal,
+		&spanner.TransactionSelector{Id: q.TransactionID}, &o)
+}
+
+// CloseBatch deletes the session holding the batch's transaction, invalidating
+// its partitions.
+func (c *Client) CloseBatch(ctx context.Context, q *BatchQuery) error {
+	sess, err := c.session(ctx, q.Session)
+	if err != nil {
+		return err
+	}
+	_, err = sess.rpc(ctx).Delete(q.Session).Context(ctx).Do()
+	return errors.Wrap(err, "unable to delete batch session")
+}
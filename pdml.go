@@ -0,0 +1,122 @@
+package spannerr
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// PDMLProgress reports the progress of a partitioned DML statement.
+type PDMLProgress struct {
+	// RowCount is a lower bound on the number of rows modified so far.
+	RowCount int64
+	// Elapsed is the time since the statement was started.
+	Elapsed time.Duration
+	// Done is set on the final report, once the statement has completed.
+	Done bool
+}
+
+// ExecutePartitionedDML executes a DML statement as partitioned DML, which applies
+// it to the table in independent partitions without the mutation limits of a
+// single transaction. The statement must be idempotent and is not atomic. It
+// returns a lower bound on the number of rows modified.
+// More details can be found here: https://cloud.google.com/spanner/docs/dml-partitioned
+func (s *Session) ExecutePartitionedDML(ctx context.Context, params []*Param, sql string) (int64, error) {
+	return s.ExecutePartitionedDMLProgress(ctx, params, sql, 0, nil)
+}
+
+// ExecutePartitionedDMLProgress is ExecutePartitionedDML for long-running
+// statements such as backfills. progress is called whenever Spanner reports a new
+// row count, every interval in between if interval is positive, and once more
+// when the statement completes. Calls to progress are never concurrent.
+func (s *Session) ExecutePartitionedDMLProgress(ctx context.Context, params []*Param, sql string, interval time.Duration, progress func(PDMLProgress)) (int64, error) {
+	if s.client.isDryRun(ctx) {
+		// partitioned DML can't be rolled back, so only check that it plans
+		if _, err := s.ExecuteSQL(ctx, params, sql, QueryModePlan, nil); err != nil {
+			return 0, errors.Wrap(err, "unable to plan partitioned dml")
+		}
+		s.client.logf(ctx, "dry run: partitioned dml not applied: %q", sql)
+		return 0, nil
+	}
+	txn, err := s.BeginTransaction(ctx, &spanner.BeginTransactionRequest{
+		Options: &spanner.TransactionOptions{PartitionedDml: &spanner.PartitionedDml{}},
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to begin partitioned dml transaction")
+	}
+
+	var (
+		mu       sync.Mutex
+		count    int64
+		finished bool
+	)
+	start := time.Now()
+	report := func(done bool) {
+		if progress == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		// the ticker may still fire after the final report
+		if finished {
+			return
+		}
+		finished = done
+		p := PDMLProgress{RowCount: count, Elapsed: time.Since(start), Done: done}
+		if err := protect(func() error {
+			progress(p)
+			return nil
+		}); err != nil {
+			// the statement keeps running; stop reporting
+			finished = true
+			s.client.logf(ctx, "partitioned dml progress callback failed: %s\n%s", err, err.(*PanicError).Stack)
+		}
+	}
+	opts := &StreamOptions{OnStats: func(st *spanner.ResultSetStats) {
+		mu.Lock()
+		changed := st.RowCountLowerBound != count
+		count = st.RowCountLowerBound
+		mu.Unlock()
+		if changed {
+			report(false)
+		}
+	}}
+	if progress != nil && interval > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-t.C:
+					report(false)
+				}
+			}
+		}()
+	}
+
+	it, err := s.ExecuteStreamingSQL(ctx, params, sql, QueryModeNormal,
+		&spanner.TransactionSelector{Id: txn.Id}, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+	for it.Next() {
+	}
+	if err := it.Err(); err != nil {
+		return 0, errors.Wrap(err, "unable to execute partitioned dml")
+	}
+	if it.Stats() != nil {
+		mu.Lock()
+		count = it.Stats().RowCountLowerBound
+		mu.Unlock()
+	}
+	report(true)
+	return count, nil
+}
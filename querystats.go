@@ -0,0 +1,140 @@
+package spannerr
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// QueryResult describes the execution of a statement run by QueryWithStats.
+type QueryResult struct {
+	// Columns are the columns of the result set.
+	Columns []Column
+	// TransactionID and ReadTimestamp describe the transaction begun by the
+	// statement, if any.
+	TransactionID string
+	ReadTimestamp time.Time
+	Stats         StatementStats
+}
+
+// StatementStats are the statistics Spanner reports for a statement. Row counts
+// are only reported for DML, and the remaining fields only in QueryModeProfile.
+type StatementStats struct {
+	// RowCount is the number of rows modified by DML. For partitioned DML it is
+	// a lower bound and RowCountExact is false.
+	RowCount      int64
+	RowCountExact bool
+
+	// QueryText is the statement as restated by Spanner.
+	QueryText    string
+	ElapsedTime  time.Duration
+	CPUTime      time.Duration
+	RowsReturned int64
+	RowsScanned  int64
+	// Raw holds every reported query statistic, such as "optimizer_version",
+	// as formatted by Spanner.
+	Raw map[string]string
+	// QueryPlan is the execution plan, in QueryModePlan and QueryModeProfile.
+	QueryPlan *spanner.QueryPlan
+}
+
+// QueryWithStats executes sql and decodes all resulting rows into dst, a pointer
+// to a slice of structs, returning the statement's metadata and statistics with
+// consistent types regardless of query mode. dst may be nil in QueryModePlan and for
+// DML without THEN RETURN.
+func (s *Session) QueryWithStats(ctx context.Context, sql string, params []*Param, queryMode QueryMode, tx *spanner.TransactionSelector, dst interface{}) (*QueryResult, error) {
+	res, err := s.ExecuteSQL(ctx, params, sql, queryMode, tx)
+	if err != nil {
+		return nil, err
+	}
+	if dst != nil {
+		if err := decodeRows(res, dst); err != nil {
+			return nil, errors.Wrap(err, "unable to decode query results")
+		}
+	}
+	qr := &QueryResult{Columns: Columns(res.Metadata)}
+	qr.TransactionID, qr.ReadTimestamp, _ = TransactionInfo(res.Metadata)
+	if res.Stats != nil {
+		qr.Stats, err = statementStats(res.Stats)
+	}
+	return qr, err
+}
+
+// QueryWithStats is Session.QueryWithStats within the transaction carried by ctx
+// or, if there is none, as a strong single-use read on a pooled session.
+func (c *Client) QueryWithStats(ctx context.Context, sql string, params []*Param, queryMode QueryMode, dst interface{}) (*QueryResult, error) {
+	if txn, ok := FromContextTxn(ctx); ok {
+		return txn.Session.QueryWithStats(ctx, sql, params, queryMode, txn.Selector(), dst)
+	}
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.ReleaseSession(ctx, *sess)
+	return sess.QueryWithStats(ctx, sql, params, queryMode, nil, dst)
+}
+
+// statementStats converts the API's ResultSetStats.
+func statementStats(rs *spanner.ResultSetStats) (StatementStats, error) {
+	st := StatementStats{
+		RowCount:      rs.RowCountExact,
+		RowCountExact: rs.RowCountLowerBound == 0,
+		QueryPlan:     rs.QueryPlan,
+	}
+	if !st.RowCountExact {
+		st.RowCount = rs.RowCountLowerBound
+	}
+	if len(rs.QueryStats) == 0 {
+		return st, nil
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(rs.QueryStats, &raw); err != nil {
+		return st, errors.Wrap(err, "unable to decode query stats")
+	}
+	st.Raw = make(map[string]string, len(raw))
+	for k, v := range raw {
+		st.Raw[k] = statString(v)
+	}
+	st.QueryText = st.Raw["query_text"]
+	st.ElapsedTime = statDuration(st.Raw["elapsed_time"])
+	st.CPUTime = statDuration(st.Raw["cpu_time"])
+	st.RowsReturned, _ = strconv.ParseInt(st.Raw["rows_returned"], 10, 64)
+	st.RowsScanned, _ = strconv.ParseInt(st.Raw["rows_scanned"], 10, 64)
+	return st, nil
+}
+
+// statString formats a query statistic, which is usually already a string.
+func statString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// statDuration parses a query statistic duration such as "1.25 msecs".
+func statDuration(s string) time.Duration {
+	f := strings.Fields(s)
+	if len(f) != 2 {
+		return 0
+	}
+	n, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return 0
+	}
+	unit := time.Second
+	switch f[1] {
+	case "msecs", "ms":
+		unit = time.Millisecond
+	case "usecs", "us":
+		unit = time.Microsecond
+	case "nsecs", "ns":
+		unit = time.Nanosecond
+	}
+	return time.Duration(n * float64(unit))
+}
@@ -0,0 +1,96 @@
+package spannerr
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// RangeDeleteMode selects how DeleteKeyRange deletes rows.
+type RangeDeleteMode int
+
+const (
+	// RangeDeleteSingle deletes the whole range atomically with a single delete
+	// mutation. Large ranges can exceed Spanner's transaction limits.
+	RangeDeleteSingle RangeDeleteMode = iota
+	// RangeDeleteChunked reads the keys in the range and deletes them in
+	// separate transactions of a bounded number of rows. It is not atomic but
+	// works for ranges of any size; if interrupted it can simply be run again.
+	RangeDeleteChunked
+)
+
+// DefaultDeleteChunk is the number of rows deleted per transaction by
+// RangeDeleteChunked when no chunk size is given.
+const DefaultDeleteChunk = 1000
+
+// DeleteKeyRange deletes the rows of table within kr, as selected by mode. In
+// RangeDeleteChunked mode at most chunk rows are deleted per transaction and the
+// number of rows deleted is returned; in RangeDeleteSingle mode the number of
+// rows is unknown and -1 is returned.
+func (c *Client) DeleteKeyRange(ctx context.Context, table string, kr *spanner.KeyRange, mode RangeDeleteMode, chunk int) (int64, error) {
+	ks := &spanner.KeySet{Ranges: []*spanner.KeyRange{encodeKeyRange(kr)}}
+	if mode == RangeDeleteSingle {
+		err := c.Apply(ctx, &spanner.Mutation{Delete: &spanner.Delete{Table: table, KeySet: ks}})
+		return -1, errors.Wrap(err, "unable to delete key range")
+	}
+	if chunk <= 0 {
+		chunk = DefaultDeleteChunk
+	}
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer c.ReleaseSession(ctx, *sess)
+	pk, err := sess.PrimaryKey(ctx, table)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	for {
+		res, err := sess.rpc(ctx).Read(sess.name, &spanner.ReadRequest{
+			Table:   table,
+			KeySet:  ks,
+			Columns: pk,
+			Limit:   int64(chunk),
+		}).Context(ctx).Do()
+		if err != nil {
+			return deleted, errors.Wrap(err, "unable to read keys in range")
+		}
+		if len(res.Rows) == 0 {
+			return deleted, nil
+		}
+		keys := make([]Key, len(res.Rows))
+		for i, row := range res.Rows {
+			keys[i] = Key(row)
+		}
+		if _, err := sess.Commit(ctx, []*spanner.Mutation{DeleteKeys(table, keys...)},
+			&spanner.TransactionOptions{ReadWrite: &spanner.ReadWrite{}}, ""); err != nil {
+			return deleted, errors.Wrapf(err, "unable to delete chunk after %d rows", deleted)
+		}
+		deleted += int64(len(keys))
+		if len(res.Rows) < chunk {
+			return deleted, nil
+		}
+	}
+}
+
+// encodeKeyRange returns a copy of kr with its key values encoded for the API.
+func encodeKeyRange(kr *spanner.KeyRange) *spanner.KeyRange {
+	enc := func(k []interface{}) []interface{} {
+		if k == nil {
+			return nil
+		}
+		out := make([]interface{}, len(k))
+		for i, v := range k {
+			out[i] = encodeValue(v)
+		}
+		return out
+	}
+	return &spanner.KeyRange{
+		StartClosed: enc(kr.StartClosed),
+		StartOpen:   enc(kr.StartOpen),
+		EndClosed:   enc(kr.EndClosed),
+		EndOpen:     enc(kr.EndOpen),
+	}
+}
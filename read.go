@@ -0,0 +1,116 @@
+package spannerr
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Key is a primary key value, with one element per key column in key order.
+type Key []interface{}
+
+// ErrRowNotFound is returned by ReadRow when no row exists for the given key.
+var ErrRowNotFound = errors.New("row not found")
+
+// keySet builds a KeySet containing the given keys with their values encoded
+// for the Spanner API.
+func keySet(keys ...Key) *spanner.KeySet {
+	ks := &spanner.KeySet{Keys: make([][]interface{}, len(keys))}
+	for i, k := range keys {
+		ks.Keys[i] = make([]interface{}, len(k))
+		for j, v := range k {
+			ks.Keys[i][j] = encodeValue(v)
+		}
+	}
+	return ks
+}
+
+// Read reads rows from the database using key lookups and scans.
+// This function wraps https://godoc.org/google.golang.org/api/spanner/v1#ProjectsInstancesDatabasesSessionsService.Read
+func (s *Session) Read(ctx context.Context, table string, keys *spanner.KeySet, columns []string, tx *spanner.TransactionSelector) (*spanner.ResultSet, error) {
+	if err := s.client.authorize(ctx, OpRead, "", []string{table}); err != nil {
+		return nil, err
+	}
+	if err := spendStatement(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	exit, err := s.enter(ctx, "read")
+	if err != nil {
+		return nil, err
+	}
+	defer exit()
+	defer spendTime(ctx, time.Now())
+	var res *spanner.ResultSet
+	err = s.client.withDirectedReads(ctx, tx, func(dro *spanner.DirectedReadOptions) (err error) {
+		res, err = s.rpc(ctx).Read(s.name, &spanner.ReadRequest{
+			Table:               table,
+			KeySet:              keys,
+			Columns:             columns,
+			Transaction:         tx,
+			DirectedReadOptions: dro,
+		}).Context(ctx).Do()
+		return err
+	})
+	s.client.reportError(ctx, "read "+table, "", err)
+	return res, errors.Wrap(err, "unable to read rows")
+}
+
+// ReadRow reads a single row by primary key and decodes it into dst, which must be
+// a pointer to a struct. If columns is empty, the columns are derived from the
+// `spanner` tags of dst. ErrRowNotFound is returned if the row does not exist.
+func (s *Session) ReadRow(ctx context.Context, table string, key Key, columns []string, dst interface{}) error {
+	t := reflect.TypeOf(dst)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return errors.Errorf("destination must be a pointer, got %T", dst)
+	}
+	rows := reflect.New(reflect.SliceOf(t.Elem()))
+	if err := s.BatchReadRows(ctx, table, []Key{key}, columns, rows.Interface()); err != nil {
+		return err
+	}
+	if rows.Elem().Len() == 0 {
+		return ErrRowNotFound
+	}
+	reflect.ValueOf(dst).Elem().Set(rows.Elem().Index(0))
+	return nil
+}
+
+// BatchReadRows reads all rows matching the given primary keys and decodes them
+// into results, which must be a pointer to a slice of structs or struct pointers.
+// If columns is empty, the columns are derived from the `spanner` tags of the slice
+// element type. Keys without a matching row are omitted and rows are returned in
+// primary key order.
+func (s *Session) BatchReadRows(ctx context.Context, table string, keys []Key, columns []string, results interface{}) error {
+	if len(columns) == 0 {
+		var err error
+		columns, err = columnsOf(results)
+		if err != nil {
+			return err
+		}
+	}
+	res, err := s.readLive(ctx, table, keySet(keys...), columns)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(decodeRows(res, results), "unable to decode rows")
+}
+
+// columnsOf returns the column names mapped by the struct type underlying v, which
+// may be a struct, a pointer to a struct or a pointer to a slice of either.
+func columnsOf(v interface{}) ([]string, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("unable to derive columns from %T", v)
+	}
+	fs := structFields(t)
+	cols := make([]string, len(fs))
+	for i, f := range fs {
+		cols[i] = f.name
+	}
+	return cols, nil
+}
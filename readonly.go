@@ -0,0 +1,107 @@
+package spannerr
+
+import (
+	"context"
+	"strings"
+)
+
+// ReadOnlyError is returned when a statement that may modify data is executed by a
+// Client or context restricted to read-only statements, or when a read-only Client
+// attempts any other write.
+type ReadOnlyError struct {
+	SQL string
+	// Op is the rejected operation, such as "commit", when it is not a
+	// statement.
+	Op string
+}
+
+func (e *ReadOnlyError) Error() string {
+	if e.SQL == "" {
+		return "client is read-only: " + e.Op + " not allowed"
+	}
+	return "statement is not read-only: " + e.SQL
+}
+
+type readOnlyKey struct{}
+
+// ReadOnlyContext returns a copy of ctx that restricts ExecuteSQL to read-only
+// statements, for analytics or reporting code paths that should never write.
+func ReadOnlyContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+func isReadOnlyContext(ctx context.Context) bool {
+	ro, _ := ctx.Value(readOnlyKey{}).(bool)
+	return ro
+}
+
+// checkReadOnly returns a *ReadOnlyError if read-only statements are required by the
+// Client or ctx and sql is not a query.
+func (c *Client) checkReadOnly(ctx context.Context, sql string) error {
+	if !c.ReadOnlyQueries && !c.ReadOnly && !isReadOnlyContext(ctx) {
+		return nil
+	}
+	if isQuery(sql) {
+		return nil
+	}
+	return &ReadOnlyError{SQL: sql}
+}
+
+// checkWrite returns a *ReadOnlyError if the Client is read-only.
+func (c *Client) checkWrite(op string) error {
+	if c == nil || !c.ReadOnly {
+		return nil
+	}
+	return &ReadOnlyError{Op: op}
+}
+
+// isQuery reports whether sql is a SELECT statement, optionally starting with a
+// WITH clause, parentheses, comments or statement hints.
+func isQuery(sql string) bool {
+	sql = stripHints(stripComments(sql))
+	sql = strings.TrimLeft(sql, " \t\r\n(")
+	i := strings.IndexFunc(sql, func(r rune) bool {
+		return !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if i >= 0 {
+		sql = sql[:i]
+	}
+	switch strings.ToUpper(sql) {
+	case "SELECT", "WITH":
+		// THEN RETURN is only valid on DML, so WITH and SELECT are always reads
+		return true
+	}
+	return false
+}
+
+// stripComments removes --, # and /* */ comments from sql, ignoring any found
+// within quoted strings.
+func stripComments(sql string) string {
+	var (
+		b  strings.Builder
+		rs = []rune(sql)
+	)
+	for i := 0; i < len(rs); i++ {
+		switch r := rs[i]; {
+		case r == '\'' || r == '"' || r == '`':
+			end := endOfQuoted(rs, i)
+			b.WriteString(string(rs[i:end]))
+			i = end - 1
+		case r == '-' && i+1 < len(rs) && rs[i+1] == '-', r == '#':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+			b.WriteRune(' ')
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			i += 2
+			for i+1 < len(rs) && !(rs[i] == '*' && rs[i+1] == '/') {
+				i++
+			}
+			i++
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
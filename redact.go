@@ -0,0 +1,106 @@
+package spannerr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Redacted replaces the value of sensitive parameters and columns in logs.
+const Redacted = "[REDACTED]"
+
+// isSensitive reports whether the parameter or column name should be redacted.
+func (c *Client) isSensitive(table, name string) bool {
+	for _, r := range c.Redact {
+		if strings.EqualFold(r, name) {
+			return true
+		}
+	}
+	if table == "" {
+		return false
+	}
+	// columns tagged sensitive in structs passed to the package-level builders
+	// are redacted by every Client, as redacting too much is harmless
+	return c.tables().isSensitive(table, name) || DefaultTables.isSensitive(table, name)
+}
+
+// redactParams returns the params as a name to value map suitable for logging,
+// with sensitive values masked.
+func (c *Client) redactParams(params []*Param) map[string]interface{} {
+	out := make(map[string]interface{}, len(params))
+	for _, p := range params {
+		if p.Sensitive || c.isSensitive("", p.Name) {
+			out[p.Name] = Redacted
+			continue
+		}
+		out[p.Name] = p.Value
+	}
+	return out
+}
+
+// redactMutations returns a loggable summary of the mutations with the values of
+// sensitive columns masked.
+func (c *Client) redactMutations(muts []*spanner.Mutation) []string {
+	out := make([]string, len(muts))
+	for i, m := range muts {
+		if m.Delete != nil {
+			out[i] = fmt.Sprintf("delete %s", m.Delete.Table)
+			continue
+		}
+		w, op := mutationWrite(m)
+		if w == nil {
+			continue
+		}
+		vals := make([]string, len(w.Columns))
+		for j, col := range w.Columns {
+			if c.isSensitive(w.Table, col) {
+				vals[j] = col + "=" + Redacted
+				continue
+			}
+			var rows []interface{}
+			for _, row := range w.Values {
+				if j < len(row) {
+					rows = append(rows, row[j])
+				}
+			}
+			vals[j] = fmt.Sprintf("%s=%v", col, rows)
+		}
+		out[i] = op + " " + w.Table + " " + strings.Join(vals, " ")
+	}
+	return out
+}
+
+// mutationWrite returns the Write of a non-delete mutation along with its operation name.
+func mutationWrite(m *spanner.Mutation) (*spanner.Write, string) {
+	switch {
+	case m.Insert != nil:
+		return m.Insert, "insert"
+	case m.Update != nil:
+		return m.Update, "update"
+	case m.InsertOrUpdate != nil:
+		return m.InsertOrUpdate, "insert_or_update"
+	case m.Replace != nil:
+		return m.Replace, "replace"
+	}
+	return nil, ""
+}
+
+// logStatement logs the statement and its redacted params if the Client has
+// statement logging enabled.
+func (c *Client) logStatement(ctx context.Context, sql string, params []*Param) {
+	if c == nil || !c.LogStatements {
+		return
+	}
+	c.logf(ctx, "execute sql: %q params: %v%s", sql, c.redactParams(params), c.metadataString(ctx))
+}
+
+// logCommit logs the mutations being committed, redacted, if the Client has
+// statement logging enabled.
+func (c *Client) logCommit(ctx context.Context, muts []*spanner.Mutation) {
+	if c == nil || !c.LogStatements {
+		return
+	}
+	c.logf(ctx, "commit: %s%s", strings.Join(c.redactMutations(muts), "; "), c.metadataString(ctx))
+}
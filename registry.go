@@ -0,0 +1,334 @@
+package spannerr
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Statement is a named SQL statement with a fixed parameter schema, registered
+// with a Registry at startup.
+type Statement struct {
+	Name string
+	SQL  string
+	// Params maps each parameter name used in SQL to its Spanner type, such as
+	// "INT64" or "ARRAY<STRING>".
+	Params map[string]string
+	// Unscoped exempts the statement from the Registry's TenantScope, for
+	// statements that legitimately span tenants.
+	Unscoped bool
+}
+
+// Registry holds named statements so all SQL issued by an application can be
+// reviewed in one place and validated against the database at startup.
+type Registry struct {
+	mu    sync.RWMutex
+	stmts map[string]*Statement
+
+	// Tenant, if set, scopes statements registered afterwards to the tenant
+	// carried by the ctx they are executed with; see TenantScope.
+	Tenant *TenantScope
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{stmts: map[string]*Statement{}}
+}
+
+var paramRE = regexp.MustCompile(`@([A-Za-z_][A-Za-z0-9_]*)`)
+
+// Register adds the given statements to the registry. It returns an error if a
+// name is already registered, if a parameter used in the SQL is not declared or
+// if a declared type is invalid. With a TenantScope, statements are rewritten
+// and declare the @__tenant param, and those that can't be rewritten are
+// rejected unless Unscoped.
+func (r *Registry) Register(stmts ...Statement) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range stmts {
+		st := stmts[i]
+		if st.Name == "" {
+			return errors.New("statement name is required")
+		}
+		if _, ok := r.stmts[st.Name]; ok {
+			return errors.Errorf("statement %q is already registered", st.Name)
+		}
+		if r.Tenant != nil && !st.Unscoped {
+			sql, err := r.Tenant.Rewrite(st.SQL)
+			if err != nil {
+				return errors.Wrapf(err, "unable to scope statement %q to tenants", st.Name)
+			}
+			params := map[string]string{TenantParam: r.Tenant.paramType()}
+			for name, typ := range st.Params {
+				params[name] = typ
+			}
+			st.SQL, st.Params = sql, params
+		}
+		for _, m := range paramRE.FindAllStringSubmatch(stripHints(st.SQL), -1) {
+			if _, ok := st.Params[m[1]]; !ok {
+				return errors.Errorf("statement %q uses undeclared parameter @%s", st.Name, m[1])
+			}
+		}
+		for name, typ := range st.Params {
+			if _, _, err := parseParamType(typ); err != nil {
+				return errors.Wrapf(err, "statement %q parameter %q", st.Name, name)
+			}
+		}
+		r.stmts[st.Name] = &st
+	}
+	return nil
+}
+
+// Lookup returns the statement registered under name.
+func (r *Registry) Lookup(name string) (*Statement, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	st, ok := r.stmts[name]
+	return st, ok
+}
+
+// Names returns the names of all registered statements, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.stmts))
+	for n := range r.stmts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Bind converts args into Params according to the statement's parameter schema.
+// Every declared parameter must be present in args. Values are encoded for their
+// declared type, so a time.Time bound to a DATE param is sent as its date in the
+// time's location, a string bound to BYTES as its bytes and a value bound to JSON
+// as its JSON encoding.
+func (st *Statement) Bind(args map[string]interface{}) ([]*Param, error) {
+	params := make([]*Param, 0, len(st.Params))
+	for name, typ := range st.Params {
+		v, ok := args[name]
+		if !ok {
+			return nil, errors.Errorf("statement %q missing argument %q", st.Name, name)
+		}
+		code, elem, err := parseParamType(typ)
+		if err != nil {
+			return nil, errors.Wrapf(err, "statement %q parameter %q", st.Name, name)
+		}
+		val, err := bindValue(v, code, elem)
+		if err != nil {
+			return nil, errors.Wrapf(err, "statement %q argument %q", st.Name, name)
+		}
+		params = append(params, &Param{Name: name, Value: val, Type: TypeCode(code), ArrayElementType: TypeCode(elem)})
+	}
+	for name := range args {
+		if _, ok := st.Params[name]; !ok {
+			return nil, errors.Errorf("statement %q has no parameter %q", st.Name, name)
+		}
+	}
+	return params, nil
+}
+
+// bindValue encodes v for a param of the given type code and, for arrays, element
+// type code.
+func bindValue(v interface{}, code, elem string) (interface{}, error) {
+	if isNull(v) {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	v = rv.Interface()
+	switch TypeCode(code) {
+	case TypeArray:
+		if _, ok := v.([]byte); ok || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+			return nil, errors.Errorf("expected a slice for ARRAY<%s>, got %T", elem, v)
+		}
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			ev, err := bindValue(rv.Index(i).Interface(), elem, "")
+			if err != nil {
+				return nil, errors.Wrapf(err, "element %d", i)
+			}
+			out[i] = ev
+		}
+		return out, nil
+	case TypeDate:
+		switch t := v.(type) {
+		case time.Time:
+			return civil.DateOf(t).String(), nil
+		case string:
+			if _, err := ParseDate(t); err != nil {
+				return nil, err
+			}
+		}
+	case TypeTimestamp:
+		switch t := v.(type) {
+		case civil.Date:
+			return nil, errors.Errorf("expected a time.Time for TIMESTAMP, got DATE %s", t)
+		case string:
+			if _, err := ParseTimestamp(t); err != nil {
+				return nil, err
+			}
+		}
+	case TypeBytes:
+		if t, ok := v.(string); ok {
+			return encodeValue([]byte(t)), nil
+		}
+	case TypeJSON:
+		switch t := v.(type) {
+		case string:
+			return t, nil
+		case []byte:
+			return string(t), nil
+		case json.RawMessage:
+			return string(t), nil
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to encode JSON")
+		}
+		return string(b), nil
+	}
+	return encodeValue(v), nil
+}
+
+// Validate plans every registered statement against the database, returning an
+// error describing each statement that no longer matches the schema. Call this at
+// startup or in CI to catch schema drift before it reaches a user request.
+func (r *Registry) Validate(ctx context.Context, s *Session) error {
+	var errs []string
+	for _, name := range r.Names() {
+		st, _ := r.Lookup(name)
+		if err := st.validate(ctx, s); err != nil {
+			errs = append(errs, name+": "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("invalid statements:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+func (st *Statement) validate(ctx context.Context, s *Session) error {
+	res, err := st.plan(ctx, s)
+	if err != nil {
+		return err
+	}
+	if res.Metadata != nil && res.Metadata.UndeclaredParameters != nil &&
+		len(res.Metadata.UndeclaredParameters.Fields) > 0 {
+		return errors.Errorf("undeclared parameter %q", res.Metadata.UndeclaredParameters.Fields[0].Name)
+	}
+	return nil
+}
+
+// plan executes the statement in PLAN mode, which returns its result schema.
+func (st *Statement) plan(ctx context.Context, s *Session) (*spanner.ResultSet, error) {
+	params := make([]*Param, 0, len(st.Params))
+	for name, typ := range st.Params {
+		code, elem, _ := parseParamType(typ)
+		params = append(params, &Param{Name: name, Type: TypeCode(code), ArrayElementType: TypeCode(elem)})
+	}
+	var tx *spanner.TransactionSelector
+	if isDML(st.SQL) {
+		// DML can only be planned within a read-write transaction
+		txn, err := s.BeginReadWrite(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer txn.Rollback(ctx)
+		tx = txn.Selector()
+	}
+	return s.ExecuteSQL(ctx, params, st.SQL, QueryModePlan, tx)
+}
+
+// Exec executes the statement registered with the Client's Registry under name.
+// If ctx carries a transaction the statement runs within it, otherwise it runs on
+// a pooled session as a single-use read. Tenant scoped statements are passed the
+// tenant attached to ctx with WithTenant.
+func (c *Client) Exec(ctx context.Context, name string, args map[string]interface{}) (*spanner.ResultSet, error) {
+	if c.Registry == nil {
+		return nil, errors.New("no statement registry configured")
+	}
+	st, ok := c.Registry.Lookup(name)
+	if !ok {
+		return nil, errors.Errorf("unknown statement %q", name)
+	}
+	args, err := st.tenantArgs(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	params, err := st.Bind(args)
+	if err != nil {
+		return nil, err
+	}
+	if txn, ok := FromContextTxn(ctx); ok {
+		return txn.Session.ExecuteSQL(ctx, params, st.SQL, QueryModeNormal, txn.Selector())
+	}
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.ReleaseSession(ctx, *sess)
+	return sess.ExecuteSQL(ctx, params, st.SQL, QueryModeNormal, nil)
+}
+
+// ExecInto executes the named statement like Exec and decodes all resulting rows
+// into dst, a pointer to a slice of structs.
+func (c *Client) ExecInto(ctx context.Context, name string, args map[string]interface{}, dst interface{}) error {
+	res, err := c.Exec(ctx, name, args)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(decodeRows(res, dst), "unable to decode query results")
+}
+
+// parseParamType splits a type such as "ARRAY<INT64>" into its type code and array
+// element type code.
+func parseParamType(typ string) (string, string, error) {
+	typ = strings.ToUpper(strings.TrimSpace(typ))
+	if strings.HasPrefix(typ, "ARRAY<") && strings.HasSuffix(typ, ">") {
+		elem := strings.TrimSuffix(strings.TrimPrefix(typ, "ARRAY<"), ">")
+		if !identRE.MatchString(elem) {
+			return "", "", errors.Errorf("invalid array element type %q", elem)
+		}
+		return "ARRAY", elem, nil
+	}
+	if !identRE.MatchString(typ) {
+		return "", "", errors.Errorf("invalid type %q", typ)
+	}
+	return typ, "", nil
+}
+
+// isDML reports whether sql is an INSERT, UPDATE or DELETE statement.
+func isDML(sql string) bool {
+	fields := strings.Fields(stripHints(sql))
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "INSERT", "UPDATE", "DELETE":
+		return true
+	}
+	return false
+}
+
+var hintRE = regexp.MustCompile(`@\{[^}]*\}`)
+
+// stripHints removes query hints from sql.
+func stripHints(sql string) string {
+	return hintRE.ReplaceAllString(sql, " ")
+}
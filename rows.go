@@ -0,0 +1,124 @@
+package spannerr
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Rows iterates over the rows of a ResultSet returned by ExecuteSQL, decoding
+// Spanner's JSON encoding of each value into Go values. Its usage mirrors
+// RowIterator:
+//
+//	rows := spannerr.NewRows(res)
+//	for rows.Next() {
+//		var (
+//			id   int64
+//			name string
+//		)
+//		if err := rows.Scan(&id, &name); err != nil {
+//			return err
+//		}
+//		...
+//	}
+type Rows struct {
+	rs  *spanner.ResultSet
+	i   int
+	row []interface{}
+}
+
+// NewRows returns an iterator over the rows of rs.
+func NewRows(rs *spanner.ResultSet) *Rows {
+	if rs == nil {
+		rs = &spanner.ResultSet{}
+	}
+	return &Rows{rs: rs}
+}
+
+// Next advances to the next row, returning false when there are no more rows.
+func (r *Rows) Next() bool {
+	if r.i >= len(r.rs.Rows) {
+		r.row = nil
+		return false
+	}
+	r.row = r.rs.Rows[r.i]
+	r.i++
+	return true
+}
+
+// Row returns the raw values of the current row.
+func (r *Rows) Row() []interface{} {
+	return r.row
+}
+
+// Columns returns the columns of the result set.
+func (r *Rows) Columns() []Column {
+	return Columns(r.rs.Metadata)
+}
+
+// Scan decodes the columns of the current row into dest, which must hold a
+// pointer for each column, in order. A nil pointer skips its column. NULL values
+// set their destination to its zero value; scan into a pointer to a pointer to
+// tell them apart.
+func (r *Rows) Scan(dest ...interface{}) error {
+	return scanRow(rowFields(r.rs.Metadata), r.row, dest)
+}
+
+// ScanStruct decodes the current row into the struct pointed to by ptr, matching
+// columns to fields by their `spanner:"col"` tags or, failing that, their names.
+func (r *Rows) ScanStruct(ptr interface{}) error {
+	if r.row == nil {
+		return errors.New("no current row")
+	}
+	return decodeStruct(rowFields(r.rs.Metadata), r.row, ptr)
+}
+
+// Scan decodes the columns of the current row into dest, like Rows.Scan.
+func (it *RowIterator) Scan(dest ...interface{}) error {
+	return scanRow(rowFields(it.metadata), it.row, dest)
+}
+
+// ScanStruct decodes the current row into the struct pointed to by ptr, like
+// Rows.ScanStruct.
+func (it *RowIterator) ScanStruct(ptr interface{}) error {
+	if it.row == nil {
+		return errors.New("no current row")
+	}
+	return decodeStruct(rowFields(it.metadata), it.row, ptr)
+}
+
+func rowFields(md *spanner.ResultSetMetadata) []*spanner.Field {
+	if md == nil || md.RowType == nil {
+		return nil
+	}
+	return md.RowType.Fields
+}
+
+// scanRow decodes each value of row into the matching pointer in dest.
+func scanRow(fields []*spanner.Field, row []interface{}, dest []interface{}) error {
+	if row == nil {
+		return errors.New("no current row")
+	}
+	if len(dest) != len(row) {
+		return errors.Errorf("expected %d destinations, got %d", len(row), len(dest))
+	}
+	for i, d := range dest {
+		if d == nil {
+			continue
+		}
+		v := reflect.ValueOf(d)
+		if v.Kind() != reflect.Ptr || v.IsNil() {
+			return errors.Errorf("destination %d must be a non-nil pointer, got %T", i, d)
+		}
+		var t *spanner.Type
+		name := ""
+		if i < len(fields) {
+			t, name = fields[i].Type, fields[i].Name
+		}
+		if err := decodeValue(row[i], t, v.Elem()); err != nil {
+			return errors.Wrapf(err, "unable to decode column %d %q", i, name)
+		}
+	}
+	return nil
+}
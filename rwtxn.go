@@ -0,0 +1,92 @@
+package spannerr
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// retry backoff bounds for ReadWriteTransaction.
+const (
+	minRetryDelay = 20 * time.Millisecond
+	maxRetryDelay = time.Second
+)
+
+// ReadWriteTransaction runs fn in a read-write transaction on a pooled session and
+// commits it. If Spanner aborts the transaction, whether while fn runs or on
+// commit, the whole of fn is retried in a new transaction after a backoff, until
+// it commits or ctx is done. fn must therefore be safe to run more than once and
+// should return errors from the transaction unchanged, or wrapped, so aborts are
+// recognized. If fn returns any other error the transaction is rolled back and
+// the error returned.
+//
+// If the pooled session turns out to have expired, the transaction is lost with
+// it and is likewise retried, on a newly created session. Other commit errors,
+// such as UNAVAILABLE or DEADLINE_EXCEEDED, are not retried: the commit may have
+// been applied, and running fn again could apply its writes twice.
+//
+// The transaction is passed to fn and carried by its ctx, so Client.Query and
+// Client.Apply participate in it. If ctx already carries a transaction fn is run
+// in it once, leaving the commit and any retry to the caller.
+func (c *Client) ReadWriteTransaction(ctx context.Context, fn func(ctx context.Context, txn *Txn) error) (*spanner.CommitResponse, error) {
+	if txn, ok := FromContextTxn(ctx); ok {
+		return nil, protect(func() error { return fn(ctx, txn) })
+	}
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { c.ReleaseSession(ctx, *sess) }()
+
+	delay := minRetryDelay
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			c.recordRetry(ctx)
+			// full jitter spreads out transactions that aborted each other
+			select {
+			case <-ctx.Done():
+				return nil, errors.Wrap(ctx.Err(), "transaction not retried")
+			case <-time.After(time.Duration(rand.Int63n(int64(delay)))):
+			}
+			if delay *= 2; delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+			if err := c.checkBudget(ctx, "transaction retry"); err != nil {
+				return nil, err
+			}
+		}
+		res, err := runReadWrite(ctx, sess, fn)
+		if isSessionNotFound(err) && c.dropExpired(ctx, sess) {
+			fresh, ferr := c.freshSession(ctx)
+			if ferr != nil {
+				c.logf(ctx, "unable to replace expired session %s: %s", sess.name, ferr)
+				return nil, err
+			}
+			c.ReleaseSession(ctx, *sess)
+			sess = fresh
+			continue
+		}
+		if !IsAborted(err) {
+			return res, err
+		}
+	}
+}
+
+// runReadWrite makes a single attempt at running and committing fn.
+func runReadWrite(ctx context.Context, sess *Session, fn func(context.Context, *Txn) error) (*spanner.CommitResponse, error) {
+	txn, err := sess.BeginReadWrite(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := protect(func() error { return fn(NewContext(ctx, txn), txn) }); err != nil {
+		if !IsAborted(err) {
+			txn.Rollback(ctx)
+		}
+		return nil, err
+	}
+	res, err := txn.Commit(ctx)
+	return res, errors.Wrap(err, "unable to commit transaction")
+}
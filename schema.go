@@ -0,0 +1,124 @@
+package spannerr
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// PrimaryKey returns the primary key columns of the given table in key order.
+// Results are looked up via INFORMATION_SCHEMA and cached on the Client.
+func (s *Session) PrimaryKey(ctx context.Context, table string) ([]string, error) {
+	c := s.client
+	c.schemaMu.Lock()
+	cols, ok := c.primaryKey[table]
+	c.schemaMu.Unlock()
+	if ok {
+		return cols, nil
+	}
+
+	var rows []struct {
+		Name string `spanner:"COLUMN_NAME"`
+	}
+	err := s.query(ctx, "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.INDEX_COLUMNS"+
+		" WHERE TABLE_SCHEMA = @schema AND TABLE_NAME = @table AND INDEX_NAME = @index"+
+		" ORDER BY ORDINAL_POSITION", []*Param{
+		{Name: "schema", Value: "", Type: TypeString},
+		{Name: "table", Value: table, Type: TypeString},
+		{Name: "index", Value: "PRIMARY_KEY", Type: TypeString},
+	}, nil, &rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to look up primary key")
+	}
+	if len(rows) == 0 {
+		return nil, errors.Errorf("table %q not found", table)
+	}
+	cols = make([]string, len(rows))
+	for i, r := range rows {
+		cols[i] = r.Name
+	}
+
+	c.schemaMu.Lock()
+	if c.primaryKey == nil {
+		c.primaryKey = map[string][]string{}
+	}
+	c.primaryKey[table] = cols
+	c.schemaMu.Unlock()
+	return cols, nil
+}
+
+// ChildTable describes a table interleaved in a parent table.
+type ChildTable struct {
+	Name string `spanner:"TABLE_NAME"`
+	// OnDelete is the ON DELETE action of the interleave, either "CASCADE"
+	// or "NO ACTION".
+	OnDelete string `spanner:"ON_DELETE_ACTION"`
+}
+
+// InterleavedChildren returns the tables directly interleaved in the given table.
+func (s *Session) InterleavedChildren(ctx context.Context, table string) ([]*ChildTable, error) {
+	var children []*ChildTable
+	err := s.query(ctx, "SELECT TABLE_NAME, ON_DELETE_ACTION FROM INFORMATION_SCHEMA.TABLES"+
+		" WHERE TABLE_SCHEMA = @schema AND PARENT_TABLE_NAME = @table ORDER BY TABLE_NAME",
+		[]*Param{
+			{Name: "schema", Value: "", Type: TypeString},
+			{Name: "table", Value: table, Type: TypeString},
+		}, nil, &children)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to look up interleaved tables")
+	}
+	for _, c := range children {
+		s.client.tables().RegisterInterleave(c.Name, table)
+	}
+	return children, nil
+}
+
+// ForeignKey describes a foreign key referencing a table.
+type ForeignKey struct {
+	Name string
+	// Table is the referencing table.
+	Table string
+	// Columns are the referencing columns of Table.
+	Columns []string
+	// ReferencedColumns are the columns of the referenced table, in the same
+	// order as Columns.
+	ReferencedColumns []string
+	// OnDelete is the delete rule of the foreign key, either "CASCADE" or "NO ACTION".
+	OnDelete string
+}
+
+// ReferencingForeignKeys returns the foreign keys in other tables that reference
+// the given table.
+func (s *Session) ReferencingForeignKeys(ctx context.Context, table string) ([]*ForeignKey, error) {
+	var rows []struct {
+		Name       string `spanner:"CONSTRAINT_NAME"`
+		Table      string `spanner:"TABLE_NAME"`
+		Column     string `spanner:"COLUMN_NAME"`
+		Referenced string `spanner:"REFERENCED_COLUMN"`
+		OnDelete   string `spanner:"DELETE_RULE"`
+	}
+	err := s.query(ctx, "SELECT rc.CONSTRAINT_NAME, kcu.TABLE_NAME, kcu.COLUMN_NAME,"+
+		" ref.COLUMN_NAME AS REFERENCED_COLUMN, rc.DELETE_RULE"+
+		" FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS AS rc"+
+		" JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS kcu"+
+		" ON kcu.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND kcu.CONSTRAINT_NAME = rc.CONSTRAINT_NAME"+
+		" JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS ref"+
+		" ON ref.CONSTRAINT_SCHEMA = rc.UNIQUE_CONSTRAINT_SCHEMA AND ref.CONSTRAINT_NAME = rc.UNIQUE_CONSTRAINT_NAME"+
+		" AND ref.ORDINAL_POSITION = kcu.POSITION_IN_UNIQUE_CONSTRAINT"+
+		" WHERE ref.TABLE_NAME = @table"+
+		" ORDER BY rc.CONSTRAINT_NAME, kcu.ORDINAL_POSITION",
+		[]*Param{{Name: "table", Value: table, Type: TypeString}}, nil, &rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to look up foreign keys")
+	}
+	var fks []*ForeignKey
+	for _, r := range rows {
+		if len(fks) == 0 || fks[len(fks)-1].Name != r.Name {
+			fks = append(fks, &ForeignKey{Name: r.Name, Table: r.Table, OnDelete: r.OnDelete})
+		}
+		fk := fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, r.Column)
+		fk.ReferencedColumns = append(fk.ReferencedColumns, r.Referenced)
+	}
+	return fks, nil
+}
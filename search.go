@@ -0,0 +1,122 @@
+package spannerr
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SearchScoreColumn is the name of the relevance score column returned by Search.
+// Map it with a float64 field tagged `spanner:"search_score"` to decode scores.
+const SearchScoreColumn = "search_score"
+
+// SearchQuery describes a full-text search over a TOKENLIST column backed by a
+// search index. More details can be found here: https://cloud.google.com/spanner/docs/full-text-search
+type SearchQuery struct {
+	// Table is the table to search.
+	Table string
+	// Tokens is the TOKENLIST column to search, e.g. "AlbumTitle_Tokens".
+	Tokens string
+	// Query is the raw search query in Spanner's search query syntax, such as
+	// "fifth symphony -live". It is always passed as a parameter.
+	Query string
+	// Columns are the columns to return. If empty, they are derived from the
+	// `spanner` tags of the destination.
+	Columns []string
+	// Where is an optional additional predicate ANDed with the search, which may
+	// reference Params.
+	Where  string
+	Params []*Param
+	// Enhance enables query enhancement, such as spelling correction and synonyms.
+	Enhance bool
+	// Limit caps the number of results. Zero means no limit.
+	Limit int64
+}
+
+// SearchPredicate returns a SEARCH function call over the tokens column using the
+// given query parameter name, for use in a WHERE clause.
+func SearchPredicate(tokens, param string, enhance bool) (string, error) {
+	return searchCall("SEARCH", tokens, param, enhance)
+}
+
+// ScoreExpr returns a SCORE function call over the tokens column using the given
+// query parameter name, for use in a SELECT list or ORDER BY clause.
+func ScoreExpr(tokens, param string, enhance bool) (string, error) {
+	return searchCall("SCORE", tokens, param, enhance)
+}
+
+func searchCall(fn, tokens, param string, enhance bool) (string, error) {
+	qt, err := quoteIdent(tokens)
+	if err != nil {
+		return "", err
+	}
+	if !identRE.MatchString(param) {
+		return "", errors.Errorf("invalid parameter name %q", param)
+	}
+	call := fn + "(" + qt + ", @" + param
+	if enhance {
+		call += ", enhance_query=>TRUE"
+	}
+	return call + ")", nil
+}
+
+// SQL renders the search as a parameterized statement ordered by relevance.
+func (q SearchQuery) SQL() (string, []*Param, error) {
+	if len(q.Columns) == 0 {
+		return "", nil, errors.New("no columns selected for search")
+	}
+	qt, err := quoteIdent(q.Table)
+	if err != nil {
+		return "", nil, err
+	}
+	cols := make([]string, len(q.Columns))
+	for i, c := range q.Columns {
+		if cols[i], err = quoteIdent(c); err != nil {
+			return "", nil, err
+		}
+	}
+	pred, err := SearchPredicate(q.Tokens, "search_query", q.Enhance)
+	if err != nil {
+		return "", nil, err
+	}
+	score, _ := ScoreExpr(q.Tokens, "search_query", q.Enhance)
+
+	sql := "SELECT " + strings.Join(cols, ", ") + ", " + score + " AS " + SearchScoreColumn +
+		" FROM " + qt + " WHERE " + pred
+	if q.Where != "" {
+		sql += " AND (" + q.Where + ")"
+	}
+	sql += " ORDER BY " + SearchScoreColumn + " DESC"
+	params := append([]*Param{{Name: "search_query", Value: q.Query, Type: TypeString}}, q.Params...)
+	if q.Limit > 0 {
+		sql += " LIMIT @search_limit"
+		params = append(params, &Param{Name: "search_limit", Value: strconv.FormatInt(q.Limit, 10), Type: TypeInt64})
+	}
+	return sql, params, nil
+}
+
+// Search executes the full-text search and decodes the matching rows, most relevant
+// first, into dst, which must be a pointer to a slice of structs.
+func (s *Session) Search(ctx context.Context, q SearchQuery, dst interface{}) error {
+	if err := s.client.requireFeatures(ctx, FeatureGoogleSQL, FeatureSearchIndexes); err != nil {
+		return err
+	}
+	if len(q.Columns) == 0 {
+		cols, err := columnsOf(dst)
+		if err != nil {
+			return err
+		}
+		for _, c := range cols {
+			if c != SearchScoreColumn {
+				q.Columns = append(q.Columns, c)
+			}
+		}
+	}
+	sql, params, err := q.SQL()
+	if err != nil {
+		return err
+	}
+	return s.query(ctx, sql, params, nil, dst)
+}
@@ -0,0 +1,183 @@
+package spannerr
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	secretmanager "google.golang.org/api/secretmanager/v1"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// SecretString returns the payload of the given Secret Manager secret version, such
+// as "projects/my-project/secrets/spanner-database/versions/latest". It can be used
+// to resolve the project, instance or database passed to NewClient at startup.
+func SecretString(ctx context.Context, version string) (string, error) {
+	b, err := accessSecret(ctx, version)
+	return string(b), err
+}
+
+// SecretTokenSource returns a TokenSource backed by the service account key JSON
+// stored in the given Secret Manager secret version. The secret is fetched
+// immediately, so configuration errors surface at startup, and fetched again once
+// refresh has elapsed so rotated keys are picked up without a redeploy. Assign the
+// result to Client.TokenSource. It issues tokens for the spanner.data scope and,
+// being a ScopedTokenSource, for the scopes of the other APIs the Client calls.
+//
+// ctx is only used for the initial fetch; later fetches and token requests run in
+// the background, independent of it, and failed refreshes are logged through the
+// logger of the Client using the token source.
+func SecretTokenSource(ctx context.Context, version string, refresh time.Duration) (oauth2.TokenSource, error) {
+	k := &secretKey{version: version, refresh: refresh}
+	if err := k.load(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := google.CredentialsFromJSON(ctx, k.key, spanner.SpannerDataScope); err != nil {
+		return nil, errors.Wrap(err, "unable to parse credentials from secret")
+	}
+	return k.source(spanner.SpannerDataScope), nil
+}
+
+// secretRetry is how long a secretKey waits before fetching the secret again
+// after a failed refresh, unless its refresh period is shorter.
+const secretRetry = time.Minute
+
+// secretKey is a service account key stored in Secret Manager.
+type secretKey struct {
+	version string
+	refresh time.Duration
+
+	mu  sync.Mutex
+	key []byte
+	// logf reports failed refreshes; see setLogf.
+	logf func(ctx context.Context, format string, args ...interface{})
+	// next is when the secret is fetched again.
+	next time.Time
+	// sources are the token sources made from the key, by scopes.
+	sources map[string]*secretTokenSource
+}
+
+func (k *secretKey) load(ctx context.Context) error {
+	key, err := accessSecret(ctx, k.version)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.key = key
+	k.next = time.Now().Add(k.refresh)
+	k.mu.Unlock()
+	return nil
+}
+
+// get returns the key, fetching it again first if refresh has elapsed. If that
+// fails the error is logged and the previous key, which is likely still valid,
+// is returned; the secret is fetched again after secretRetry.
+func (k *secretKey) get() []byte {
+	k.mu.Lock()
+	due := k.refresh > 0 && !time.Now().Before(k.next)
+	if due {
+		// claim the refresh so concurrent callers keep using the current key
+		retry := secretRetry
+		if k.refresh < 2*retry {
+			retry = k.refresh / 2
+		}
+		k.next = time.Now().Add(retry)
+	}
+	logf := k.logf
+	k.mu.Unlock()
+	if due {
+		// refreshes outlive the context of whichever request triggered them
+		ctx := context.Background()
+		if err := k.load(ctx); err != nil {
+			if logf == nil {
+				logf = (&Client{}).logf
+			}
+			logf(ctx, "unable to refresh credentials from secret %s, keeping the previous ones: %s", k.version, err)
+		}
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.key
+}
+
+// setLogf makes the key report failed refreshes through logf.
+func (k *secretKey) setLogf(logf func(ctx context.Context, format string, args ...interface{})) {
+	k.mu.Lock()
+	k.logf = logf
+	k.mu.Unlock()
+}
+
+// source returns the token source issuing tokens for scopes from the key.
+func (k *secretKey) source(scopes ...string) *secretTokenSource {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	id := strings.Join(scopes, " ")
+	if k.sources == nil {
+		k.sources = map[string]*secretTokenSource{}
+	}
+	ts, ok := k.sources[id]
+	if !ok {
+		ts = &secretTokenSource{key: k, scopes: scopes}
+		k.sources[id] = ts
+	}
+	return ts
+}
+
+type secretTokenSource struct {
+	key    *secretKey
+	scopes []string
+
+	mu sync.Mutex
+	ts oauth2.TokenSource
+	// used is the key ts was made from.
+	used []byte
+}
+
+func (s *secretTokenSource) Token() (*oauth2.Token, error) {
+	key := s.key.get()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ts == nil || !bytes.Equal(key, s.used) {
+		creds, err := google.CredentialsFromJSON(context.Background(), key, s.scopes...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to parse credentials from secret")
+		}
+		s.ts, s.used = oauth2.ReuseTokenSource(nil, creds.TokenSource), key
+	}
+	return s.ts.Token()
+}
+
+func (s *secretTokenSource) setLogf(logf func(ctx context.Context, format string, args ...interface{})) {
+	s.key.setLogf(logf)
+}
+
+// WithScopes implements ScopedTokenSource.
+func (s *secretTokenSource) WithScopes(scopes ...string) oauth2.TokenSource {
+	return s.key.source(scopes...)
+}
+
+func accessSecret(ctx context.Context, version string) ([]byte, error) {
+	hc, err := google.DefaultClient(ctx, secretmanager.CloudPlatformScope)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to init default client")
+	}
+	svc, err := secretmanager.New(hc)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to init secret manager service")
+	}
+	resp, err := svc.Projects.Secrets.Versions.Access(version).Context(ctx).Do()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to access secret")
+	}
+	if resp.Payload == nil {
+		return nil, errors.Errorf("secret %q has no payload", version)
+	}
+	b, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	return b, errors.Wrap(err, "unable to decode secret payload")
+}
@@ -0,0 +1,132 @@
+package spannerr
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// NextSequenceValues returns the next n values of the named sequence, for keys
+// that must be known before rows are written, such as those of interleaved child
+// rows. Sequence values are only allocated within read-write transactions.
+// More details can be found here: https://cloud.google.com/spanner/docs/primary-key-default-value
+func (t *Txn) NextSequenceValues(ctx context.Context, sequence string, n int) ([]int64, error) {
+	if err := t.Session.client.requireFeatures(ctx, FeatureGoogleSQL, FeatureSequences); err != nil {
+		return nil, err
+	}
+	q, err := quoteIdent(sequence)
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.ExecuteSQL(ctx, []*Param{{Name: "n", Value: strconv.Itoa(n), Type: TypeInt64}},
+		"SELECT GET_NEXT_SEQUENCE_VALUE(SEQUENCE "+q+") FROM UNNEST(GENERATE_ARRAY(1, @n))")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get sequence values")
+	}
+	vals := make([]int64, 0, n)
+	for _, row := range res.Rows {
+		if len(row) == 0 {
+			continue
+		}
+		s, _ := row[0].(string)
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to decode sequence value")
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// InsertReturning inserts v into table with DML and sets the columns of v tagged
+// with the generated option, such as IDENTITY columns or columns defaulting to
+// GET_NEXT_SEQUENCE_VALUE, from the values Spanner assigned, using THEN RETURN.
+// v must be a pointer to a struct. Generated columns that are non-zero are
+// inserted as given.
+//
+//	type Singer struct {
+//		SingerID int64  `spanner:"SingerId,pk,generated"`
+//		Name     string `spanner:"Name"`
+//	}
+func (t *Txn) InsertReturning(ctx context.Context, table string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.Errorf("destination must be a non-nil pointer, got %T", v)
+	}
+	tq, err := quoteIdent(table)
+	if err != nil {
+		return err
+	}
+	sv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	var (
+		cols, vals, returning []string
+		params                []*Param
+	)
+	for _, f := range keyFirst(sv.Type()) {
+		cq, err := quoteIdent(f.name)
+		if err != nil {
+			return err
+		}
+		fv := sv.FieldByIndex(f.index)
+		if f.hasOpt("generated") {
+			returning = append(returning, cq)
+			if fv.IsZero() {
+				continue
+			}
+		}
+		name := "p" + strconv.Itoa(len(params))
+		p := valueParam(name, fv.Interface())
+		p.Sensitive = f.hasOpt("sensitive")
+		params = append(params, p)
+		cols = append(cols, cq)
+		vals = append(vals, "@"+name)
+	}
+	if len(returning) == 0 {
+		return errors.Errorf("%T has no generated columns", v)
+	}
+	sql := "INSERT INTO " + tq + " (" + strings.Join(cols, ", ") + ") VALUES (" +
+		strings.Join(vals, ", ") + ") THEN RETURN " + strings.Join(returning, ", ")
+	res, err := t.ExecuteSQL(ctx, params, sql)
+	if err != nil {
+		return errors.Wrap(err, "unable to insert row")
+	}
+	if len(res.Rows) != 1 || res.Metadata == nil || res.Metadata.RowType == nil {
+		return errors.New("insert returned no generated values")
+	}
+	return errors.Wrap(decodeStruct(res.Metadata.RowType.Fields, res.Rows[0], v),
+		"unable to decode generated values")
+}
+
+// InsertReturning is Txn.InsertReturning using the transaction carried by ctx or,
+// if there is none, a new read-write transaction on a pooled session.
+func (c *Client) InsertReturning(ctx context.Context, table string, v interface{}) error {
+	if txn, ok := FromContextTxn(ctx); ok {
+		return txn.InsertReturning(ctx, table, v)
+	}
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.ReleaseSession(ctx, *sess)
+	txn, err := sess.BeginReadWrite(ctx)
+	if err != nil {
+		return err
+	}
+	if err := txn.InsertReturning(ctx, table, v); err != nil {
+		txn.Rollback(ctx)
+		return err
+	}
+	_, err = txn.Commit(ctx)
+	return errors.Wrap(err, "unable to commit insert")
+}
+
+// skipGenerated reports whether f is a generated column left for Spanner to fill.
+func skipGenerated(f fieldInfo, v reflect.Value) bool {
+	return f.hasOpt("generated") && v.IsZero()
+}
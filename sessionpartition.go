@@ -0,0 +1,55 @@
+package spannerr
+
+import (
+	"context"
+
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// SessionPartition identifies a segregated part of a Client's session pool.
+// Sessions are only ever handed out to callers asking for the partition they
+// were created for, so a session created with broad privileges is never used
+// for work that should run with narrower ones.
+type SessionPartition struct {
+	// Role is the fine-grained access control database role sessions are
+	// created with. Empty uses the database-level IAM permissions of the
+	// Client's credentials.
+	Role string
+	// Tenant, if set, is recorded as the "tenant" label of sessions. It must be
+	// a valid label value: lowercase letters, digits, underscores and dashes.
+	Tenant string
+}
+
+type sessionPartitionKey struct{}
+
+// WithSessionPartition returns a copy of ctx for which AcquireSession, and every
+// Client method using it, only returns sessions of the given partition:
+//
+//	ctx = spannerr.WithSessionPartition(ctx, spannerr.SessionPartition{Role: "reporting"})
+//	err := client.Query(ctx, sql, params, &rows)
+func WithSessionPartition(ctx context.Context, p SessionPartition) context.Context {
+	return context.WithValue(ctx, sessionPartitionKey{}, p)
+}
+
+// sessionPartition returns the partition requested by ctx.
+func sessionPartition(ctx context.Context) SessionPartition {
+	p, _ := ctx.Value(sessionPartitionKey{}).(SessionPartition)
+	return p
+}
+
+// session returns the Session resource to create for the partition.
+func (p SessionPartition) session() *spanner.Session {
+	labels := sessionLabels
+	if p.Tenant != "" {
+		labels = map[string]string{"tenant": p.Tenant}
+		for k, v := range sessionLabels {
+			labels[k] = v
+		}
+	}
+	return &spanner.Session{Labels: labels, CreatorRole: p.Role}
+}
+
+// matches reports whether the server-side session s was created for the partition.
+func (p SessionPartition) matches(s *spanner.Session) bool {
+	return s.CreatorRole == p.Role && s.Labels["tenant"] == p.Tenant
+}
@@ -0,0 +1,76 @@
+package spannerr
+
+import (
+	"context"
+	"strings"
+)
+
+// isSessionNotFound reports whether err is Spanner's "Session not found" error,
+// returned for sessions it deleted after an hour of inactivity or for other
+// reasons.
+func isSessionNotFound(err error) bool {
+	return ErrorCode(err) == "NOT_FOUND" && strings.Contains(strings.ToLower(err.Error()), "session not found")
+}
+
+// retryExpired handles a "Session not found" err returned for a call on the
+// pooled session s: the dead session is dropped from the Client's pool and, if
+// retry is set, call is retried once on a newly created session, which is
+// released again right after. Other idle sessions may be as old as s and have
+// expired too, so they are not reused for the retry. s itself is left as it is, so later calls on it
+// are retried the same way until it is released. Calls within a transaction
+// must not be retried, as the transaction was lost with the session. It returns
+// the error of the retry, or err if call was not retried.
+func (s *Session) retryExpired(ctx context.Context, err error, retry bool, call func(*Session) error) error {
+	c := s.client
+	if c == nil || !isSessionNotFound(err) {
+		return err
+	}
+	if !c.dropExpired(ctx, s) {
+		return err
+	}
+	if !retry {
+		c.logf(ctx, "session %s expired during a transaction: %s", s.name, err)
+		return err
+	}
+	fresh, aerr := c.freshSession(ctx)
+	if aerr != nil {
+		c.logf(ctx, "unable to replace expired session %s: %s", s.name, aerr)
+		return err
+	}
+	defer c.ReleaseSession(ctx, *fresh)
+	c.logf(ctx, "session %s expired, retrying on %s: %s", s.name, fresh.name, err)
+	return call(fresh)
+}
+
+// dropExpired drops the pooled session s, which Spanner reported as not found,
+// from the Client's pool, reporting whether s was pooled.
+func (c *Client) dropExpired(ctx context.Context, s *Session) bool {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	if _, pooled := c.sessions[s.name]; pooled {
+		c.deleteSession(ctx, s.name)
+		// remembered until the caller releases s
+		c.expired[s.name] = true
+	}
+	return c.expired[s.name]
+}
+
+// freshSession creates a new pooled session, making room for it by deleting an
+// idle session if the pool is full.
+func (c *Client) freshSession(ctx context.Context) (*Session, error) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	c.renewSlots(ctx)
+	if len(c.sessions) >= c.maxSessions {
+		for name, info := range c.sessions {
+			if !info.inUse {
+				c.deleteSession(ctx, name)
+				break
+			}
+		}
+	}
+	if len(c.sessions) >= c.maxSessions {
+		return nil, ErrPoolExhausted
+	}
+	return c.createSession(ctx)
+}
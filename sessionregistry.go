@@ -0,0 +1,287 @@
+package spannerr
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/memcache"
+)
+
+// ErrSessionCap is returned by AcquireSession when the Client's SessionRegistry has
+// no free slots and no local session is available.
+var ErrSessionCap = errors.New("service-wide session cap reached")
+
+// registryRenewInterval is how often a Client renews its SessionRegistry slots.
+const registryRenewInterval = time.Minute
+
+// SessionRegistry tracks which instance of a service owns each of a fixed number
+// of session slots, capping the total number of sessions across all instances.
+// Slots are leased, so the sessions of instances that go away are reassigned to
+// other instances instead of being leaked.
+type SessionRegistry interface {
+	// Claim leases a free slot for owner, returning ErrSessionCap if there are
+	// none. If the slot's previous owner let its lease expire, the name of the
+	// session it held is returned so it can be adopted.
+	Claim(ctx context.Context, owner string) (slot int, session string, err error)
+	// Assign records the session held in a slot.
+	Assign(ctx context.Context, slot int, session string) error
+	// Renew extends owner's leases on the given slots, returning any slots
+	// that have been lost to another owner.
+	Renew(ctx context.Context, owner string, slots []int) (lost []int, err error)
+	// Free releases a slot and forgets its session.
+	Free(ctx context.Context, slot int) error
+}
+
+// createSession creates a new pooled session, claiming a slot in the
+// SessionRegistry first if one is configured. c.smu must be held.
+func (c *Client) createSession(ctx context.Context) (*Session, error) {
+	info := &sessionInfo{slot: -1, partition: sessionPartition(ctx)}
+	if c.SessionRegistry == nil {
+		sess, err := c.newSession(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.sessions[sess.name] = info
+		sess.lease = c.hold(info)
+		return sess, nil
+	}
+	if c.ownerID == "" {
+		c.ownerID = newEventID()
+	}
+	slot, prev, err := c.SessionRegistry.Claim(ctx, c.ownerID)
+	if err != nil {
+		return nil, err
+	}
+	info.slot = slot
+	if prev != "" {
+		sess, res, err := c.adoptSession(ctx, prev)
+		if err == nil && info.partition.matches(res) {
+			c.sessions[sess.name] = info
+			sess.lease = c.hold(info)
+			return sess, nil
+		}
+		if err == nil {
+			// created for another partition; it can't be reused
+			sess.sess.Delete(prev).Context(ctx).Do()
+		}
+	}
+	sess, err := c.newSession(ctx)
+	if err != nil {
+		c.SessionRegistry.Free(ctx, slot)
+		return nil, err
+	}
+	if err := c.SessionRegistry.Assign(ctx, slot, sess.name); err != nil {
+		c.logf(ctx, "unable to record session in registry: %s", err)
+	}
+	c.sessions[sess.name] = info
+	sess.lease = c.hold(info)
+	return sess, nil
+}
+
+// freeSlot releases the registry slot held by a session that has been removed
+// from the pool. c.smu must be held.
+func (c *Client) freeSlot(ctx context.Context, info *sessionInfo) {
+	if c.SessionRegistry == nil || info.slot < 0 {
+		return
+	}
+	if err := c.SessionRegistry.Free(ctx, info.slot); err != nil {
+		c.logf(ctx, "unable to free session registry slot: %s", err)
+	}
+}
+
+// renewSlots renews the Client's registry leases if they are due, dropping any
+// sessions whose slots were lost. It is called by AcquireSession and, for
+// instances that go without acquiring sessions for a while, by pingIdle.
+// c.smu must be held.
+func (c *Client) renewSlots(ctx context.Context) {
+	if c.SessionRegistry == nil || time.Since(c.renewed) < registryRenewInterval {
+		return
+	}
+	c.renewed = time.Now()
+	var slots []int
+	bySlot := map[int]string{}
+	for name, info := range c.sessions {
+		if info.slot >= 0 {
+			slots = append(slots, info.slot)
+			bySlot[info.slot] = name
+		}
+	}
+	if len(slots) == 0 {
+		return
+	}
+	lost, err := c.SessionRegistry.Renew(ctx, c.ownerID, slots)
+	if err != nil {
+		c.logf(ctx, "unable to renew session registry leases: %s", err)
+		return
+	}
+	for _, slot := range lost {
+		delete(c.sessions, bySlot[slot])
+	}
+}
+
+// MemcacheSessionRegistry is a SessionRegistry backed by App Engine memcache, for
+// services running many small instances whose per-instance pools would otherwise
+// multiply the number of sessions. Memcache is not durable, so the cap is best
+// effort: an evicted lease may briefly allow more than MaxSessions sessions.
+type MemcacheSessionRegistry struct {
+	// MaxSessions is the total number of sessions allowed across all instances.
+	MaxSessions int
+	// Lease is how long a slot stays claimed without being renewed. It defaults
+	// to five minutes and must be longer than a minute. Leases are renewed by
+	// AcquireSession, Maintain and KeepAlive, so instances that hold sessions
+	// for longer than the lease without acquiring others, such as while
+	// reading a change stream, must run KeepAlive with a shorter interval.
+	// Otherwise their slots lapse and the sessions are adopted by another
+	// instance while still in use.
+	Lease time.Duration
+	// Prefix is prepended to all memcache keys. It defaults to "spannerr:sessions:".
+	Prefix string
+}
+
+func (m *MemcacheSessionRegistry) key(kind string, slot int) string {
+	prefix := m.Prefix
+	if prefix == "" {
+		prefix = "spannerr:sessions:"
+	}
+	return prefix + kind + ":" + strconv.Itoa(slot)
+}
+
+func (m *MemcacheSessionRegistry) lease() time.Duration {
+	if m.Lease <= 0 {
+		return 5 * time.Minute
+	}
+	return m.Lease
+}
+
+// Claim implements SessionRegistry.
+func (m *MemcacheSessionRegistry) Claim(ctx context.Context, owner string) (int, string, error) {
+	if m.MaxSessions <= 0 {
+		return 0, "", ErrSessionCap
+	}
+	// start at a random slot to reduce contention between instances
+	start := rand.Intn(m.MaxSessions)
+	for i := 0; i < m.MaxSessions; i++ {
+		slot := (start + i) % m.MaxSessions
+		err := memcache.Add(ctx, &memcache.Item{
+			Key:        m.key("lease", slot),
+			Value:      []byte(owner),
+			Expiration: m.lease(),
+		})
+		if err == memcache.ErrNotStored {
+			continue
+		}
+		if err != nil {
+			return 0, "", errors.Wrap(err, "unable to claim session slot")
+		}
+		item, err := memcache.Get(ctx, m.key("session", slot))
+		if err == memcache.ErrCacheMiss {
+			return slot, "", nil
+		}
+		if err != nil {
+			return slot, "", errors.Wrap(err, "unable to look up session slot")
+		}
+		return slot, string(item.Value), nil
+	}
+	return 0, "", ErrSessionCap
+}
+
+// Assign implements SessionRegistry.
+func (m *MemcacheSessionRegistry) Assign(ctx context.Context, slot int, session string) error {
+	return memcache.Set(ctx, &memcache.Item{Key: m.key("session", slot), Value: []byte(session)})
+}
+
+// Renew implements SessionRegistry. Leases are extended with compare-and-swap,
+// so a lease taken by another owner since it was read is reported as lost rather
+// than overwritten.
+func (m *MemcacheSessionRegistry) Renew(ctx context.Context, owner string, slots []int) ([]int, error) {
+	keys := make([]string, len(slots))
+	for i, slot := range slots {
+		keys[i] = m.key("lease", slot)
+	}
+	items, err := memcache.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read session leases")
+	}
+	var (
+		lost []int
+		// held are the leases still owned, and expired those that expired
+		// but may not have been taken by anyone else yet
+		held, expired           []*memcache.Item
+		heldSlots, expiredSlots []int
+	)
+	for i, slot := range slots {
+		item, ok := items[keys[i]]
+		switch {
+		case !ok:
+			expired = append(expired, &memcache.Item{Key: keys[i], Value: []byte(owner), Expiration: m.lease()})
+			expiredSlots = append(expiredSlots, slot)
+		case string(item.Value) != owner:
+			lost = append(lost, slot)
+		default:
+			item.Expiration = m.lease()
+			held = append(held, item)
+			heldSlots = append(heldSlots, slot)
+		}
+	}
+	// a conflict means the lease changed hands since it was read, and a failed
+	// add that it was taken after expiring
+	if err := memcache.CompareAndSwapMulti(ctx, held); err != nil {
+		l, err := lostLeases(err, heldSlots, memcache.ErrCASConflict, memcache.ErrNotStored)
+		lost = append(lost, l...)
+		if err != nil {
+			return lost, errors.Wrap(err, "unable to renew session leases")
+		}
+	}
+	if err := memcache.AddMulti(ctx, expired); err != nil {
+		l, err := lostLeases(err, expiredSlots, memcache.ErrNotStored)
+		lost = append(lost, l...)
+		if err != nil {
+			return lost, errors.Wrap(err, "unable to reclaim session leases")
+		}
+	}
+	return lost, nil
+}
+
+// lostLeases returns the slots whose items failed with one of the given errors
+// in the MultiError err of a memcache batch call, or err if it is another error.
+func lostLeases(err error, slots []int, lostErrs ...error) ([]int, error) {
+	me, ok := err.(appengine.MultiError)
+	if !ok {
+		return nil, err
+	}
+	var lost []int
+	for i, e := range me {
+		if e == nil {
+			continue
+		}
+		for _, le := range lostErrs {
+			if e == le {
+				lost = append(lost, slots[i])
+				e = nil
+				break
+			}
+		}
+		if e != nil {
+			return lost, e
+		}
+	}
+	return lost, nil
+}
+
+// Free implements SessionRegistry.
+func (m *MemcacheSessionRegistry) Free(ctx context.Context, slot int) error {
+	err := memcache.DeleteMulti(ctx, []string{m.key("lease", slot), m.key("session", slot)})
+	if me, ok := err.(appengine.MultiError); ok {
+		for _, e := range me {
+			if e != nil && e != memcache.ErrCacheMiss {
+				return e
+			}
+		}
+		return nil
+	}
+	return err
+}
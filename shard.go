@@ -0,0 +1,136 @@
+package spannerr
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ShardParam is the name of the parameter bound to the shard number by ShardUnion
+// and QueryShards.
+const ShardParam = "shard"
+
+// shardConcurrency is the number of shards QueryShards queries at once.
+const shardConcurrency = 8
+
+// ShardID returns the shard, in [0, shards), of a row with the given key values.
+// Storing it as the leading primary key column, ahead of a monotonically
+// increasing column such as a timestamp or sequence, spreads writes across splits
+// instead of hot-spotting the end of the key range. The result depends only on
+// the key values, so it is stable across processes and releases; the number of
+// shards cannot be changed without rewriting the table.
+//
+//	CREATE TABLE Events (
+//		ShardId INT64 NOT NULL,
+//		Created TIMESTAMP NOT NULL,
+//		EventId STRING(36) NOT NULL,
+//		...
+//	) PRIMARY KEY (ShardId, Created, EventId)
+func ShardID(shards int, key ...interface{}) int64 {
+	if shards <= 1 {
+		return 0
+	}
+	h := fnv.New64a()
+	for _, v := range key {
+		// hash the API encoding so equal values of different Go types, such as
+		// int and int64, land in the same shard
+		fmt.Fprintf(h, "%v", encodeValue(v))
+		// separate values so ("ab", "c") and ("a", "bc") differ
+		h.Write([]byte{0})
+	}
+	return int64(h.Sum64() % uint64(shards))
+}
+
+// ShardedKey returns key prefixed with its ShardID, for use with the read helpers
+// and DeleteKeys.
+func ShardedKey(shards int, key ...interface{}) Key {
+	return append(Key{ShardID(shards, key...)}, key...)
+}
+
+// ShardUnion expands a query that selects from a single shard, referencing the
+// shard number as @shard, into a UNION ALL of the query over every shard. Keeping
+// each branch restricted to a single shard lets Spanner use the primary key to
+// seek, rather than scanning the table as a predicate on the remaining key
+// columns alone would.
+//
+//	sql, params, err := spannerr.ShardUnion(16, spannerr.Frag(
+//		"SELECT * FROM Events WHERE ShardId = @shard AND Created > @since",
+//		&spannerr.Param{Name: "since", Value: since, Type: spannerr.TypeTimestamp},
+//	)).Build()
+func ShardUnion(shards int, query Fragment) Fragment {
+	frags := make([]Fragment, shards)
+	for i := range frags {
+		frags[i] = Fragment{
+			SQL:    "(" + query.SQL + ")",
+			Params: append(shardParams(query.Params, i), query.Params...),
+			err:    query.err,
+		}
+	}
+	return Join(" UNION ALL ", frags...)
+}
+
+// shardParams returns the @shard param for shard i unless params already has it.
+func shardParams(params []*Param, i int) []*Param {
+	for _, p := range params {
+		if p.Name == ShardParam {
+			return nil
+		}
+	}
+	return []*Param{{Name: ShardParam, Value: int64(i), Type: TypeInt64}}
+}
+
+// QueryShards runs sql, which must reference the shard number as @shard, once for
+// each shard in parallel and decodes all resulting rows into dst, a pointer to a
+// slice of structs, in shard order. It is an alternative to ShardUnion for queries
+// that return many rows or need an ORDER BY and LIMIT applied per shard.
+func (c *Client) QueryShards(ctx context.Context, shards int, sql string, params []*Param, dst interface{}) error {
+	dv := reflect.ValueOf(dst)
+	if dv.Kind() != reflect.Ptr || dv.Elem().Kind() != reflect.Slice {
+		return errors.Errorf("destination must be a pointer to a slice, got %T", dst)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		results  = make([]reflect.Value, shards)
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, shardConcurrency)
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < shards; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+			rows := reflect.New(dv.Elem().Type())
+			p := append(shardParams(params, i), params...)
+			if err := c.Query(ctx, sql, p, rows.Interface()); err != nil {
+				errOnce.Do(func() {
+					firstErr = errors.Wrapf(err, "unable to query shard %d", i)
+					cancel()
+				})
+				return
+			}
+			results[i] = rows.Elem()
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	out := dv.Elem()
+	for _, rows := range results {
+		out = reflect.AppendSlice(out, rows)
+	}
+	dv.Elem().Set(out)
+	return nil
+}
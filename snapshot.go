@@ -0,0 +1,96 @@
+package spannerr
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Snapshot is a read-only transaction whose queries and reads all observe the
+// database at the same timestamp, for generating reports that span many queries
+// with a consistent view. A Snapshot is safe for concurrent use by multiple
+// goroutines.
+type Snapshot struct {
+	// Session is the session the snapshot was started on.
+	Session *Session
+	// ID is the Spanner transaction ID.
+	ID string
+	// Timestamp is the time the snapshot reads at.
+	Timestamp time.Time
+
+	// pooled is set when the session was acquired from the Client's pool.
+	pooled    bool
+	closeOnce sync.Once
+}
+
+// BeginSnapshot starts a read-only transaction on the session with the given
+// timestamp bound. A nil ro reads at a strong (current) timestamp.
+func (s *Session) BeginSnapshot(ctx context.Context, ro *spanner.ReadOnly) (*Snapshot, error) {
+	if ro == nil {
+		ro = &spanner.ReadOnly{Strong: true}
+	}
+	bound := *ro
+	bound.ReturnReadTimestamp = true
+	tx, err := s.BeginTransaction(ctx, &spanner.BeginTransactionRequest{
+		Options: &spanner.TransactionOptions{ReadOnly: &bound},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to begin read-only transaction")
+	}
+	ts, err := time.Parse(time.RFC3339Nano, tx.ReadTimestamp)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid read timestamp")
+	}
+	return &Snapshot{Session: s, ID: tx.Id, Timestamp: ts}, nil
+}
+
+// Snapshot acquires a pooled session and starts a read-only transaction on it. The
+// session is held until the Snapshot is closed.
+func (c *Client) Snapshot(ctx context.Context, ro *spanner.ReadOnly) (*Snapshot, error) {
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := sess.BeginSnapshot(ctx, ro)
+	if err != nil {
+		c.ReleaseSession(ctx, *sess)
+		return nil, err
+	}
+	snap.pooled = true
+	return snap, nil
+}
+
+// Selector returns a TransactionSelector for executing statements within the snapshot.
+func (s *Snapshot) Selector() *spanner.TransactionSelector {
+	return &spanner.TransactionSelector{Id: s.ID}
+}
+
+// ExecuteSQL executes a query within the snapshot.
+func (s *Snapshot) ExecuteSQL(ctx context.Context, params []*Param, sql string) (*spanner.ResultSet, error) {
+	return s.Session.ExecuteSQL(sharedSession(ReadOnlyContext(ctx)), params, sql, QueryModeNormal, s.Selector())
+}
+
+// Query executes a query within the snapshot and decodes all resulting rows into
+// dst, a pointer to a slice of structs.
+func (s *Snapshot) Query(ctx context.Context, sql string, params []*Param, dst interface{}) error {
+	return s.Session.query(sharedSession(ReadOnlyContext(ctx)), sql, params, s.Selector(), dst)
+}
+
+// Read reads rows by key within the snapshot.
+func (s *Snapshot) Read(ctx context.Context, table string, keys *spanner.KeySet, columns []string) (*spanner.ResultSet, error) {
+	return s.Session.Read(sharedSession(ctx), table, keys, columns, s.Selector())
+}
+
+// Close ends the snapshot, returning its session to the pool if it was started
+// with Client.Snapshot. Read-only transactions hold no locks, so nothing is sent
+// to Spanner. It is safe to call Close more than once.
+func (s *Snapshot) Close(ctx context.Context) {
+	s.closeOnce.Do(func() {
+		if s.pooled {
+			s.Session.client.ReleaseSession(ctx, *s.Session)
+		}
+	})
+}
@@ -6,9 +6,10 @@ package spannerr
 
 import (
 	"context"
-	"encoding/json"
+	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -24,15 +25,215 @@ type (
 	Client struct {
 		smu      sync.Mutex
 		sessions map[string]*sessionInfo
+		// waiters are the AcquireSession calls waiting for a session, oldest
+		// first; see WaitForSession.
+		waiters []chan struct{}
+		// expired are the names of acquired sessions Spanner reported as not
+		// found, until they are released; see retryExpired.
+		expired map[string]bool
+		// leases counts the acquisitions of pooled sessions; see hold.
+		leases uint64
 
 		conn        string
 		maxSessions int
+
+		// svc is the Spanner service shared by all data calls, made with svcHC;
+		// see service.
+		svcMu    sync.Mutex
+		svc      *spanner.Service
+		svcHC    *http.Client
+		svcBuilt time.Time
+
+		featMu   sync.Mutex
+		features *Features
+
+		schemaMu   sync.Mutex
+		primaryKey map[string][]string
+
+		txnStats txnStats
+
+		// LintMode enables checking SQL passed to ExecuteSQL for inline literals
+		// that should be passed in as parameters. It is LintOff by default.
+		LintMode LintMode
+		// LintAllow is a list of literals, exactly as written in SQL, that are
+		// intentional constants and should not be flagged by the linter.
+		LintAllow []string
+
+		// Conventions configures the audit and soft-delete columns honored by the
+		// read helpers. It is disabled by default.
+		Conventions Conventions
+
+		// ReadOnlyQueries restricts ExecuteSQL to read-only statements, rejecting
+		// DML with a *ReadOnlyError. See ReadOnlyContext to restrict a single call.
+		ReadOnlyQueries bool
+		// ReadOnly rejects every write with a *ReadOnlyError before it reaches
+		// Spanner: commits, read-write and partitioned DML transactions, DML and
+		// DDL. It implies ReadOnlyQueries. Use it for analytics deployments and
+		// credentials that should never write, as defense in depth on top of IAM.
+		ReadOnly bool
+
+		// Registry holds the named statements available to Exec.
+		Registry *Registry
+
+		// TokenSource, if set, provides the credentials used for all Spanner calls
+		// instead of the App Engine service account. Unless it is a
+		// ScopedTokenSource, it is also used as-is for the other Google APIs
+		// some helpers call, such as Cloud Storage by Export, so its tokens must
+		// carry their scopes too, for example by being for the cloud-platform
+		// scope.
+		TokenSource oauth2.TokenSource
+		// HTTPClient, if set, is used as-is for all Spanner calls in place of one
+		// authenticated with TokenSource or the App Engine service account.
+		HTTPClient *http.Client
+		// Endpoint, if set, is the base URL of the Spanner REST API, such as the
+		// emulator's, ending with a slash.
+		Endpoint string
+		// Scopes are the OAuth scopes requested for data calls when credentials
+		// come from the environment. They default to the spanner.data scope.
+		Scopes []string
+		// NewService, if set, constructs the Spanner service used for all data
+		// calls in place of the Client's own, for tests and environments such as
+		// custom auth proxies. TokenSource is then ignored for data calls. The
+		// service is built once and shared; see ServiceMaxAge.
+		// Streaming queries, which the generated service can't make, are sent to
+		// the service's BasePath with HTTPClient or, if it is nil, the Client's
+		// own credentials, so set HTTPClient too when the service authenticates
+		// on its own, such as through a proxy.
+		NewService func(ctx context.Context) (*spanner.Service, error)
+		// ServiceMaxAge, if set, is how long the Spanner service shared by all
+		// data calls is reused before it is rebuilt, resolving credentials and
+		// calling NewService again. By default it is only rebuilt by
+		// RefreshService.
+		ServiceMaxAge time.Duration
+		// AdminTokenSource, if set, provides the credentials used for database
+		// administration, such as DDL and database creation, so a service's data
+		// credentials don't need admin rights. If nil, admin calls use TokenSource
+		// or the App Engine service account with AdminScopes.
+		AdminTokenSource oauth2.TokenSource
+		// AdminScopes are the OAuth scopes requested for admin calls when
+		// AdminTokenSource is nil or a ScopedTokenSource. They default to the
+		// spanner.admin scope.
+		AdminScopes []string
+
+		// LogStatements logs every executed statement and committed mutation
+		// through Logf. Values of sensitive params and columns are redacted.
+		LogStatements bool
+		// Redact lists param and column names whose values are always redacted from
+		// logs. Individual params can also set Param.Sensitive, and struct fields
+		// used with the mutation builders can be tagged `spanner:"Email,sensitive"`.
+		Redact []string
+		// Tables records the interleaved parents and sensitive columns of the
+		// database's tables, for redaction and InterleavedChildren. It defaults
+		// to DefaultTables. The struct mutation builders are not tied to a
+		// Client, so struct tags are only ever discovered into DefaultTables:
+		// a registry set here only holds what is recorded with its own
+		// RegisterStruct and RegisterInterleave and what InterleavedChildren
+		// looks up. Clients of databases whose tables share names should each
+		// be given their own, filled that way, although sensitive columns
+		// discovered into DefaultTables are still redacted for every Client.
+		Tables *TableRegistry
+
+		// SessionRegistry, if set, coordinates session creation across all of a
+		// service's instances, capping the total number of sessions.
+		SessionRegistry SessionRegistry
+		ownerID         string
+		renewed         time.Time
+
+		// ReplicaPreferences is an ordered list of replicas, such as
+		// {Location: "us-east1", Type: "READ_ONLY"}, to direct reads and queries in
+		// read-only transactions to. If a replica is UNAVAILABLE, the call is
+		// retried with the next preference and finally with Spanner's default
+		// routing. Streaming queries only use the first preference.
+		ReplicaPreferences []*spanner.ReplicaSelection
+
+		// DeadlineReserve, if set, is the time that must remain before the ctx
+		// deadline, such as the App Engine request deadline, for transactions to
+		// be started or committed. Calls made with less time remaining fail with
+		// a *BudgetError instead, so the request can still respond.
+		DeadlineReserve time.Duration
+
+		// NPlusOneThreshold, if positive, enables N+1 query detection for
+		// debugging: when the same statement is executed with this many
+		// different params within a RequestScope, a warning with a stack trace
+		// is logged.
+		NPlusOneThreshold int
+
+		// DryRun, if set, validates and logs mutations instead of committing them,
+		// for shadow deployments and migration rehearsals. Read-write
+		// transactions are rolled back instead of committed, so DML executed
+		// within them is discarded, and partitioned DML is only planned. See
+		// DryRunContext to enable it for a single call.
+		DryRun bool
+
+		// DedupeMutations drops redundant mutations before every commit: exact
+		// duplicates, and writes of a row that a later write of the same kind to
+		// the same columns overwrites, so the last write wins. This reduces the
+		// mutation count of code that naively accumulates writes. Matching rows
+		// requires each table's primary key, which is looked up once and cached.
+		DedupeMutations bool
+
+		// OnCommitSuccess, if set, is called after every successful commit of
+		// mutations with the tables and keys they touched, so caches can be
+		// invalidated exactly when writes land. Rows written by DML are not
+		// reported. Determining the keys of inserts and updates requires each
+		// table's primary key, which is looked up once and cached.
+		OnCommitSuccess func(ctx context.Context, touched []*TouchedTable)
+
+		// ParamsEncoder, if set, replaces encoding/json for encoding statement
+		// params, such as with a faster encoder for very large payloads.
+		ParamsEncoder ParamsEncoder
+
+		// LargeParams is the size in bytes above which encoded params are logged
+		// as a warning and, if compression is enabled, request bodies are gzipped.
+		// It defaults to DefaultLargeParams.
+		LargeParams int
+		// CompressLargeParams gzips request bodies larger than LargeParams for all
+		// calls. See CompressContext to enable it for a single call. It has no
+		// effect when NewService is set.
+		CompressLargeParams bool
+
+		// OnError, if set, is called with every failed call whose error is not
+		// retryable, for forwarding to an error tracker; see ErrorReporter.
+		OnError func(ctx context.Context, r *ErrorReport)
+
+		// Authorize, if set, is called before every statement, read and commit
+		// of mutations with the caller's identity, see WithIdentity, the kind of
+		// operation and the tables it touches. Returning an error vetoes the
+		// operation, which then fails with an *AuthzError. It allows application
+		// level authorization on top of Spanner's IAM.
+		Authorize func(ctx context.Context, req *AuthzRequest) error
+
+		// WaitForSession makes AcquireSession wait for a session to be released
+		// when all are in use, instead of failing with ErrPoolExhausted. Waiting
+		// callers are served in order. The wait ends when ctx is done or, if set,
+		// after AcquireTimeout. ErrSessionCap is still returned immediately, as
+		// sessions released by other clients can't be waited for.
+		WaitForSession bool
+		// AcquireTimeout bounds how long AcquireSession waits for a session when
+		// WaitForSession is set.
+		AcquireTimeout time.Duration
+
+		// Logf is used to report warnings. If nil, the standard library logger is used.
+		Logf func(ctx context.Context, format string, args ...interface{})
 	}
 
-	// Session represents a live session on Google Cloud Spanner.
+	// Session represents a live session on Google Cloud Spanner. A Session, and
+	// any Txn on it, must not be used by multiple goroutines at once; a call made
+	// while another is in flight fails with a *ConcurrentUseError.
 	Session struct {
-		name string
-		sess *spanner.ProjectsInstancesDatabasesSessionsService
+		name   string
+		sess   *spanner.ProjectsInstancesDatabasesSessionsService
+		client *Client
+		seqno  int64
+		guard  *sessionGuard
+		// lease is the acquisition the handle was returned for, or 0 if it is
+		// not a pooled session.
+		lease uint64
+
+		// hc and basePath are used for calls the generated service can't make,
+		// such as streaming reads.
+		hc       *http.Client
+		basePath string
 	}
 
 	// Param contains the information required to pass a parameter to a Cloud Spanner query.
@@ -43,93 +244,263 @@ type (
 		Value interface{}
 		// Type will be used to populate the spanner.Type.Code field. More details
 		// can be found here: https://godoc.org/google.golang.org/api/spanner/v1#Type
-		Type string
+		Type TypeCode
 		// ArrayElementType will be used to populate the spanner.Type.Code field of a
 		// nested array type. More details can be found here:
 		// https://godoc.org/google.golang.org/api/spanner/v1#Type
-		ArrayElementType string
+		ArrayElementType TypeCode
+		// Sensitive marks the value as containing PII or secrets so it is redacted
+		// from all logs.
+		Sensitive bool
 	}
 
 	sessionInfo struct {
-		inUse    bool
+		inUse bool
+		// lease identifies the current acquisition, so a release of an earlier
+		// one is ignored.
+		lease    uint64
 		lastUsed time.Time
+		// slot is the SessionRegistry slot held by the session, or -1.
+		slot int
+		// partition is the SessionPartition the session was created for.
+		partition SessionPartition
 	}
 )
 
-// NewClient returns a new Client implementation.
-func NewClient(project, instances, database string, maxSessions int) *Client {
-	return &Client{
-		conn:        "projects/" + project + "/instances/" + instances + "/databases/" + database,
-		maxSessions: maxSessions,
+// NewClient returns a new Client for the given database, configured by opts.
+// Without options it uses App Engine credentials, the default Spanner endpoint
+// and a pool of DefaultMaxSessions sessions. For example, to use the emulator:
+//
+//	c := spannerr.NewClient("p", "i", "db",
+//		spannerr.WithEndpoint("http://localhost:9020/"),
+//		spannerr.WithHTTPClient(http.DefaultClient),
+//		spannerr.WithMaxSessions(25))
+func NewClient(project, instance, database string, opts ...Option) *Client {
+	c := &Client{
+		conn:        "projects/" + project + "/instances/" + instance + "/databases/" + database,
+		maxSessions: DefaultMaxSessions,
 		sessions:    map[string]*sessionInfo{},
+		expired:     map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 var idleTimeout = 45 * time.Minute
 
+// ErrPoolExhausted is returned by AcquireSession when all sessions are in use and
+// the pool is full.
+var ErrPoolExhausted = errors.New("all sessions are in use. you may need to increase your session pool size.")
+
 // AcquireSession will pull an existing session from the local cache. If the session
 // cache is not full, it will create a new session and put it in the cache.
+// If all sessions are in use it fails with ErrPoolExhausted or, with
+// WaitForSession set, waits for one to be released.
 // Users must pass the Session to ReleaseSession when work is complete.
 func (c *Client) AcquireSession(ctx context.Context) (*Session, error) {
-	c.smu.Lock()
-	defer c.smu.Unlock()
+	if !c.WaitForSession {
+		c.smu.Lock()
+		defer c.smu.Unlock()
+		return c.acquireSession(ctx)
+	}
+	var timeout <-chan time.Time
+	if c.AcquireTimeout > 0 {
+		t := time.NewTimer(c.AcquireTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	for {
+		c.smu.Lock()
+		sess, err := c.acquireSession(ctx)
+		if err != ErrPoolExhausted {
+			c.smu.Unlock()
+			return sess, err
+		}
+		wait := make(chan struct{})
+		c.waiters = append(c.waiters, wait)
+		c.smu.Unlock()
+
+		select {
+		case <-wait:
+			continue
+		case <-ctx.Done():
+			err = errors.Wrap(ctx.Err(), "gave up waiting for a session")
+		case <-timeout:
+			err = errors.Wrapf(ErrPoolExhausted, "timed out after %s waiting for a session", c.AcquireTimeout)
+		}
+		c.smu.Lock()
+		c.dropWaiter(wait)
+		c.smu.Unlock()
+		return nil, err
+	}
+}
+
+// wakeWaiter wakes the longest waiting AcquireSession call, if any, after a
+// session was released or room was made in the pool. c.smu must be held.
+func (c *Client) wakeWaiter() {
+	if len(c.waiters) == 0 {
+		return
+	}
+	close(c.waiters[0])
+	c.waiters = c.waiters[1:]
+}
+
+// dropWaiter removes a waiter that gave up. If it was already woken, the wakeup is
+// passed on so it isn't lost. c.smu must be held.
+func (c *Client) dropWaiter(wait chan struct{}) {
+	for i, w := range c.waiters {
+		if w == wait {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return
+		}
+	}
+	c.wakeWaiter()
+}
+
+// acquireSession implements AcquireSession without waiting. c.smu must be held.
+func (c *Client) acquireSession(ctx context.Context) (*Session, error) {
+	c.renewSlots(ctx)
 	// fill the buffer first
 	if len(c.sessions) < c.maxSessions {
-		sess, err := c.newSession(ctx)
-		if err != nil {
-			return nil, err
+		sess, err := c.createSession(ctx)
+		if err != ErrSessionCap {
+			return sess, err
 		}
-		c.sessions[sess.name] = &sessionInfo{inUse: true}
-		return sess, nil
+		// the service-wide cap is reached; fall back to a free local session
 	}
 	// range over existing sessions until we find a free one
+	part := sessionPartition(ctx)
+	var spare string
 	for name, info := range c.sessions {
 		if info.inUse {
 			continue
 		}
+		if info.partition != part {
+			spare = name
+			continue
+		}
 		// if session has been idle for too long, toss it out and make a new one
 		if time.Now().UTC().Sub(info.lastUsed) > idleTimeout {
 			delete(c.sessions, name)
-			sess, err := c.newSession(ctx)
-			if err != nil {
-				return nil, err
-			}
-			c.sessions[sess.name] = &sessionInfo{inUse: true}
-			return sess, nil
+			c.freeSlot(ctx, info)
+			return c.createSession(ctx)
 		}
 
-		c.sessions[name] = &sessionInfo{inUse: true}
+		lease := c.hold(info)
 		// init the client for the session before passing it back
-		svc, err := newSpanner(ctx)
+		sess, err := c.session(ctx, name)
 		if err != nil {
-			return nil, errors.Wrap(err, "unable to init spanner service")
+			return nil, err
 		}
-		return &Session{name: name, sess: svc.Projects.Instances.Databases.Sessions},
-			nil
+		sess.lease = lease
+		return sess, nil
+	}
+	if spare != "" {
+		// replace a free session of another partition with one of ours
+		c.deleteSession(ctx, spare)
+		return c.createSession(ctx)
+	}
+	if len(c.sessions) == 0 && c.SessionRegistry != nil {
+		return nil, ErrSessionCap
 	}
-	return nil, errors.Errorf("all %d sessions are in use. you may need to increase your session pool size.",
-		len(c.sessions))
+	return nil, ErrPoolExhausted
 }
 
 func (c *Client) newSession(ctx context.Context) (*Session, error) {
-	svc, err := newSpanner(ctx)
+	sess, err := c.session(ctx, "")
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to init spanner service")
+		return nil, err
 	}
-	sess := svc.Projects.Instances.Databases.Sessions
-	resp, err := sess.Create(c.conn, &spanner.CreateSessionRequest{}).Do()
+	resp, err := sess.sess.Create(c.conn, &spanner.CreateSessionRequest{
+		Session: sessionPartition(ctx).session(),
+	}).Do()
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to init spanner session")
 	}
-	return &Session{name: resp.Name, sess: sess}, nil
+	sess.name = resp.Name
+	return sess, nil
+}
+
+// session returns a Session handle for the named session using the Client's
+// shared service.
+func (c *Client) session(ctx context.Context, name string) (*Session, error) {
+	svc, hc, err := c.service(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to init spanner service")
+	}
+	return &Session{
+		guard:    &sessionGuard{},
+		name:     name,
+		sess:     svc.Projects.Instances.Databases.Sessions,
+		client:   c,
+		hc:       hc,
+		basePath: svc.BasePath,
+	}, nil
+}
+
+// Name returns the full resource name of the session, in the form
+// "projects/<p>/instances/<i>/databases/<d>/sessions/<s>".
+func (s *Session) Name() string {
+	return s.name
+}
+
+// Raw returns the underlying sessions service, for calling REST methods this
+// package does not wrap while still using a pooled session:
+//
+//	res, err := sess.Raw().ExecuteSql(sess.Name(), req).Context(ctx).Do()
+//
+// Calls made through Raw bypass the Client's checks, logging and statistics,
+// and do not use end user credentials from WithUserCredentials.
+func (s *Session) Raw() *spanner.ProjectsInstancesDatabasesSessionsService {
+	return s.sess
+}
+
+// deleteSession removes the named session from the pool and deletes it. Failures
+// are only logged, as Spanner expires unused sessions. c.smu must be held.
+func (c *Client) deleteSession(ctx context.Context, name string) {
+	info := c.sessions[name]
+	delete(c.sessions, name)
+	if info != nil {
+		c.freeSlot(ctx, info)
+		c.wakeWaiter()
+	}
+	sess, err := c.session(ctx, name)
+	if err == nil {
+		_, err = sess.sess.Delete(name).Context(ctx).Do()
+	}
+	if err != nil && ErrorCode(err) != "NOT_FOUND" {
+		c.logf(ctx, "unable to delete session: %s", err)
+	}
+}
+
+// hold marks a pooled session in use, returning the lease that identifies this
+// acquisition. c.smu must be held.
+func (c *Client) hold(info *sessionInfo) uint64 {
+	c.leases++
+	info.inUse, info.lease = true, c.leases
+	return info.lease
 }
 
 // ReleaseSession will make the session available in the cache again. Call this after
-// first acquiring a session.
+// first acquiring a session. Releasing a session twice, or after it was handed to
+// another caller, is ignored.
 func (c *Client) ReleaseSession(ctx context.Context, sess Session) {
 	c.smu.Lock()
 	defer c.smu.Unlock()
-	c.sessions[sess.name] = &sessionInfo{inUse: false, lastUsed: time.Now().UTC()}
+	// sessions evicted while in use, such as those whose registry slot was
+	// lost, are not returned to the pool
+	if info, ok := c.sessions[sess.name]; ok {
+		if !info.inUse || info.lease != sess.lease {
+			c.logf(ctx, "ignoring stale release of session %s", sess.name)
+			return
+		}
+		info.inUse = false
+		info.lastUsed = time.Now().UTC()
+	}
+	delete(c.expired, sess.name)
+	c.wakeWaiter()
 }
 
 // Close will attempt to end all existing sessions. If you have shutdown hooks
@@ -137,7 +508,7 @@ func (c *Client) ReleaseSession(ctx context.Context, sess Session) {
 // If you do not have shutdown hooks, the sessions made will be closed automatically
 // after one hour of idle time: https://cloud.google.com/spanner/docs/sessions
 func (c *Client) Close(ctx context.Context) error {
-	svc, err := newSpanner(ctx)
+	svc, err := c.newSpanner(ctx)
 	if err != nil {
 		return errors.Wrap(err, "unable to init spanner service")
 	}
@@ -146,80 +517,377 @@ func (c *Client) Close(ctx context.Context) error {
 	c.smu.Lock()
 	defer c.smu.Unlock()
 
-	for s := range c.sessions {
+	for s, info := range c.sessions {
 		_, err := sess.Delete(s).Context(ctx).Do()
 		if err != nil {
 			return err
 		}
+		delete(c.sessions, s)
+		c.freeSlot(ctx, info)
 	}
 	return nil
 }
 
-// BeginTransaction starts a new transaction.
+// BeginTransaction starts a new transaction, read-write if opts is nil. Statements
+// and reads are run within it by passing a selector with the returned ID, and it
+// is finished by passing the ID to Commit or Rollback. See also BeginReadWrite.
+// This function wraps https://godoc.org/google.golang.org/api/spanner/v1#ProjectsInstancesDatabasesSessionsService.BeginTransaction
 func (s *Session) BeginTransaction(ctx context.Context, opts *spanner.BeginTransactionRequest) (*spanner.Transaction, error) {
-	return s.sess.BeginTransaction(s.name, opts).Context(ctx).Do()
+	if err := s.client.checkBudget(ctx, "begin transaction"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if opts == nil {
+		opts = &spanner.BeginTransactionRequest{
+			Options: &spanner.TransactionOptions{ReadWrite: &spanner.ReadWrite{}},
+		}
+	}
+	if o := opts.Options; o != nil && (o.ReadWrite != nil || o.PartitionedDml != nil) {
+		if err := s.client.checkWrite("begin read-write transaction"); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if opts.RequestOptions == nil {
+		// copy rather than modify the caller's request
+		o := *opts
+		o.RequestOptions = requestOptions(ctx)
+		opts = &o
+	}
+	exit, err := s.enter(ctx, "begin transaction")
+	if err != nil {
+		return nil, err
+	}
+	defer exit()
+	return s.rpc(ctx).BeginTransaction(s.name, opts).Context(ctx).Do()
 }
 
-// Rollback rolls back a transaction.
+// Rollback rolls back a transaction begun with BeginTransaction.
+// This function wraps https://godoc.org/google.golang.org/api/spanner/v1#ProjectsInstancesDatabasesSessionsService.Rollback
 func (s *Session) Rollback(ctx context.Context, txID string) error {
-	_, err := s.sess.Rollback(s.name,
+	exit, err := s.enter(ctx, "rollback")
+	if err != nil {
+		return err
+	}
+	defer exit()
+	_, err = s.rpc(ctx).Rollback(s.name,
 		&spanner.RollbackRequest{TransactionId: txID}).Context(ctx).Do()
 	return err
 }
 
 // Commit commits a transaction. The request includes the mutations to be applied to
 // rows in the database. Including opts signals a one-off query, whereas including txID
-// signals this commit is part of a larger transaction.
+// signals this commit is part of a larger transaction. If Spanner reports that the
+// pooled session was not found, a one-off commit is retried once on another pooled
+// session.
 // This function wraps https://godoc.org/google.golang.org/api/spanner/v1#ProjectsInstancesDatabasesSessionsService.Commit
 func (s *Session) Commit(ctx context.Context, mutations []*spanner.Mutation, opts *spanner.TransactionOptions, txID string) (*spanner.CommitResponse, error) {
-	return s.sess.Commit(s.name, &spanner.CommitRequest{
-		Mutations:            mutations,
-		SingleUseTransaction: opts,
-		TransactionId:        txID,
-	}).Context(ctx).Do()
+	if err := s.client.checkBudget(ctx, "commit"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := s.client.checkWrite("commit"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := s.client.authorizeCommit(ctx, mutations); err != nil {
+		return nil, err
+	}
+	if s.client.DedupeMutations {
+		mutations = s.dedupeMutations(ctx, mutations)
+	}
+	if s.client.isDryRun(ctx) {
+		return s.dryRunCommit(ctx, mutations, txID)
+	}
+	exit, err := s.enter(ctx, "commit")
+	if err != nil {
+		return nil, err
+	}
+	s.client.logCommit(ctx, mutations)
+	start := time.Now()
+	defer spendTime(ctx, start)
+	var res *spanner.CommitResponse
+	commit := func(sess *Session) error {
+		res, err = sess.rpc(ctx).Commit(sess.name, &spanner.CommitRequest{
+			Mutations:            mutations,
+			SingleUseTransaction: opts,
+			TransactionId:        txID,
+			RequestOptions:       requestOptions(ctx),
+		}).Context(ctx).Do()
+		return err
+	}
+	err = s.retryExpired(ctx, commit(s), txID == "", commit)
+	exit()
+	s.client.recordCommit(ctx, start, err)
+	s.client.reportError(ctx, "commit", "", err)
+	if err == nil && s.client.OnCommitSuccess != nil && len(mutations) > 0 {
+		touched := s.touchedTables(ctx, mutations)
+		if perr := protect(func() error {
+			s.client.OnCommitSuccess(ctx, touched)
+			return nil
+		}); perr != nil {
+			// the commit has landed, so only report the hook's failure
+			s.client.logf(ctx, "OnCommitSuccess hook failed: %s\n%s", perr, perr.(*PanicError).Stack)
+		}
+	}
+	return res, err
 }
 
 // ExecuteSQL executes an SQL query, returning all rows in a single reply.
 // It can be called within a transaction by including a TransactionSelector
-// with its Id field set.
+// with its Id field set. If Spanner reports that the pooled session was not found,
+// a query outside of a transaction is retried once on another pooled session.
 // This function wraps https://godoc.org/google.golang.org/api/spanner/v1#ProjectsInstancesDatabasesSessionsExecuteSqlCall
-func (s *Session) ExecuteSQL(ctx context.Context, params []*Param, sql, queryMode string, tx *spanner.TransactionSelector) (*spanner.ResultSet, error) {
+func (s *Session) ExecuteSQL(ctx context.Context, params []*Param, sql string, queryMode QueryMode, tx *spanner.TransactionSelector) (*spanner.ResultSet, error) {
+	if err := checkQueryMode(queryMode); err != nil {
+		return nil, err
+	}
+	pTypes, pJSON, err := s.prepareSQL(ctx, sql, params)
+	if err != nil {
+		return nil, err
+	}
+	exit, err := s.enter(ctx, "execute sql")
+	if err != nil {
+		return nil, err
+	}
+	defer exit()
+	var res *spanner.ResultSet
+	defer spendTime(ctx, time.Now())
+	execute := func(sess *Session) error {
+		return s.client.withDirectedReads(ctx, tx, func(dro *spanner.DirectedReadOptions) error {
+			res, err = sess.rpc(ctx).ExecuteSql(sess.name, &spanner.ExecuteSqlRequest{
+				ParamTypes:          pTypes,
+				Params:              pJSON,
+				QueryMode:           string(queryMode),
+				Sql:                 sql,
+				Transaction:         tx,
+				Seqno:               sess.nextSeqno(),
+				DirectedReadOptions: dro,
+			}).Context(ctx).Do()
+			return err
+		})
+	}
+	err = s.retryExpired(ctx, execute(s), tx == nil || tx.SingleUse != nil, execute)
+	s.client.reportError(ctx, "execute sql", sql, err)
+	return res, errors.Wrap(err, "unable to execute query")
+}
+
+// prepareSQL runs the Client's checks on a statement about to be executed and
+// encodes its params.
+func (s *Session) prepareSQL(ctx context.Context, sql string, params []*Param) (map[string]spanner.Type, []byte, error) {
+	if err := s.client.lint(ctx, sql); err != nil {
+		return nil, nil, err
+	}
+	if err := s.client.checkReadOnly(ctx, sql); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if err := s.client.authorizeSQL(ctx, sql); err != nil {
+		return nil, nil, err
+	}
+	if err := spendStatement(ctx); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	pTypes, pJSON, err := s.client.encodeParams(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.client.detectNPlusOne(ctx, sql, pJSON)
+	s.client.checkParamsSize(ctx, sql, len(pJSON))
+	s.client.logStatement(ctx, sql, params)
+	return pTypes, pJSON, nil
+}
+
+// ExecuteBatchDML executes a batch of DML statements in order within the given
+// read-write transaction. Execution stops at the first failed statement, whose
+// error is returned along with the results of the statements before it.
+// This function wraps https://godoc.org/google.golang.org/api/spanner/v1#ProjectsInstancesDatabasesSessionsService.ExecuteBatchDml
+func (s *Session) ExecuteBatchDML(ctx context.Context, stmts []*spanner.Statement, txID string) ([]*spanner.ResultSet, error) {
+	if err := s.client.checkWrite("execute batch dml"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, stmt := range stmts {
+		if err := s.client.authorizeSQL(ctx, stmt.Sql); err != nil {
+			return nil, err
+		}
+	}
+	exit, err := s.enter(ctx, "execute batch dml")
+	if err != nil {
+		return nil, err
+	}
+	defer exit()
+	res, err := s.rpc(ctx).ExecuteBatchDml(s.name, &spanner.ExecuteBatchDmlRequest{
+		Statements:  stmts,
+		Transaction: &spanner.TransactionSelector{Id: txID},
+		Seqno:       s.nextSeqno(),
+	}).Context(ctx).Do()
+	if err != nil {
+		s.client.reportError(ctx, "execute batch dml", "", err)
+		return nil, errors.Wrap(err, "unable to execute batch dml")
+	}
+	if res.Status != nil && res.Status.Code != 0 {
+		return res.ResultSets, errors.Errorf("batch dml statement %d failed: %s",
+			len(res.ResultSets), res.Status.Message)
+	}
+	return res.ResultSets, nil
+}
+
+// nextSeqno returns the sequence number for the next request on the session,
+// which Spanner uses to order and deduplicate DML within a transaction.
+func (s *Session) nextSeqno() int64 {
+	return atomic.AddInt64(&s.seqno, 1)
+}
+
+// encodeParams converts params into the ParamTypes and Params fields of a Spanner
+// request.
+func (c *Client) encodeParams(params []*Param) (map[string]spanner.Type, []byte, error) {
 	var (
 		pTypes = map[string]spanner.Type{}
 		pVals  = map[string]interface{}{}
 	)
 	for _, p := range params {
-		var aryType *spanner.Type
-		if p.ArrayElementType != "" {
-			aryType = &spanner.Type{Code: p.ArrayElementType}
+		if p.Type == TypeArray && p.ArrayElementType == "" {
+			return nil, nil, errors.Errorf("param %q is an ARRAY without an ArrayElementType", p.Name)
+		}
+		if p.Type != "" && !p.Type.Valid() {
+			return nil, nil, errors.Errorf("param %q has invalid type %q", p.Name, string(p.Type))
+		}
+		if p.ArrayElementType != "" && !p.ArrayElementType.Valid() {
+			return nil, nil, errors.Errorf("param %q has invalid array element type %q", p.Name, string(p.ArrayElementType))
+		}
+		// leave untyped params for Spanner to infer rather than sending an
+		// unspecified type code
+		if p.Type != "" {
+			var aryType *spanner.Type
+			if p.ArrayElementType != "" {
+				aryType = &spanner.Type{Code: string(p.ArrayElementType)}
+			}
+			pTypes[p.Name] = spanner.Type{Code: string(p.Type), ArrayElementType: aryType}
+		}
+		if isNull(p.Value) {
+			// typed nils, such as a nil *big.Rat, would otherwise be encoded
+			// by their own marshalers
+			pVals[p.Name] = nil
+			continue
 		}
-		pTypes[p.Name] = spanner.Type{Code: p.Type, ArrayElementType: aryType}
 		pVals[p.Name] = p.Value
 	}
-	pJSON, err := json.Marshal(pVals)
+	var enc ParamsEncoder = jsonParamsEncoder{}
+	if c != nil && c.ParamsEncoder != nil {
+		enc = c.ParamsEncoder
+	}
+	pJSON, err := enc.EncodeParams(pVals)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to encode query params")
+		return nil, nil, errors.Wrap(err, "unable to encode query params")
 	}
-	res, err := s.sess.ExecuteSql(s.name, &spanner.ExecuteSqlRequest{
-		ParamTypes:  pTypes,
-		Params:      pJSON,
-		QueryMode:   queryMode,
-		Sql:         sql,
-		Transaction: tx,
-	}).Context(ctx).Do()
-	return res, errors.Wrap(err, "unable to execute query")
+	return pTypes, pJSON, nil
+}
+
+// query executes the given SQL and decodes all resulting rows into dst, which must
+// be a pointer to a slice of structs. A nil tx runs a strong single-use read.
+func (s *Session) query(ctx context.Context, sql string, params []*Param, tx *spanner.TransactionSelector, dst interface{}) error {
+	res, err := s.ExecuteSQL(ctx, params, sql, QueryModeNormal, tx)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(decodeRows(res, dst), "unable to decode query results")
+}
+
+func (c *Client) logf(ctx context.Context, format string, args ...interface{}) {
+	if c.Logf != nil {
+		c.Logf(ctx, format, args...)
+		return
+	}
+	log.Printf("spannerr: "+format, args...)
+}
+
+func (c *Client) newSpanner(ctx context.Context) (*spanner.Service, error) {
+	svc, _, err := c.service(ctx)
+	return svc, err
+}
+
+// service returns the Spanner service shared by all data calls and the HTTP
+// client it makes calls with, building them on first use or once they are older
+// than ServiceMaxAge. Credentials are resolved with the ctx of each call, not the
+// one that builds them.
+func (c *Client) service(ctx context.Context) (*spanner.Service, *http.Client, error) {
+	c.svcMu.Lock()
+	defer c.svcMu.Unlock()
+	if c.svc != nil && (c.ServiceMaxAge <= 0 || time.Since(c.svcBuilt) < c.ServiceMaxAge) {
+		return c.svc, c.svcHC, nil
+	}
+	var (
+		hc  *http.Client
+		svc *spanner.Service
+		err error
+	)
+	if c.NewService != nil {
+		hc = c.httpClient(c.dataScopes()...)
+		svc, err = c.NewService(ctx)
+	} else {
+		hc = c.compress(c.httpClient(c.dataScopes()...))
+		svc, err = c.newService(hc)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	c.svc, c.svcHC, c.svcBuilt = svc, hc, time.Now()
+	return svc, hc, nil
 }
 
-func newSpanner(ctx context.Context) (*spanner.Service, error) {
-	var client *http.Client
+// RefreshService discards the Spanner service shared by the Client's data calls,
+// so the next call builds a new one, for example after rotating credentials or
+// changing TokenSource, HTTPClient or NewService. Sessions already acquired keep
+// using the previous service until they are released.
+func (c *Client) RefreshService() {
+	c.svcMu.Lock()
+	defer c.svcMu.Unlock()
+	c.svc, c.svcHC = nil, nil
+}
+
+// newService returns a Spanner service making calls with hc to the Client's
+// Endpoint.
+func (c *Client) newService(hc *http.Client) (*spanner.Service, error) {
+	svc, err := spanner.New(hc)
+	if err != nil {
+		return nil, err
+	}
+	if c.Endpoint != "" {
+		svc.BasePath = c.Endpoint
+	}
+	return svc, nil
+}
+
+func (c *Client) dataScopes() []string {
+	if len(c.Scopes) > 0 {
+		return c.Scopes
+	}
+	return []string{spanner.SpannerDataScope}
+}
+
+// httpClient returns an HTTP client authenticated for the given scopes. If the
+// Client has an HTTPClient it is used as-is, otherwise requests carry tokens from
+// tokenSource, fetched with each request's own context.
+func (c *Client) httpClient(scopes ...string) *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return &http.Client{Transport: &authTransport{client: c, scopes: scopes}}
+}
+
+// tokenSource returns the Client's TokenSource, scoped if it is a
+// ScopedTokenSource, or, without one, App Engine (or default, on the dev server)
+// credentials for the given scopes built with ctx, and a description of where they
+// come from.
+func (c *Client) tokenSource(ctx context.Context, scopes []string) (oauth2.TokenSource, string, error) {
+	c.useLogger(c.TokenSource)
+	if sts, ok := c.TokenSource.(ScopedTokenSource); ok {
+		return sts.WithScopes(scopes...), "Client.TokenSource", nil
+	}
+	if c.TokenSource != nil {
+		return c.TokenSource, "Client.TokenSource", nil
+	}
 	if appengine.IsDevAppServer() {
-		var err error
-		client, err = google.DefaultClient(ctx, spanner.SpannerDataScope)
+		creds, err := google.FindDefaultCredentials(ctx, scopes...)
 		if err != nil {
-			return nil, errors.Wrap(err, "unable to init default client")
+			return nil, "application default credentials", errors.Wrap(err, "unable to find default credentials")
 		}
-	} else {
-		client = oauth2.NewClient(ctx, google.AppEngineTokenSource(ctx, spanner.SpannerDataScope))
+		return creds.TokenSource, "application default credentials", nil
 	}
-	return spanner.New(client)
+	return google.AppEngineTokenSource(ctx, scopes...), "App Engine service account", nil
 }
@@ -0,0 +1,174 @@
+package spannerrtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite spannerrtest golden files with the current results")
+
+// Golden compares decoded query results against golden files, for regression
+// testing queries such as reports. Results are stored as indented JSON in a
+// canonical form: rows are sorted unless Ordered is set, object keys are sorted
+// and timestamps are converted to UTC. Run the tests with -update-golden to write
+// the files after reviewing a change in results.
+type Golden struct {
+	// Dir is the directory holding the golden files. It defaults to "testdata".
+	Dir string
+	// Ordered keeps rows in the order they were returned, for queries with an
+	// ORDER BY clause.
+	Ordered bool
+	// Volatile lists the names of fields, as encoded in JSON, whose values
+	// change between runs, such as commit timestamps or generated keys. Their
+	// values are replaced with a placeholder.
+	Volatile []string
+}
+
+// volatileValue replaces the values of Golden.Volatile fields.
+const volatileValue = "<volatile>"
+
+// CheckGolden compares v against testdata/<name>.golden using the default Golden
+// options.
+func CheckGolden(t testing.TB, name string, v interface{}) {
+	t.Helper()
+	(&Golden{}).Check(t, name, v)
+}
+
+// Check compares the canonical form of v, typically a slice of structs decoded by
+// Client.Query, against the golden file <name>.golden, failing the test with the
+// first difference found.
+func (g *Golden) Check(t testing.TB, name string, v interface{}) {
+	t.Helper()
+	got, err := g.canonical(v)
+	if err != nil {
+		t.Fatalf("spannerrtest: unable to encode %s results: %s", name, err)
+	}
+	dir := g.Dir
+	if dir == "" {
+		dir = "testdata"
+	}
+	path := filepath.Join(dir, name+".golden")
+	if *updateGolden {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("spannerrtest: %s", err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("spannerrtest: %s", err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("spannerrtest: golden file %s does not exist; run with -update-golden to create it", path)
+	}
+	if err != nil {
+		t.Fatalf("spannerrtest: %s", err)
+	}
+	if line, w, gt, ok := firstDiff(want, got); !ok {
+		t.Errorf("spannerrtest: results differ from %s at line %d:\n\twant: %s\n\t got: %s", path, line, w, gt)
+	}
+}
+
+// canonical returns the canonical JSON encoding of v.
+func (g *Golden) canonical(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	// keep INT64 values exact
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	doc = g.normalize(doc)
+	if rows, ok := doc.([]interface{}); ok && !g.Ordered {
+		keys := make([]string, len(rows))
+		for i, r := range rows {
+			k, _ := json.Marshal(r)
+			keys[i] = string(k)
+		}
+		sort.Sort(byKey{rows, keys})
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// normalize masks volatile fields and converts timestamps to UTC.
+func (g *Golden) normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range v {
+			if g.volatile(k) && fv != nil {
+				v[k] = volatileValue
+				continue
+			}
+			v[k] = g.normalize(fv)
+		}
+	case []interface{}:
+		for i, ev := range v {
+			v[i] = g.normalize(ev)
+		}
+	case string:
+		if ts, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return ts.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return v
+}
+
+func (g *Golden) volatile(field string) bool {
+	for _, f := range g.Volatile {
+		if strings.EqualFold(f, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// byKey sorts rows by their encoded keys.
+type byKey struct {
+	rows []interface{}
+	keys []string
+}
+
+func (b byKey) Len() int           { return len(b.rows) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.rows[i], b.rows[j] = b.rows[j], b.rows[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+
+// firstDiff returns the first line at which want and got differ, if any.
+func firstDiff(want, got []byte) (line int, w, g string, same bool) {
+	wl := strings.Split(string(want), "\n")
+	gl := strings.Split(string(got), "\n")
+	for i := 0; i < len(wl) || i < len(gl); i++ {
+		w, g = "<eof>", "<eof>"
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			return i + 1, w, g, false
+		}
+	}
+	return 0, "", "", true
+}
@@ -0,0 +1,38 @@
+// Package spannerrtest provides helpers for integration tests that run against a
+// real Cloud Spanner database through a spannerr.Client.
+package spannerrtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jprobinson/spannerr"
+)
+
+// RunInRollback runs fn within a read-write transaction that is always rolled
+// back, even if fn fails the test or panics, so tests sharing a database don't
+// leave rows behind. The ctx passed to fn carries the transaction, so
+// spannerr.Client.Query and Client.Apply called with it participate in the
+// transaction.
+//
+// Only DML is visible to later statements within fn: mutations are buffered
+// until commit, so they are discarded without ever being applied.
+func RunInRollback(t testing.TB, c *spannerr.Client, fn func(ctx context.Context, txn *spannerr.Txn)) {
+	t.Helper()
+	ctx := context.Background()
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		t.Fatalf("spannerrtest: unable to acquire session: %s", err)
+	}
+	defer c.ReleaseSession(ctx, *sess)
+	txn, err := sess.BeginReadWrite(ctx)
+	if err != nil {
+		t.Fatalf("spannerrtest: %s", err)
+	}
+	defer func() {
+		if err := txn.Rollback(ctx); err != nil {
+			t.Errorf("spannerrtest: unable to roll back transaction: %s", err)
+		}
+	}()
+	fn(spannerr.NewContext(ctx, txn), txn)
+}
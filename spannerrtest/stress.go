@@ -0,0 +1,244 @@
+package spannerrtest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jprobinson/spannerr"
+)
+
+// StressOptions configures StressPool.
+type StressOptions struct {
+	// Workers is the number of goroutines hammering the pool. It defaults to
+	// four times the pool size.
+	Workers int
+	// Iterations is the number of acquire, execute and release cycles each
+	// worker runs. It defaults to 100.
+	Iterations int
+	// SQL is executed on each acquired session. It defaults to "SELECT 1".
+	SQL string
+	// AcquireTimeout bounds each AcquireSession call. It defaults to five
+	// seconds.
+	AcquireTimeout time.Duration
+}
+
+// StressReport summarizes a StressPool run.
+type StressReport struct {
+	Acquired int
+	// AcquireErrors and ExecErrors count failures by Spanner error code, or
+	// "CLIENT" for errors raised before reaching Spanner.
+	AcquireErrors map[string]int
+	ExecErrors    map[string]int
+}
+
+// StressPool hammers the Client's session pool from many goroutines at once,
+// acquiring sessions, executing a statement on them and releasing them, and fails
+// the test if the pool's accounting breaks: a session handed to two workers at
+// the same time, sessions left in use once every worker is done or more sessions
+// than the pool allows. Workers also release each session a second time, both
+// right away and after it may have been handed to another worker, which the pool
+// must ignore. Errors from Spanner are expected under fault injection,
+// see FaultTransport, and are only counted. Run it with -race to also catch
+// unsynchronized pool state.
+func StressPool(t testing.TB, c *spannerr.Client, opts StressOptions) *StressReport {
+	t.Helper()
+	maxSessions := c.PoolStats().MaxSessions
+	if opts.Workers <= 0 {
+		opts.Workers = 4 * maxSessions
+		if opts.Workers == 0 {
+			opts.Workers = 4
+		}
+	}
+	if opts.Iterations <= 0 {
+		opts.Iterations = 100
+	}
+	if opts.SQL == "" {
+		opts.SQL = "SELECT 1"
+	}
+	if opts.AcquireTimeout <= 0 {
+		opts.AcquireTimeout = 5 * time.Second
+	}
+
+	var (
+		mu      sync.Mutex
+		holders = map[string]int{}
+		rep     = &StressReport{AcquireErrors: map[string]int{}, ExecErrors: map[string]int{}}
+		wg      sync.WaitGroup
+	)
+	code := func(err error) string {
+		if c := spannerr.ErrorCode(err); c != "" {
+			return c
+		}
+		return "CLIENT"
+	}
+	ctx := context.Background()
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			var prev *spannerr.Session
+			for i := 0; i < opts.Iterations; i++ {
+				actx, cancel := context.WithTimeout(ctx, opts.AcquireTimeout)
+				sess, err := c.AcquireSession(actx)
+				cancel()
+				if err != nil {
+					mu.Lock()
+					rep.AcquireErrors[code(err)]++
+					mu.Unlock()
+					continue
+				}
+				if prev != nil {
+					// release-after-reacquire: the previous session may be
+					// held by another worker by now
+					c.ReleaseSession(ctx, *prev)
+				}
+				name := sess.Name()
+				mu.Lock()
+				rep.Acquired++
+				if other, ok := holders[name]; ok {
+					t.Errorf("spannerrtest: session %s handed to worker %d while held by worker %d", name, worker, other)
+				}
+				holders[name] = worker
+				if st := c.PoolStats(); st.Sessions > st.MaxSessions {
+					t.Errorf("spannerrtest: pool holds %d sessions, more than its maximum of %d", st.Sessions, st.MaxSessions)
+				}
+				mu.Unlock()
+
+				_, err = sess.ExecuteSQL(ctx, nil, opts.SQL, spannerr.QueryModeNormal, nil)
+
+				mu.Lock()
+				if err != nil {
+					rep.ExecErrors[code(err)]++
+				}
+				// forget the holder before releasing so the next holder isn't
+				// reported
+				delete(holders, name)
+				mu.Unlock()
+				c.ReleaseSession(ctx, *sess)
+				c.ReleaseSession(ctx, *sess)
+				prev = sess
+			}
+		}(w)
+	}
+	wg.Wait()
+	if st := c.PoolStats(); st.InUse != 0 {
+		t.Errorf("spannerrtest: %d sessions still in use after all workers released theirs", st.InUse)
+	}
+
+	// a release of an earlier acquisition must not free the current one
+	if sess, err := c.AcquireSession(ctx); err == nil {
+		stale := *sess
+		c.ReleaseSession(ctx, *sess)
+		if sess, err = c.AcquireSession(ctx); err == nil {
+			c.ReleaseSession(ctx, stale)
+			if st := c.PoolStats(); st.InUse != 1 {
+				t.Errorf("spannerrtest: stale release of session %s freed its new holder", stale.Name())
+			}
+			c.ReleaseSession(ctx, *sess)
+		}
+	}
+	return rep
+}
+
+// FaultTransport is an http.RoundTripper that fails a fraction of the requests
+// made through it with Spanner errors, for exercising retry and recovery paths.
+// Use it from Client.NewService:
+//
+//	ft := &spannerrtest.FaultTransport{Base: http.DefaultTransport, Rate: 0.1}
+//	c.HTTPClient = &http.Client{Transport: ft}
+//	c.NewService = func(ctx context.Context) (*spanner.Service, error) {
+//		return spanner.NewService(ctx, option.WithHTTPClient(c.HTTPClient))
+//	}
+type FaultTransport struct {
+	// Base makes the requests that are not failed.
+	Base http.RoundTripper
+	// Rate is the fraction of requests to fail, between 0 and 1.
+	Rate float64
+	// Faults are the failures injected, chosen at random for each failed
+	// request. It defaults to UNAVAILABLE, ABORTED and session NOT_FOUND.
+	Faults []Fault
+	// Seed seeds the choice of requests to fail, for reproducible runs.
+	Seed int64
+
+	once sync.Once
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// Fault is a failure injected by FaultTransport.
+type Fault struct {
+	// Status is the HTTP status code returned.
+	Status int
+	// Code is the canonical Spanner error code, such as "UNAVAILABLE".
+	Code string
+	// Methods, if set, limits the fault to request URLs ending with one of
+	// them, such as ":commit".
+	Methods []string
+	// Message is the error message returned. It defaults to "injected fault".
+	Message string
+}
+
+// DefaultFaults are the faults injected by a FaultTransport without Faults.
+var DefaultFaults = []Fault{
+	{Status: http.StatusServiceUnavailable, Code: "UNAVAILABLE"},
+	{Status: http.StatusConflict, Code: "ABORTED", Methods: []string{":commit", ":executeSql", ":executeBatchDml"}},
+	{Status: http.StatusNotFound, Code: "NOT_FOUND", Message: "Session not found: injected fault",
+		Methods: []string{":executeSql", ":executeStreamingSql", ":beginTransaction", ":commit"}},
+}
+
+// RoundTrip implements http.RoundTripper.
+func (f *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.once.Do(func() { f.rand = rand.New(rand.NewSource(f.Seed)) })
+	faults := f.Faults
+	if len(faults) == 0 {
+		faults = DefaultFaults
+	}
+	f.mu.Lock()
+	fail := f.rand.Float64() < f.Rate
+	pick := f.rand.Intn(len(faults))
+	f.mu.Unlock()
+	if fail {
+		if fault := faults[pick]; fault.applies(req.URL.Path) {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			msg := fault.Message
+			if msg == "" {
+				msg = "injected fault"
+			}
+			body := `{"error":{"code":` + strconv.Itoa(fault.Status) + `,"message":` + strconv.Quote(msg) + `,"status":"` + fault.Code + `"}}`
+			return &http.Response{
+				StatusCode: fault.Status,
+				Status:     http.StatusText(fault.Status),
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+				Request:    req,
+			}, nil
+		}
+	}
+	base := f.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
+
+func (f Fault) applies(path string) bool {
+	if len(f.Methods) == 0 {
+		return true
+	}
+	for _, m := range f.Methods {
+		if strings.HasSuffix(path, m) {
+			return true
+		}
+	}
+	return false
+}
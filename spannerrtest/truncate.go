@@ -0,0 +1,105 @@
+package spannerrtest
+
+import (
+	"context"
+
+	"github.com/jprobinson/spannerr"
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// TruncateTables deletes every row of the given tables, or of all tables if none
+// are given, for resetting a test database between tests. Tables interleaved in
+// a truncated table are truncated too. Rows are deleted in a single commit, with
+// children and referencing tables ordered before the tables they depend on, as
+// learned from the database schema.
+func TruncateTables(ctx context.Context, c *spannerr.Client, tables ...string) error {
+	schema, err := c.Schema(ctx)
+	if err != nil {
+		return err
+	}
+	children := map[string][]string{}
+	known := map[string]bool{}
+	for _, t := range schema {
+		known[t.Name] = true
+		if t.Parent != "" {
+			children[t.Parent] = append(children[t.Parent], t.Name)
+		}
+	}
+	if len(tables) == 0 {
+		for _, t := range schema {
+			tables = append(tables, t.Name)
+		}
+	}
+
+	// expand to interleaved descendants
+	truncate := map[string]bool{}
+	var add func(string)
+	add = func(name string) {
+		if truncate[name] {
+			return
+		}
+		truncate[name] = true
+		for _, child := range children[name] {
+			add(child)
+		}
+	}
+	for _, name := range tables {
+		if !known[name] {
+			return errors.Errorf("unknown table %q", name)
+		}
+		add(name)
+	}
+
+	// before maps each table to the tables that must be emptied first
+	before := map[string][]string{}
+	for parent, cs := range children {
+		before[parent] = append(before[parent], cs...)
+	}
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.ReleaseSession(ctx, *sess)
+	for name := range truncate {
+		fks, err := sess.ReferencingForeignKeys(ctx, name)
+		if err != nil {
+			return err
+		}
+		for _, fk := range fks {
+			if fk.Table != name {
+				before[name] = append(before[name], fk.Table)
+			}
+		}
+	}
+
+	var (
+		muts    []*spanner.Mutation
+		visited = map[string]bool{}
+	)
+	var visit func(string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		// marking first breaks foreign key cycles, which Spanner only checks at
+		// commit anyway
+		visited[name] = true
+		for _, dep := range before[name] {
+			if truncate[dep] {
+				visit(dep)
+			}
+		}
+		muts = append(muts, &spanner.Mutation{Delete: &spanner.Delete{
+			Table:  name,
+			KeySet: &spanner.KeySet{All: true},
+		}})
+	}
+	// visiting in reverse schema order keeps the result stable
+	for i := len(schema) - 1; i >= 0; i-- {
+		if truncate[schema[i].Name] {
+			visit(schema[i].Name)
+		}
+	}
+	return errors.Wrap(c.Apply(ctx, muts...), "unable to truncate tables")
+}
@@ -0,0 +1,122 @@
+package spannerr
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// StalenessMonitor periodically measures how far behind a bounded-stale read is
+// from a strong read, to help choose bounded staleness values with confidence.
+// Each sample issues a strong read and a bounded-stale read of "SELECT 1" and
+// compares the read timestamps Spanner chose for each.
+type StalenessMonitor struct {
+	Client *Client
+	// MaxStaleness is the bound used for the stale reads.
+	MaxStaleness time.Duration
+	// OnSample, if set, is called with every observed staleness, for example to
+	// record it in a metrics system.
+	OnSample func(time.Duration)
+
+	mu    sync.Mutex
+	stats StalenessStats
+}
+
+// StalenessStats summarizes the staleness observed by a StalenessMonitor.
+type StalenessStats struct {
+	Samples int
+	Last    time.Duration
+	Max     time.Duration
+	Mean    time.Duration
+}
+
+// Stats returns a summary of the samples taken so far.
+func (m *StalenessMonitor) Stats() StalenessStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// Run samples staleness every interval, or every minute if interval is not
+// positive, until ctx is done. Sampling errors are reported to the Client's
+// logger.
+func (m *StalenessMonitor) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(tickInterval(interval, time.Minute))
+	defer t.Stop()
+	for {
+		if _, err := m.Sample(ctx); err != nil {
+			m.Client.logf(ctx, "staleness monitor: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Sample takes a single staleness measurement.
+func (m *StalenessMonitor) Sample(ctx context.Context) (time.Duration, error) {
+	sess, err := m.Client.AcquireSession(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer m.Client.ReleaseSession(ctx, *sess)
+
+	strong, err := readTimestamp(ctx, sess, &spanner.ReadOnly{Strong: true, ReturnReadTimestamp: true})
+	if err != nil {
+		return 0, err
+	}
+	stale, err := readTimestamp(ctx, sess, &spanner.ReadOnly{
+		MaxStaleness:        durationString(m.MaxStaleness),
+		ReturnReadTimestamp: true,
+	})
+	if err != nil {
+		return 0, err
+	}
+	d := strong.Sub(stale)
+	if d < 0 {
+		// the stale read was served after the strong one and was fully caught up
+		d = 0
+	}
+
+	m.mu.Lock()
+	st := &m.stats
+	st.Mean = (st.Mean*time.Duration(st.Samples) + d) / time.Duration(st.Samples+1)
+	st.Samples++
+	st.Last = d
+	if d > st.Max {
+		st.Max = d
+	}
+	m.mu.Unlock()
+
+	if m.OnSample != nil {
+		m.OnSample(d)
+	}
+	return d, nil
+}
+
+// readTimestamp runs a trivial single-use read with the given options and returns
+// the timestamp it was served at.
+func readTimestamp(ctx context.Context, s *Session, ro *spanner.ReadOnly) (time.Time, error) {
+	res, err := s.ExecuteSQL(ctx, nil, "SELECT 1", QueryModeNormal, &spanner.TransactionSelector{
+		SingleUse: &spanner.TransactionOptions{ReadOnly: ro},
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if res.Metadata == nil || res.Metadata.Transaction == nil {
+		return time.Time{}, errors.New("no read timestamp returned")
+	}
+	ts, err := time.Parse(time.RFC3339Nano, res.Metadata.Transaction.ReadTimestamp)
+	return ts, errors.Wrap(err, "invalid read timestamp")
+}
+
+// durationString formats d as a protobuf Duration string, e.g. "1.5s".
+func durationString(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
@@ -0,0 +1,173 @@
+package spannerr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// StatsLogger writes a Client's pool and commit latency summary as a single
+// structured log line, for dashboards and log-based metrics built on Cloud
+// Logging without any metrics infrastructure. Lines are JSON objects in the
+// format second-generation App Engine runtimes and Cloud Run parse from stdout,
+// with the summary in jsonPayload. First-generation runtimes do not parse stdout,
+// so the lines would arrive there as plain text:
+//
+//	{"severity":"INFO","message":"spannerr stats: 3/10 sessions, 1 in use, 0 waiting; 42 commits, 1 aborts, avg 12ms",
+//	 "pool":{...},"interval":{...},"totals":{...},"logging.googleapis.com/labels":{...}}
+//
+// Pass Log to MaintenanceHandler to log from a cron job, or call Start to log on
+// a timer from instances that allow background work.
+type StatsLogger struct {
+	// Client is the Client whose Stats are logged by Start.
+	Client *Client
+	// Writer receives the log lines. It defaults to os.Stdout.
+	Writer io.Writer
+	// Severity is the severity of each line. It defaults to "INFO".
+	Severity string
+	// Labels are attached to each line as logging.googleapis.com/labels.
+	Labels map[string]string
+
+	mu      sync.Mutex
+	prev    TxnTagStats
+	prevMax map[string]time.Duration
+	last    time.Time
+}
+
+// statsLine is the JSON encoding of a StatsLogger line.
+type statsLine struct {
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"`
+	Time     string    `json:"time"`
+	Pool     PoolStats `json:"pool"`
+	// Interval covers the commits since the previous line and Totals those
+	// since the Client was created. Interval only has a max latency when a new
+	// maximum was seen for some tag since the previous line.
+	Interval commitSummary     `json:"interval"`
+	Totals   commitSummary     `json:"totals"`
+	Labels   map[string]string `json:"logging.googleapis.com/labels,omitempty"`
+}
+
+type commitSummary struct {
+	Seconds      float64 `json:"seconds,omitempty"`
+	Commits      int64   `json:"commits"`
+	Aborts       int64   `json:"aborts"`
+	Failures     int64   `json:"failures"`
+	Retries      int64   `json:"retries"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms,omitempty"`
+}
+
+// Start logs the Client's Stats every interval, or every minute if interval is
+// not positive, until the returned function is called.
+func (l *StatsLogger) Start(ctx context.Context, interval time.Duration) (stop func()) {
+	c := l.Client
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		tick := time.NewTicker(tickInterval(interval, time.Minute))
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+			if err := l.Log(ctx, c.Stats()); err != nil {
+				c.logf(ctx, "unable to log stats: %s", err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
+
+// Log writes st as a log line. Its signature matches the flush function taken by
+// MaintenanceHandler.
+func (l *StatsLogger) Log(ctx context.Context, st Stats) error {
+	var total TxnTagStats
+	for _, t := range st.Transactions {
+		total.Commits += t.Commits
+		total.Aborts += t.Aborts
+		total.Failures += t.Failures
+		total.Retries += t.Retries
+		total.TotalLatency += t.TotalLatency
+		if t.MaxLatency > total.MaxLatency {
+			total.MaxLatency = t.MaxLatency
+		}
+	}
+
+	l.mu.Lock()
+	now := time.Now().UTC()
+	delta := TxnTagStats{
+		Commits:      total.Commits - l.prev.Commits,
+		Aborts:       total.Aborts - l.prev.Aborts,
+		Failures:     total.Failures - l.prev.Failures,
+		Retries:      total.Retries - l.prev.Retries,
+		TotalLatency: total.TotalLatency - l.prev.TotalLatency,
+	}
+	// only the running maximum of each tag is kept, so the interval's maximum is
+	// known only where it raised one
+	max := make(map[string]time.Duration, len(st.Transactions))
+	for tag, t := range st.Transactions {
+		max[tag] = t.MaxLatency
+		if t.MaxLatency > l.prevMax[tag] && t.MaxLatency > delta.MaxLatency {
+			delta.MaxLatency = t.MaxLatency
+		}
+	}
+	var seconds float64
+	if !l.last.IsZero() {
+		seconds = now.Sub(l.last).Seconds()
+	}
+	l.prev, l.prevMax, l.last = total, max, now
+	l.mu.Unlock()
+
+	line := statsLine{
+		Severity: l.Severity,
+		Time:     now.Format(time.RFC3339Nano),
+		Pool:     st.Pool,
+		Interval: summarize(delta),
+		Totals:   summarize(total),
+		Labels:   l.Labels,
+	}
+	line.Interval.Seconds = seconds
+	if line.Severity == "" {
+		line.Severity = "INFO"
+	}
+	line.Message = fmt.Sprintf("spannerr stats: %d/%d sessions, %d in use, %d waiting; %d commits, %d aborts, avg %s",
+		st.Pool.Sessions, st.Pool.MaxSessions, st.Pool.InUse, st.Pool.Waiting,
+		delta.Commits, delta.Aborts, delta.AvgLatency().Round(time.Millisecond))
+
+	w := l.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	// a single write keeps lines from concurrent loggers intact
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func summarize(st TxnTagStats) commitSummary {
+	return commitSummary{
+		Commits:      st.Commits,
+		Aborts:       st.Aborts,
+		Failures:     st.Failures,
+		Retries:      st.Retries,
+		AvgLatencyMs: float64(st.AvgLatency()) / float64(time.Millisecond),
+		MaxLatencyMs: float64(st.MaxLatency) / float64(time.Millisecond),
+	}
+}
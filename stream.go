@@ -0,0 +1,513 @@
+package spannerr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// DefaultPrefetch is the number of PartialResultSet chunks a RowIterator reads ahead
+// of its consumer when StreamOptions.Prefetch is not set.
+const DefaultPrefetch = 4
+
+// StreamOptions configures a streaming query.
+type StreamOptions struct {
+	// Prefetch is the maximum number of PartialResultSet chunks buffered ahead of
+	// the consumer. Once the buffer is full the response body is no longer read,
+	// so a slow consumer applies backpressure to the server through HTTP flow
+	// control instead of buffering the result set in memory.
+	Prefetch int
+	// PartitionToken restricts the query to a single partition returned by
+	// Client.PartitionQuery.
+	PartitionToken string
+	// OnStats, if set, is called from Next with each ResultSetStats sent during
+	// the stream, such as the interim row counts of partitioned DML.
+	OnStats func(*spanner.ResultSetStats)
+}
+
+// RowIterator iterates over the rows of a streaming query. Its usage mirrors
+// database/sql.Rows:
+//
+//	it, err := sess.ExecuteStreamingSQL(ctx, params, sql, spannerr.QueryModeNormal, nil, nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		row := it.Row()
+//		...
+//	}
+//	return it.Err()
+type RowIterator struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	exit     func()
+	chunks   chan streamChunk
+	prefetch int
+	onStats  func(*spanner.ResultSetStats)
+
+	// state for resuming the stream, see restart
+	sess        *Session
+	req         *spanner.ExecuteSqlRequest
+	replacement *Session
+	restartable bool
+	replaceable bool
+	restarts    int
+	resumeToken string
+	readTS      string
+	// covered is the number of pending values the last resume token covers;
+	// values past it are held back while the stream is restartable
+	covered        int
+	coveredChunked bool
+	coveredTail    interface{}
+
+	metadata *spanner.ResultSetMetadata
+	stats    *spanner.ResultSetStats
+	pending  []interface{}
+	chunked  bool
+	row      []interface{}
+	err      error
+}
+
+// maxHoldback is the number of values a restartable RowIterator holds back while
+// waiting for a resume token before it gives up on restarting.
+const maxHoldback = 1 << 14
+
+// maxStreamRestarts bounds the number of times a RowIterator restarts its stream.
+const maxStreamRestarts = 3
+
+type streamChunk struct {
+	prs *spanner.PartialResultSet
+	err error
+	// broken is set when the response could not be read, such as when the
+	// connection was reset, rather than Spanner failing the query.
+	broken bool
+}
+
+// ExecuteStreamingSQL executes an SQL statement and streams the results back as
+// they are produced rather than in a single reply, which allows result sets larger
+// than the ExecuteSql reply limit. The returned iterator must be closed.
+//
+// If the stream is interrupted because Spanner is briefly unavailable or the
+// connection breaks, it is resumed on the same session, whatever the transaction.
+// If the session expires during a single-use read-only query, the iterator
+// restarts it once on a new pooled session, resuming at the same read timestamp;
+// any other transaction dies with its session. Restarts are counted in Restarts.
+// To make them possible, rows are held back until Spanner marks them resumable
+// with a resume token.
+// This function wraps https://godoc.org/google.golang.org/api/spanner/v1#ProjectsInstancesDatabasesSessionsService.ExecuteStreamingSql
+func (s *Session) ExecuteStreamingSQL(ctx context.Context, params []*Param, sql string, queryMode QueryMode, tx *spanner.TransactionSelector, opts *StreamOptions) (*RowIterator, error) {
+	if err := checkQueryMode(queryMode); err != nil {
+		return nil, err
+	}
+	pTypes, pJSON, err := s.prepareSQL(ctx, sql, params)
+	if err != nil {
+		return nil, err
+	}
+	prefetch := DefaultPrefetch
+	if opts == nil {
+		opts = &StreamOptions{}
+	}
+	if opts.Prefetch > 0 {
+		prefetch = opts.Prefetch
+	}
+	sqlReq := &spanner.ExecuteSqlRequest{
+		ParamTypes:     pTypes,
+		Params:         pJSON,
+		QueryMode:      string(queryMode),
+		Sql:            sql,
+		Transaction:    tx,
+		PartitionToken: opts.PartitionToken,
+		Seqno:          s.nextSeqno(),
+	}
+	if c := s.client; len(c.ReplicaPreferences) > 0 && directedReadable(tx) {
+		sqlReq.DirectedReadOptions = &spanner.DirectedReadOptions{
+			IncludeReplicas: &spanner.IncludeReplicas{
+				ReplicaSelections: c.ReplicaPreferences[:1],
+			},
+		}
+	}
+	if replaceable(tx) {
+		// record the read timestamp so a restart reads the same data
+		txOpts := spanner.TransactionOptions{ReadOnly: &spanner.ReadOnly{Strong: true}}
+		if tx != nil {
+			txOpts = *tx.SingleUse
+			ro := *txOpts.ReadOnly
+			txOpts.ReadOnly = &ro
+		}
+		txOpts.ReadOnly.ReturnReadTimestamp = true
+		sqlReq.Transaction = &spanner.TransactionSelector{SingleUse: &txOpts}
+	}
+	exit, err := s.enter(ctx, "execute streaming sql")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	it := &RowIterator{
+		ctx:         ctx,
+		cancel:      cancel,
+		exit:        exit,
+		prefetch:    prefetch,
+		onStats:     opts.OnStats,
+		sess:        s,
+		req:         sqlReq,
+		restartable: true,
+		replaceable: replaceable(tx),
+	}
+	if err := it.start(); err != nil {
+		if !it.retryable(err, false) {
+			s.client.reportError(ctx, "execute streaming sql", sql, err)
+			it.Close()
+			return nil, err
+		}
+		if err := it.restart(err); err != nil {
+			it.Close()
+			return nil, err
+		}
+	}
+	return it, nil
+}
+
+// replaceable reports whether a streaming query using tx can be restarted on
+// another session. Only single-use read-only transactions can be; any other
+// transaction dies with its session.
+func replaceable(tx *spanner.TransactionSelector) bool {
+	return tx == nil || (tx.SingleUse != nil && tx.SingleUse.ReadOnly != nil)
+}
+
+// start sends the iterator's request on its current session and starts reading
+// the response.
+func (it *RowIterator) start() error {
+	s := it.sess
+	if it.replacement != nil {
+		s = it.replacement
+	}
+	body, err := json.Marshal(it.req)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode streaming query")
+	}
+	req, err := http.NewRequest(http.MethodPost,
+		s.basePath+"v1/"+s.name+":executeStreamingSql?alt=json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "unable to create streaming query request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.httpClient(it.ctx).Do(req.WithContext(it.ctx))
+	if err != nil {
+		return errors.Wrap(err, "unable to execute streaming query")
+	}
+	if err := googleapi.CheckResponse(resp); err != nil {
+		resp.Body.Close()
+		return errors.Wrap(err, "unable to execute streaming query")
+	}
+	it.chunks = make(chan streamChunk, it.prefetch)
+	go it.read(it.ctx, it.chunks, resp.Body)
+	return nil
+}
+
+// retryable reports whether the stream can be restarted after err without losing
+// or repeating any row it has returned: either Spanner was unavailable or the
+// response broken, or the session was not found during a single-use read and has
+// not been replaced before.
+func (it *RowIterator) retryable(err error, broken bool) bool {
+	if !it.restartable || it.sess.client == nil || it.restarts >= maxStreamRestarts || it.ctx.Err() != nil {
+		return false
+	}
+	if isSessionNotFound(err) {
+		return it.replaceable && it.replacement == nil
+	}
+	return broken || ErrorCode(err) == "UNAVAILABLE"
+}
+
+// restart reissues the query, resuming from the last resume token at the
+// timestamp the original stream read at, so the rows seen are the same as if it
+// had not been interrupted. If the session expired, it is dropped from the
+// Client's pool and the query reissued on a new one. On the same session the
+// request keeps its sequence number, so DML is not applied twice.
+func (it *RowIterator) restart(cause error) error {
+	c := it.sess.client
+	sess := it.sess
+	if it.replacement != nil {
+		sess = it.replacement
+	}
+	if isSessionNotFound(cause) {
+		c.logf(it.ctx, "session %s expired during streaming query, restarting: %s", it.sess.name, cause)
+		c.smu.Lock()
+		c.deleteSession(it.ctx, it.sess.name)
+		c.smu.Unlock()
+
+		var err error
+		if sess, err = c.AcquireSession(it.ctx); err != nil {
+			return errors.Wrap(err, "unable to restart streaming query")
+		}
+		it.replacement = sess
+		it.req.Seqno = sess.nextSeqno()
+	} else {
+		c.logf(it.ctx, "streaming query interrupted, resuming: %s", cause)
+	}
+	it.restarts++
+	it.pending, it.chunked = it.pending[:it.covered], it.coveredChunked
+	if it.chunked {
+		// undo merges into the chunked value made after the resume token
+		it.pending[it.covered-1] = it.coveredTail
+	}
+	it.req.ResumeToken = it.resumeToken
+	if it.replaceable && it.readTS != "" {
+		it.req.Transaction = &spanner.TransactionSelector{SingleUse: &spanner.TransactionOptions{
+			ReadOnly: &spanner.ReadOnly{ReadTimestamp: it.readTS, ReturnReadTimestamp: true},
+		}}
+	}
+	if err := it.start(); err != nil {
+		if it.retryable(err, false) {
+			return it.restart(err)
+		}
+		return errors.Wrap(err, "unable to restart streaming query")
+	}
+	return nil
+}
+
+// read decodes the JSON array of PartialResultSets in the response body, blocking
+// whenever the prefetch buffer is full.
+func (it *RowIterator) read(ctx context.Context, chunks chan<- streamChunk, body io.ReadCloser) {
+	defer close(chunks)
+	defer body.Close()
+
+	send := func(c streamChunk) bool {
+		select {
+		case chunks <- c:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	dec := json.NewDecoder(body)
+	if _, err := dec.Token(); err != nil {
+		send(streamChunk{err: errors.Wrap(err, "unable to read streaming response"), broken: true})
+		return
+	}
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			send(streamChunk{err: errors.Wrap(err, "unable to read streaming response"), broken: true})
+			return
+		}
+		if err := streamError(raw); err != nil {
+			send(streamChunk{err: errors.Wrap(err, "streaming query failed")})
+			return
+		}
+		var prs spanner.PartialResultSet
+		if err := json.Unmarshal(raw, &prs); err != nil {
+			send(streamChunk{err: errors.Wrap(err, "unable to decode partial result set")})
+			return
+		}
+		if !send(streamChunk{prs: &prs}) {
+			return
+		}
+	}
+}
+
+// streamError returns the error embedded in a stream element, if any. Errors that
+// occur after the response has started are sent as a final element of the form
+// {"error": {...}}.
+func streamError(raw json.RawMessage) error {
+	var msg struct {
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(raw, &msg) != nil || msg.Error == nil {
+		return nil
+	}
+	return &googleapi.Error{Code: msg.Error.Code, Message: msg.Error.Message, Body: string(raw)}
+}
+
+// Next advances the iterator to the next row, returning false when there are no
+// more rows or an error occurred. Check Err after Next returns false.
+func (it *RowIterator) Next() bool {
+	it.row = nil
+	if it.err != nil {
+		return false
+	}
+	for {
+		if it.metadata != nil {
+			n := len(it.metadata.RowType.Fields)
+			complete, chunked := len(it.pending), it.chunked
+			if it.restartable {
+				// only return rows a restart could resume after
+				complete, chunked = it.covered, it.coveredChunked
+			}
+			if chunked {
+				complete--
+			}
+			if n > 0 && complete >= n {
+				it.row, it.pending = it.pending[:n:n], it.pending[n:]
+				if it.covered -= n; it.covered < 0 {
+					it.covered = 0
+				}
+				return true
+			}
+		}
+		c, ok := <-it.chunks
+		if !ok {
+			if it.restartable {
+				// the stream is complete, so every value is final
+				it.restartable = false
+				continue
+			}
+			if len(it.pending) > 0 {
+				it.err = errors.New("streaming query ended with an incomplete row")
+			}
+			return false
+		}
+		if c.err != nil {
+			if it.retryable(c.err, c.broken) {
+				for range it.chunks {
+				}
+				if err := it.restart(c.err); err == nil {
+					continue
+				}
+			}
+			it.err = c.err
+			return false
+		}
+		if err := it.add(c.prs); err != nil {
+			it.err = err
+			return false
+		}
+	}
+}
+
+// add appends the values of prs to the pending values, merging any chunked value.
+func (it *RowIterator) add(prs *spanner.PartialResultSet) error {
+	if prs.Metadata != nil && it.metadata == nil {
+		it.metadata = prs.Metadata
+		if tx := prs.Metadata.Transaction; tx != nil {
+			it.readTS = tx.ReadTimestamp
+		}
+		if it.metadata.RowType == nil {
+			it.metadata.RowType = &spanner.StructType{}
+		}
+	}
+	if prs.Stats != nil {
+		it.stats = prs.Stats
+		if it.onStats != nil {
+			if err := protect(func() error {
+				it.onStats(prs.Stats)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	vals := prs.Values
+	if it.chunked && len(vals) > 0 {
+		last := len(it.pending) - 1
+		merged, err := mergeChunk(it.pending[last], vals[0])
+		if err != nil {
+			return err
+		}
+		it.pending[last] = merged
+		vals = vals[1:]
+	}
+	it.pending = append(it.pending, vals...)
+	it.chunked = prs.ChunkedValue
+	if prs.ResumeToken != "" {
+		it.resumeToken = prs.ResumeToken
+		it.covered, it.coveredChunked = len(it.pending), it.chunked
+		if it.chunked {
+			it.coveredTail = it.pending[it.covered-1]
+		}
+	}
+	if it.restartable && len(it.pending)-it.covered > maxHoldback {
+		// too much to hold back; return rows as they arrive instead
+		it.restartable = false
+	}
+	if it.metadata == nil && len(it.pending) > 0 {
+		return errors.New("streaming query returned values before metadata")
+	}
+	return nil
+}
+
+// mergeChunk merges a chunked value with its continuation. Strings are
+// concatenated and lists are concatenated with their adjoining string or list
+// elements merged recursively.
+func mergeChunk(a, b interface{}) (interface{}, error) {
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return nil, errors.Errorf("cannot merge chunked string with %T", b)
+		}
+		return av + bv, nil
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			return nil, errors.Errorf("cannot merge chunked list with %T", b)
+		}
+		if len(av) == 0 || len(bv) == 0 {
+			return append(av, bv...), nil
+		}
+		last := av[len(av)-1]
+		switch last.(type) {
+		case string, []interface{}:
+			merged, err := mergeChunk(last, bv[0])
+			if err != nil {
+				return nil, err
+			}
+			out := append(av[:len(av)-1:len(av)-1], merged)
+			return append(out, bv[1:]...), nil
+		}
+		return append(av, bv...), nil
+	}
+	return nil, errors.Errorf("cannot merge chunked %T", a)
+}
+
+// Row returns the current row. It is only valid until the next call to Next.
+func (it *RowIterator) Row() []interface{} {
+	return it.row
+}
+
+// Metadata returns the result set metadata, which is available once Next has been
+// called.
+func (it *RowIterator) Metadata() *spanner.ResultSetMetadata {
+	return it.metadata
+}
+
+// Stats returns the statistics for the statement, such as the DML row count or
+// the PROFILE query plan. They are only available once the iterator is exhausted.
+func (it *RowIterator) Stats() *spanner.ResultSetStats {
+	return it.stats
+}
+
+// Restarts returns the number of times the stream was transparently restarted
+// after its session expired or it was interrupted.
+func (it *RowIterator) Restarts() int {
+	return it.restarts
+}
+
+// Err returns the error, if any, that stopped iteration.
+func (it *RowIterator) Err() error {
+	return it.err
+}
+
+// Close stops the stream and releases its resources. It is safe to call Close
+// more than once.
+func (it *RowIterator) Close() {
+	it.cancel()
+	if it.chunks != nil {
+		for range it.chunks {
+		}
+	}
+	it.exit()
+	if it.replacement != nil {
+		it.replacement.client.ReleaseSession(it.ctx, *it.replacement)
+		it.replacement = nil
+	}
+}
@@ -0,0 +1,201 @@
+package spannerr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+func TestMergeChunk(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "strings", a: "ab", b: "cd", want: "abcd"},
+		{name: "empty string", a: "", b: "cd", want: "cd"},
+		{
+			name: "list of strings",
+			a:    []interface{}{"a", "b"},
+			b:    []interface{}{"c", "d"},
+			want: []interface{}{"a", "bc", "d"},
+		},
+		{
+			name: "list of numbers",
+			a:    []interface{}{1.5},
+			b:    []interface{}{2.5},
+			want: []interface{}{1.5, 2.5},
+		},
+		{
+			name: "nested lists",
+			a:    []interface{}{[]interface{}{"a", "b"}},
+			b:    []interface{}{[]interface{}{"c"}, []interface{}{"d"}},
+			want: []interface{}{[]interface{}{"a", "bc"}, []interface{}{"d"}},
+		},
+		{
+			name: "list of structs",
+			a:    []interface{}{[]interface{}{"1", true}},
+			b:    []interface{}{[]interface{}{false}},
+			want: []interface{}{[]interface{}{"1", true, false}},
+		},
+		{name: "empty first list", a: []interface{}{}, b: []interface{}{"a"}, want: []interface{}{"a"}},
+		{name: "empty second list", a: []interface{}{"a"}, b: []interface{}{}, want: []interface{}{"a"}},
+		{name: "string and number", a: "a", b: 1.0, wantErr: true},
+		{name: "list and string", a: []interface{}{"a"}, b: "b", wantErr: true},
+		{name: "number", a: 1.0, b: 2.0, wantErr: true},
+		{name: "nested mismatch", a: []interface{}{"a"}, b: []interface{}{[]interface{}{"b"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := json.Marshal(tt.a)
+			got, err := mergeChunk(tt.a, tt.b)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+			// a is restored on restarts, so it must not be modified
+			if after, _ := json.Marshal(tt.a); string(after) != string(before) {
+				t.Errorf("first chunk modified from %s to %s", before, after)
+			}
+		})
+	}
+}
+
+// streamBreak ends a fake streaming response by dropping the connection.
+const streamBreak = "<break>"
+
+func TestStreamResume(t *testing.T) {
+	const meta = `"metadata":{"rowType":{"fields":[` +
+		`{"name":"n","type":{"code":"INT64"}},{"name":"s","type":{"code":"STRING"}}]}}`
+	tests := []struct {
+		name string
+		// responses are the bodies of successive executeStreamingSql calls
+		responses []string
+		// tokens are the resume tokens the calls are expected to carry
+		tokens []string
+		want   [][]interface{}
+	}{
+		{
+			name: "uninterrupted",
+			responses: []string{
+				`[{` + meta + `,"values":["1","a"],"resumeToken":"t1"},{"values":["2","b"]}]`,
+			},
+			tokens: []string{""},
+			want:   [][]interface{}{{"1", "a"}, {"2", "b"}},
+		},
+		{
+			name: "break before any token",
+			responses: []string{
+				`[{` + meta + `,"values":["1","a","2"]}` + streamBreak,
+				`[{` + meta + `,"values":["1","a","2","b"],"resumeToken":"t1"}]`,
+			},
+			tokens: []string{"", ""},
+			want:   [][]interface{}{{"1", "a"}, {"2", "b"}},
+		},
+		{
+			name: "break mid-row",
+			responses: []string{
+				`[{` + meta + `,"values":["1","a"],"resumeToken":"t1"},{"values":["2"]}` + streamBreak,
+				`[{"values":["2","b"],"resumeToken":"t2"},{"values":["3","c"]}]`,
+			},
+			tokens: []string{"", "t1"},
+			want:   [][]interface{}{{"1", "a"}, {"2", "b"}, {"3", "c"}},
+		},
+		{
+			name: "break mid-chunk",
+			responses: []string{
+				`[{` + meta + `,"values":["1","ab"],"chunkedValue":true,"resumeToken":"t1"},` +
+					`{"values":["cd","2","e"],"chunkedValue":true}` + streamBreak,
+				`[{"values":["cd","2","ef"],"resumeToken":"t2"}]`,
+			},
+			tokens: []string{"", "t1"},
+			want:   [][]interface{}{{"1", "abcd"}, {"2", "ef"}},
+		},
+		{
+			name: "unavailable",
+			responses: []string{
+				`[{` + meta + `,"values":["1","a"],"resumeToken":"t1"},{"values":["2","b"]},` +
+					`{"error":{"code":503,"message":"unavailable","status":"UNAVAILABLE"}}]`,
+				`[{"values":["2","b"],"resumeToken":"t2"}]`,
+			},
+			tokens: []string{"", "t1"},
+			want:   [][]interface{}{{"1", "a"}, {"2", "b"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				tokens []string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req spanner.ExecuteSqlRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				mu.Lock()
+				n := len(tokens)
+				tokens = append(tokens, req.ResumeToken)
+				mu.Unlock()
+				if n >= len(tt.responses) {
+					t.Errorf("unexpected call %d", n+1)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				body := tt.responses[n]
+				if !strings.HasSuffix(body, streamBreak) {
+					w.Write([]byte(body))
+					return
+				}
+				w.Write([]byte(strings.TrimSuffix(body, streamBreak)))
+				w.(http.Flusher).Flush()
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+			}))
+			defer srv.Close()
+
+			c := NewClient("p", "i", "d", WithEndpoint(srv.URL+"/"), WithHTTPClient(http.DefaultClient))
+			c.Logf = func(context.Context, string, ...interface{}) {}
+			ctx := context.Background()
+			s, err := c.session(ctx, "projects/p/instances/i/databases/d/sessions/s")
+			if err != nil {
+				t.Fatal(err)
+			}
+			it, err := s.ExecuteStreamingSQL(ctx, nil, "SELECT n, s FROM T", QueryModeNormal, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer it.Close()
+			var got [][]interface{}
+			for it.Next() {
+				got = append(got, append([]interface{}(nil), it.Row()...))
+			}
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got rows %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(tokens, tt.tokens) {
+				t.Errorf("got resume tokens %q, want %q", tokens, tt.tokens)
+			}
+			if want := len(tt.responses) - 1; it.Restarts() != want {
+				t.Errorf("got %d restarts, want %d", it.Restarts(), want)
+			}
+		})
+	}
+}
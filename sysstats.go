@@ -0,0 +1,137 @@
+package spannerr
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StatsInterval selects which of the SPANNER_SYS statistics tables to query.
+type StatsInterval string
+
+const (
+	// StatsMinute selects the *_TOP_MINUTE statistics tables.
+	StatsMinute StatsInterval = "MINUTE"
+	// Stats10Minute selects the *_TOP_10MINUTE statistics tables.
+	Stats10Minute StatsInterval = "10MINUTE"
+	// StatsHour selects the *_TOP_HOUR statistics tables.
+	StatsHour StatsInterval = "HOUR"
+)
+
+func (i StatsInterval) table(prefix string) (string, error) {
+	switch i {
+	case StatsMinute, Stats10Minute, StatsHour:
+		return "SPANNER_SYS." + prefix + "_TOP_" + string(i), nil
+	}
+	return "", errors.Errorf("invalid stats interval %q", string(i))
+}
+
+type (
+	// QueryStat is a row from the SPANNER_SYS.QUERY_STATS_TOP_* tables.
+	// More details can be found here: https://cloud.google.com/spanner/docs/introspection/query-statistics
+	QueryStat struct {
+		IntervalEnd                           time.Time `spanner:"INTERVAL_END"`
+		RequestTag                            string    `spanner:"REQUEST_TAG"`
+		QueryType                             string    `spanner:"QUERY_TYPE"`
+		Text                                  string    `spanner:"TEXT"`
+		TextTruncated                         bool      `spanner:"TEXT_TRUNCATED"`
+		TextFingerprint                       int64     `spanner:"TEXT_FINGERPRINT"`
+		ExecutionCount                        int64     `spanner:"EXECUTION_COUNT"`
+		AvgLatencySeconds                     float64   `spanner:"AVG_LATENCY_SECONDS"`
+		AvgRows                               float64   `spanner:"AVG_ROWS"`
+		AvgBytes                              float64   `spanner:"AVG_BYTES"`
+		AvgRowsScanned                        float64   `spanner:"AVG_ROWS_SCANNED"`
+		AvgCPUSeconds                         float64   `spanner:"AVG_CPU_SECONDS"`
+		AllFailedExecutionCount               int64     `spanner:"ALL_FAILED_EXECUTION_COUNT"`
+		AllFailedAvgLatencySeconds            float64   `spanner:"ALL_FAILED_AVG_LATENCY_SECONDS"`
+		CancelledOrDisconnectedExecutionCount int64     `spanner:"CANCELLED_OR_DISCONNECTED_EXECUTION_COUNT"`
+		TimedOutExecutionCount                int64     `spanner:"TIMED_OUT_EXECUTION_COUNT"`
+	}
+
+	// TxnStat is a row from the SPANNER_SYS.TXN_STATS_TOP_* tables.
+	// More details can be found here: https://cloud.google.com/spanner/docs/introspection/transaction-statistics
+	TxnStat struct {
+		IntervalEnd                   time.Time `spanner:"INTERVAL_END"`
+		Fingerprint                   int64     `spanner:"FPRINT"`
+		ReadColumns                   []string  `spanner:"READ_COLUMNS"`
+		WriteConstructiveColumns      []string  `spanner:"WRITE_CONSTRUCTIVE_COLUMNS"`
+		WriteDeleteTables             []string  `spanner:"WRITE_DELETE_TABLES"`
+		CommitAttemptCount            int64     `spanner:"COMMIT_ATTEMPT_COUNT"`
+		CommitAbortCount              int64     `spanner:"COMMIT_ABORT_COUNT"`
+		CommitRetryCount              int64     `spanner:"COMMIT_RETRY_COUNT"`
+		CommitFailedPreconditionCount int64     `spanner:"COMMIT_FAILED_PRECONDITION_COUNT"`
+		AvgParticipants               float64   `spanner:"AVG_PARTICIPANTS"`
+		AvgTotalLatencySeconds        float64   `spanner:"AVG_TOTAL_LATENCY_SECONDS"`
+		AvgCommitLatencySeconds       float64   `spanner:"AVG_COMMIT_LATENCY_SECONDS"`
+		AvgBytes                      float64   `spanner:"AVG_BYTES"`
+	}
+
+	// LockStat is a row from the SPANNER_SYS.LOCK_STATS_TOP_* tables.
+	// More details can be found here: https://cloud.google.com/spanner/docs/introspection/lock-statistics
+	LockStat struct {
+		IntervalEnd        time.Time         `spanner:"INTERVAL_END"`
+		RowRangeStartKey   []byte            `spanner:"ROW_RANGE_START_KEY"`
+		LockWaitSeconds    float64           `spanner:"LOCK_WAIT_SECONDS"`
+		SampleLockRequests []LockRequestStat `spanner:"SAMPLE_LOCK_REQUESTS"`
+	}
+
+	// LockRequestStat is a sampled lock request within a LockStat.
+	LockRequestStat struct {
+		LockMode string `spanner:"lock_mode"`
+		Column   string `spanner:"column"`
+	}
+)
+
+// TopQueriesByCPU returns up to limit queries from the most recent statistics
+// interval, ordered by total CPU time (AVG_CPU_SECONDS * EXECUTION_COUNT).
+func (s *Session) TopQueriesByCPU(ctx context.Context, interval StatsInterval, limit int64) ([]*QueryStat, error) {
+	return s.QueryStats(ctx, interval, "AVG_CPU_SECONDS * EXECUTION_COUNT", limit)
+}
+
+// QueryStats returns up to limit rows from the most recent query statistics interval
+// ordered descending by the given expression, which must be one of the numeric
+// columns of QueryStat (e.g. "AVG_LATENCY_SECONDS") or the total CPU expression
+// "AVG_CPU_SECONDS * EXECUTION_COUNT".
+func (s *Session) QueryStats(ctx context.Context, interval StatsInterval, orderBy string, limit int64) ([]*QueryStat, error) {
+	switch orderBy {
+	case "AVG_CPU_SECONDS * EXECUTION_COUNT", "EXECUTION_COUNT", "AVG_LATENCY_SECONDS",
+		"AVG_ROWS", "AVG_BYTES", "AVG_ROWS_SCANNED", "AVG_CPU_SECONDS",
+		"ALL_FAILED_EXECUTION_COUNT", "TIMED_OUT_EXECUTION_COUNT":
+	default:
+		return nil, errors.Errorf("invalid query stats ordering %q", orderBy)
+	}
+	var stats []*QueryStat
+	err := s.latestStats(ctx, "QUERY_STATS", interval, orderBy, limit, &stats)
+	return stats, err
+}
+
+// TxnStats returns up to limit rows from the most recent transaction statistics
+// interval, ordered by average commit latency.
+func (s *Session) TxnStats(ctx context.Context, interval StatsInterval, limit int64) ([]*TxnStat, error) {
+	var stats []*TxnStat
+	err := s.latestStats(ctx, "TXN_STATS", interval, "AVG_COMMIT_LATENCY_SECONDS", limit, &stats)
+	return stats, err
+}
+
+// LockStats returns up to limit rows from the most recent lock statistics interval,
+// ordered by lock wait time.
+func (s *Session) LockStats(ctx context.Context, interval StatsInterval, limit int64) ([]*LockStat, error) {
+	var stats []*LockStat
+	err := s.latestStats(ctx, "LOCK_STATS", interval, "LOCK_WAIT_SECONDS", limit, &stats)
+	return stats, err
+}
+
+func (s *Session) latestStats(ctx context.Context, prefix string, interval StatsInterval, orderBy string, limit int64, dst interface{}) error {
+	table, err := interval.table(prefix)
+	if err != nil {
+		return err
+	}
+	sql := "SELECT * FROM " + table +
+		" WHERE INTERVAL_END = (SELECT MAX(INTERVAL_END) FROM " + table + ")" +
+		" ORDER BY " + orderBy + " DESC LIMIT @limit"
+	return s.query(ctx, sql, []*Param{
+		{Name: "limit", Value: strconv.FormatInt(limit, 10), Type: TypeInt64},
+	}, nil, dst)
+}
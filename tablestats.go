@@ -0,0 +1,73 @@
+package spannerr
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// TableSize is a row from the SPANNER_SYS.TABLE_SIZES_STATS_1HOUR table.
+// More details can be found here: https://cloud.google.com/spanner/docs/introspection/table-sizes-statistics
+type TableSize struct {
+	IntervalEnd time.Time `spanner:"INTERVAL_END"`
+	TableName   string    `spanner:"TABLE_NAME"`
+	UsedBytes   float64   `spanner:"USED_BYTES"`
+}
+
+// countStaleness is the staleness used for row counts. Stale reads can be served
+// by any replica without blocking on writes.
+const countStaleness = "15s"
+
+// RowCount returns the number of rows in the given table as of a few seconds ago.
+// Spanner keeps no row count statistics, so this scans the whole table, or its
+// smallest index, and on large tables is too costly to run from monitoring
+// endpoints; use TableSize there instead. Reading at a stale timestamp avoids
+// contending with live writes.
+func (s *Session) RowCount(ctx context.Context, table string) (int64, error) {
+	qt, err := quoteIdent(table)
+	if err != nil {
+		return 0, err
+	}
+	var rows []struct {
+		Count int64 `spanner:"row_count"`
+	}
+	err = s.query(ctx, "SELECT COUNT(*) AS row_count FROM "+qt, nil,
+		&spanner.TransactionSelector{SingleUse: &spanner.TransactionOptions{
+			ReadOnly: &spanner.ReadOnly{ExactStaleness: countStaleness},
+		}}, &rows)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, errors.New("no results from row count query")
+	}
+	return rows[0].Count, nil
+}
+
+// TableSizes returns the size of every table from the most recent hourly
+// table size statistics, largest first.
+func (s *Session) TableSizes(ctx context.Context) ([]*TableSize, error) {
+	var sizes []*TableSize
+	err := s.query(ctx, "SELECT * FROM SPANNER_SYS.TABLE_SIZES_STATS_1HOUR"+
+		" WHERE INTERVAL_END = (SELECT MAX(INTERVAL_END) FROM SPANNER_SYS.TABLE_SIZES_STATS_1HOUR)"+
+		" ORDER BY USED_BYTES DESC", nil, nil, &sizes)
+	return sizes, err
+}
+
+// TableSize returns the size of the given table from the most recent hourly
+// table size statistics.
+func (s *Session) TableSize(ctx context.Context, table string) (*TableSize, error) {
+	var sizes []*TableSize
+	err := s.query(ctx, "SELECT * FROM SPANNER_SYS.TABLE_SIZES_STATS_1HOUR"+
+		" WHERE TABLE_NAME = @table ORDER BY INTERVAL_END DESC LIMIT 1",
+		[]*Param{{Name: "table", Value: table, Type: TypeString}}, nil, &sizes)
+	if err != nil {
+		return nil, err
+	}
+	if len(sizes) == 0 {
+		return nil, errors.Errorf("no size statistics found for table %q", table)
+	}
+	return sizes[0], nil
+}
@@ -0,0 +1,215 @@
+package spannerr
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// TenantParam is the name of the param holding the current tenant in statements
+// scoped by a TenantScope.
+const TenantParam = "__tenant"
+
+// ErrNoTenant is returned by Exec when a tenant scoped statement is executed with
+// a ctx that carries no tenant.
+var ErrNoTenant = errors.New("no tenant in context for tenant scoped statement")
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx carrying the current tenant, which Exec passes
+// as the @__tenant param of tenant scoped statements.
+func WithTenant(ctx context.Context, tenant interface{}) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant attached to ctx with WithTenant.
+func TenantFromContext(ctx context.Context) (interface{}, bool) {
+	tenant := ctx.Value(tenantKey{})
+	return tenant, tenant != nil
+}
+
+// TenantScope restricts the statements of a Registry to the current tenant in
+// shared-schema databases, where every table has a tenant column. When set as
+// Registry.Tenant, each statement registered afterwards is rewritten to filter
+// its target table on the tenant column:
+//
+//	SELECT * FROM Orders o WHERE o.Status = @status ORDER BY o.Created
+//
+// becomes
+//
+//	SELECT * FROM Orders o WHERE (o.Status = @status) AND o.tenant_id = @__tenant ORDER BY o.Created
+//
+// The target table is the first table of the top-level FROM clause of a query,
+// or the table of an UPDATE or DELETE. Other joined tables are not filtered, so
+// join them on the tenant column too. Statements that already use @__tenant are
+// assumed to be scoped by hand and are left as they are; this is required for
+// INSERTs, compound queries and queries with a WITH clause, which can't be
+// rewritten. Mark statements that legitimately span tenants as Unscoped.
+type TenantScope struct {
+	// Column is the tenant column. It defaults to "tenant_id".
+	Column string
+	// Type is the Spanner type of the tenant column. It defaults to "STRING".
+	Type string
+}
+
+func (ts *TenantScope) column() string {
+	if ts.Column == "" {
+		return "tenant_id"
+	}
+	return ts.Column
+}
+
+func (ts *TenantScope) paramType() string {
+	if ts.Type == "" {
+		return "STRING"
+	}
+	return ts.Type
+}
+
+// Rewrite returns sql with a predicate on the tenant column added to the WHERE
+// clause of its target table, or an error if the statement can't be rewritten.
+func (ts *TenantScope) Rewrite(sql string) (string, error) {
+	for _, m := range paramRE.FindAllStringSubmatch(stripHints(stripComments(sql)), -1) {
+		if m[1] == TenantParam {
+			return sql, nil
+		}
+	}
+	toks := sqlTokens(sql)
+	if len(toks) == 0 {
+		return "", errors.New("empty statement")
+	}
+	var target int
+	switch strings.ToUpper(toks[0].text) {
+	case "SELECT":
+		target = -1
+		depth := 0
+		for i, tok := range toks {
+			switch {
+			case tok.text == "(":
+				depth++
+			case tok.text == ")":
+				depth--
+			case depth == 0 && tok.ident && !tok.quoted && strings.EqualFold(tok.text, "FROM"):
+				target = i + 1
+			}
+			if target >= 0 {
+				break
+			}
+		}
+		if target < 0 {
+			return "", errors.New("query has no FROM clause to scope")
+		}
+	case "UPDATE":
+		target = 1
+	case "DELETE":
+		target = 1
+		if len(toks) > 1 && strings.EqualFold(toks[1].text, "FROM") {
+			target = 2
+		}
+	default:
+		return "", errors.Errorf("only SELECT, UPDATE and DELETE statements can be scoped automatically; use @%s explicitly", TenantParam)
+	}
+
+	rs := []rune(sql)
+	// qualifier is the alias of the target table or, failing that, its name
+	i := target
+	if i >= len(toks) || !toks[i].ident || toks[i].keyword() {
+		return "", errors.New("target is not a table")
+	}
+	qualifier := string(rs[toks[i].pos:toks[i].end])
+	for i++; i+1 < len(toks) && toks[i].text == "." && toks[i+1].ident; i += 2 {
+		qualifier += "." + string(rs[toks[i+1].pos:toks[i+1].end])
+	}
+	if i < len(toks) && toks[i].text == "(" {
+		return "", errors.New("target is a table-valued function")
+	}
+	if i < len(toks) && strings.EqualFold(toks[i].text, "AS") {
+		i++
+	}
+	if i < len(toks) && toks[i].ident && !toks[i].keyword() {
+		qualifier = string(rs[toks[i].pos:toks[i].end])
+	}
+
+	// find the top-level WHERE clause and the end of the condition, where the
+	// next clause starts
+	var (
+		where = -1
+		next  = len(rs)
+		depth = 0
+	)
+scan:
+	for ; i < len(toks); i++ {
+		tok := toks[i]
+		switch {
+		case tok.text == "(":
+			depth++
+		case tok.text == ")":
+			depth--
+		case depth != 0:
+		case tok.text == ";":
+			next = tok.pos
+			break scan
+		case !tok.ident || tok.quoted:
+		default:
+			switch strings.ToUpper(tok.text) {
+			case "UNION", "INTERSECT", "EXCEPT":
+				return "", errors.Errorf("compound queries can't be scoped automatically; use @%s explicitly", TenantParam)
+			case "WHERE":
+				if where < 0 {
+					where = tok.end
+				}
+			case "GROUP", "HAVING", "WINDOW", "QUALIFY", "ORDER", "LIMIT", "OFFSET", "FOR", "THEN", "RETURNING":
+				next = tok.pos
+				break scan
+			}
+		}
+	}
+	// the predicate goes right after the last token of the condition, so a
+	// comment between it and the next clause, or ending the statement, can't
+	// swallow the predicate
+	tail := 0
+	for _, tok := range lexSQL(sql) {
+		if tok.pos < next && tok.end > tail {
+			tail = tok.end
+		}
+	}
+
+	pred := qualifier + "." + ts.column() + " = @" + TenantParam
+	var b strings.Builder
+	if where < 0 {
+		b.WriteString(string(rs[:tail]))
+		b.WriteString(" WHERE " + pred)
+	} else {
+		b.WriteString(string(rs[:where]))
+		b.WriteString(" (")
+		b.WriteString(strings.TrimSpace(string(rs[where:tail])))
+		b.WriteString(") AND " + pred)
+	}
+	if tail < len(rs) && !unicode.IsSpace(rs[tail]) && rs[tail] != ';' {
+		b.WriteString(" ")
+	}
+	b.WriteString(string(rs[tail:]))
+	return b.String(), nil
+}
+
+// tenantArgs returns args with the tenant from ctx added if st is tenant scoped.
+func (st *Statement) tenantArgs(ctx context.Context, args map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := st.Params[TenantParam]; !ok {
+		return args, nil
+	}
+	if _, ok := args[TenantParam]; ok {
+		return nil, errors.Errorf("statement %q: @%s is set from the context and can't be passed as an argument", st.Name, TenantParam)
+	}
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, errors.Wrapf(ErrNoTenant, "statement %q", st.Name)
+	}
+	out := make(map[string]interface{}, len(args)+1)
+	for k, v := range args {
+		out[k] = v
+	}
+	out[TenantParam] = tenant
+	return out, nil
+}
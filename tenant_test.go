@@ -0,0 +1,145 @@
+package spannerr
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTenantScopeRewrite(t *testing.T) {
+	tests := []struct {
+		sql, want string
+	}{
+		{
+			"SELECT * FROM Orders",
+			"SELECT * FROM Orders WHERE Orders.tenant_id = @__tenant",
+		},
+		{
+			"SELECT * FROM Orders o WHERE o.Status = @status ORDER BY o.Created",
+			"SELECT * FROM Orders o WHERE (o.Status = @status) AND o.tenant_id = @__tenant ORDER BY o.Created",
+		},
+		{
+			"SELECT COUNT(*) FROM `Orders`@{FORCE_INDEX=ByStatus} AS x GROUP BY x.Status;",
+			"SELECT COUNT(*) FROM `Orders`@{FORCE_INDEX=ByStatus} AS x WHERE x.tenant_id = @__tenant GROUP BY x.Status;",
+		},
+		{
+			"SELECT * FROM Orders WHERE a = 1 OR b IN (SELECT b FROM T WHERE c ORDER BY d) LIMIT 5",
+			"SELECT * FROM Orders WHERE (a = 1 OR b IN (SELECT b FROM T WHERE c ORDER BY d)) AND Orders.tenant_id = @__tenant LIMIT 5",
+		},
+		{
+			"UPDATE Users SET Name = @n WHERE Id = @id THEN RETURN Id",
+			"UPDATE Users SET Name = @n WHERE (Id = @id) AND Users.tenant_id = @__tenant THEN RETURN Id",
+		},
+		{
+			"DELETE FROM Users u WHERE true",
+			"DELETE FROM Users u WHERE (true) AND u.tenant_id = @__tenant",
+		},
+		{
+			"DELETE Users WHERE Id = @id RETURNING Id",
+			"DELETE Users WHERE (Id = @id) AND Users.tenant_id = @__tenant RETURNING Id",
+		},
+		// trailing comments must not swallow the predicate
+		{
+			"SELECT * FROM Orders -- everything",
+			"SELECT * FROM Orders WHERE Orders.tenant_id = @__tenant -- everything",
+		},
+		{
+			"SELECT * FROM Orders # everything",
+			"SELECT * FROM Orders WHERE Orders.tenant_id = @__tenant # everything",
+		},
+		{
+			"SELECT * FROM Orders WHERE id = 1 -- one\n",
+			"SELECT * FROM Orders WHERE (id = 1) AND Orders.tenant_id = @__tenant -- one\n",
+		},
+		{
+			"SELECT * FROM Orders WHERE id = 1 -- one\nORDER BY id",
+			"SELECT * FROM Orders WHERE (id = 1) AND Orders.tenant_id = @__tenant -- one\nORDER BY id",
+		},
+		{
+			"SELECT * FROM Orders /* all */",
+			"SELECT * FROM Orders WHERE Orders.tenant_id = @__tenant /* all */",
+		},
+		{
+			"SELECT * FROM Orders WHERE id = 1 -- one\n  OR id = 2",
+			"SELECT * FROM Orders WHERE (id = 1 -- one\n  OR id = 2) AND Orders.tenant_id = @__tenant",
+		},
+		{
+			"SELECT * FROM Orders WHERE Note = '-- x' AND id = @id # by id",
+			"SELECT * FROM Orders WHERE (Note = '-- x' AND id = @id) AND Orders.tenant_id = @__tenant # by id",
+		},
+		{
+			"SELECT * FROM Orders@{FORCE_INDEX=ByStatus} -- hinted",
+			"SELECT * FROM Orders@{FORCE_INDEX=ByStatus} WHERE Orders.tenant_id = @__tenant -- hinted",
+		},
+		// locking clauses end the condition
+		{
+			"SELECT * FROM Orders WHERE id = 1 FOR UPDATE",
+			"SELECT * FROM Orders WHERE (id = 1) AND Orders.tenant_id = @__tenant FOR UPDATE",
+		},
+		{
+			"SELECT * FROM Orders FOR UPDATE",
+			"SELECT * FROM Orders WHERE Orders.tenant_id = @__tenant FOR UPDATE",
+		},
+		// already scoped by hand
+		{
+			"SELECT * FROM T WHERE tenant_id = @__tenant",
+			"SELECT * FROM T WHERE tenant_id = @__tenant",
+		},
+	}
+	ts := &TenantScope{}
+	for _, tt := range tests {
+		got, err := ts.Rewrite(tt.sql)
+		if err != nil {
+			t.Errorf("Rewrite(%q) failed: %s", tt.sql, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Rewrite(%q)\n got %q\nwant %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestTenantScopeRewriteRejects(t *testing.T) {
+	ts := &TenantScope{}
+	for _, sql := range []string{
+		"INSERT INTO T (a) VALUES (1)",
+		"SELECT a FROM T UNION ALL SELECT a FROM U",
+		"WITH x AS (SELECT 1) SELECT * FROM x",
+		"SELECT 1",
+		"SELECT 1 -- FROM T",
+		"SELECT * FROM UNNEST(@a)",
+		"",
+	} {
+		if got, err := ts.Rewrite(sql); err == nil {
+			t.Errorf("Rewrite(%q) = %q, want an error", sql, got)
+		}
+	}
+}
+
+func TestTenantArgs(t *testing.T) {
+	r := NewRegistry()
+	r.Tenant = &TenantScope{Type: "INT64"}
+	if err := r.Register(Statement{Name: "get", SQL: "SELECT * FROM T WHERE id = @id", Params: map[string]string{"id": "INT64"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(Statement{Name: "insert", SQL: "INSERT INTO T (a) VALUES (1)"}); err == nil {
+		t.Error("registering an unscopable statement succeeded")
+	}
+	st, ok := r.Lookup("get")
+	if !ok {
+		t.Fatal("statement not registered")
+	}
+	ctx := context.Background()
+	if _, err := st.tenantArgs(ctx, map[string]interface{}{"id": 1}); err == nil {
+		t.Error("tenantArgs without a tenant succeeded")
+	}
+	if _, err := st.tenantArgs(WithTenant(ctx, 7), map[string]interface{}{"id": 1, TenantParam: 8}); err == nil {
+		t.Error("tenantArgs with an explicit tenant argument succeeded")
+	}
+	args, err := st.tenantArgs(WithTenant(ctx, 7), map[string]interface{}{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[TenantParam] != 7 {
+		t.Errorf("tenant argument is %v, want 7", args[TenantParam])
+	}
+}
@@ -0,0 +1,97 @@
+package spannerr
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TTLJob deletes rows whose timestamp column is older than a maximum age, for
+// schemas that predate native row deletion policies. Rows are deleted with
+// partitioned DML, one window of the timestamp column at a time, so each
+// statement does a bounded amount of work and the job can be rate limited.
+type TTLJob struct {
+	Client *Client
+	Table  string
+	// Column is the TIMESTAMP column compared against the cutoff.
+	Column string
+	// MaxAge is the age past which rows are deleted.
+	MaxAge time.Duration
+	// Window is the span of Column values deleted by each statement. It
+	// defaults to one hour.
+	Window time.Duration
+	// Interval is the minimum time between statements.
+	Interval time.Duration
+}
+
+// Run deletes every row older than MaxAge, oldest first, returning a lower bound
+// on the number of rows deleted. It can be stopped by cancelling ctx and run again
+// later, for example from a cron handler.
+func (j *TTLJob) Run(ctx context.Context) (int64, error) {
+	table, err := quoteIdent(j.Table)
+	if err != nil {
+		return 0, err
+	}
+	col, err := quoteIdent(j.Column)
+	if err != nil {
+		return 0, err
+	}
+	if j.MaxAge <= 0 {
+		return 0, errors.New("ttl max age must be positive")
+	}
+	window := j.Window
+	if window <= 0 {
+		window = time.Hour
+	}
+	cutoff := time.Now().Add(-j.MaxAge)
+
+	sess, err := j.Client.AcquireSession(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer j.Client.ReleaseSession(ctx, *sess)
+
+	var oldest []struct {
+		Min *time.Time `spanner:"min"`
+	}
+	err = sess.query(ctx, "SELECT MIN("+col+") AS min FROM "+table+" WHERE "+col+" < @cutoff",
+		[]*Param{valueParam("cutoff", cutoff)}, nil, &oldest)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to find oldest row")
+	}
+	if len(oldest) == 0 || oldest[0].Min == nil {
+		return 0, nil
+	}
+
+	var (
+		deleted int64
+		del     = "DELETE FROM " + table + " WHERE " + col + " >= @lo AND " + col + " < @hi"
+		ticker  *time.Ticker
+	)
+	if j.Interval > 0 {
+		ticker = time.NewTicker(j.Interval)
+		defer ticker.Stop()
+	}
+	// the first window starts at the oldest row; the last ends at the cutoff
+	for lo := *oldest[0].Min; lo.Before(cutoff); lo = lo.Add(window) {
+		hi := lo.Add(window)
+		if hi.After(cutoff) {
+			hi = cutoff
+		}
+		n, err := sess.ExecutePartitionedDML(ctx,
+			[]*Param{valueParam("lo", lo), valueParam("hi", hi)}, del)
+		deleted += n
+		if err != nil {
+			return deleted, errors.Wrapf(err, "unable to delete rows before %s", hi.Format(time.RFC3339))
+		}
+		if ticker != nil && hi.Before(cutoff) {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return deleted, ctx.Err()
+			}
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,165 @@
+package spannerr
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// Txn is a read-write transaction on a Session. Mutations written to a Txn are
+// buffered locally and sent to Spanner when the transaction is committed.
+type Txn struct {
+	// Session is the session the transaction was started on. All reads and
+	// queries within the transaction must use this session.
+	Session *Session
+	// ID is the Spanner transaction ID.
+	ID string
+
+	mu        sync.Mutex
+	mutations []*spanner.Mutation
+	// checkpoints maps checkpoint names to the number of buffered mutations
+	// when they were taken.
+	checkpoints map[string]int
+
+	started time.Time
+	// lastUsed is the time of the last request made in the transaction, in
+	// Unix nanoseconds, or zero if unknown.
+	lastUsed int64
+}
+
+// BeginReadWrite starts a new read-write transaction on the session.
+func (s *Session) BeginReadWrite(ctx context.Context) (*Txn, error) {
+	tx, err := s.BeginTransaction(ctx, &spanner.BeginTransactionRequest{
+		Options: &spanner.TransactionOptions{ReadWrite: &spanner.ReadWrite{}},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to begin transaction")
+	}
+	now := time.Now()
+	return &Txn{Session: s, ID: tx.Id, started: now, lastUsed: now.UnixNano()}, nil
+}
+
+// Selector returns a TransactionSelector for executing statements within the transaction.
+func (t *Txn) Selector() *spanner.TransactionSelector {
+	return &spanner.TransactionSelector{Id: t.ID}
+}
+
+// BufferWrite adds mutations to be applied when the transaction commits.
+func (t *Txn) BufferWrite(muts ...*spanner.Mutation) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mutations = append(t.mutations, muts...)
+}
+
+// Checkpoint records the current state of the transaction's mutation buffer under
+// name, replacing any earlier checkpoint of the same name, so that mutations
+// buffered afterwards can be discarded with RollbackTo.
+func (t *Txn) Checkpoint(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.checkpoints == nil {
+		t.checkpoints = map[string]int{}
+	}
+	t.checkpoints[name] = len(t.mutations)
+}
+
+// RollbackTo discards the mutations buffered since the named checkpoint was
+// taken, along with any checkpoints taken after it. The checkpoint itself is kept
+// so it can be rolled back to again. DML statements executed in the transaction
+// are applied by Spanner immediately and are not undone.
+func (t *Txn) RollbackTo(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, ok := t.checkpoints[name]
+	if !ok {
+		return errors.Errorf("no checkpoint %q in transaction", name)
+	}
+	// clear the discarded mutations so they can be garbage collected
+	for i := n; i < len(t.mutations); i++ {
+		t.mutations[i] = nil
+	}
+	t.mutations = t.mutations[:n]
+	for cp, cn := range t.checkpoints {
+		if cn > n {
+			delete(t.checkpoints, cp)
+		}
+	}
+	return nil
+}
+
+// ExecuteSQL executes a query or DML statement within the transaction.
+func (t *Txn) ExecuteSQL(ctx context.Context, params []*Param, sql string) (*spanner.ResultSet, error) {
+	res, err := t.Session.ExecuteSQL(ctx, params, sql, QueryModeNormal, t.Selector())
+	if err == nil {
+		t.touch()
+	}
+	return res, err
+}
+
+// Commit commits the transaction along with all buffered mutations.
+func (t *Txn) Commit(ctx context.Context) (*spanner.CommitResponse, error) {
+	t.mu.Lock()
+	muts := t.mutations
+	t.mu.Unlock()
+	res, err := t.Session.Commit(ctx, muts, nil, t.ID)
+	return res, t.expiredError(err)
+}
+
+// Rollback rolls back the transaction, discarding all buffered mutations.
+func (t *Txn) Rollback(ctx context.Context) error {
+	t.mu.Lock()
+	t.mutations = nil
+	t.checkpoints = nil
+	t.mu.Unlock()
+	return t.Session.Rollback(ctx, t.ID)
+}
+
+type txnKey struct{}
+
+// NewContext returns a copy of ctx carrying the given transaction. Client.Query
+// and Client.Apply called with the returned context participate in the
+// transaction rather than running on their own.
+func NewContext(ctx context.Context, txn *Txn) context.Context {
+	return context.WithValue(ctx, txnKey{}, txn)
+}
+
+// FromContextTxn returns the transaction carried by ctx, if any.
+func FromContextTxn(ctx context.Context) (*Txn, bool) {
+	txn, ok := ctx.Value(txnKey{}).(*Txn)
+	return txn, ok && txn != nil
+}
+
+// Query executes the given SQL and decodes all resulting rows into dst, a pointer to
+// a slice of structs. If ctx carries a transaction the query runs within it,
+// otherwise it runs as a strong single-use read on a pooled session.
+func (c *Client) Query(ctx context.Context, sql string, params []*Param, dst interface{}) error {
+	if txn, ok := FromContextTxn(ctx); ok {
+		return txn.Session.query(ctx, sql, params, txn.Selector(), dst)
+	}
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.ReleaseSession(ctx, *sess)
+	return sess.query(ctx, sql, params, nil, dst)
+}
+
+// Apply writes the given mutations. If ctx carries a transaction the mutations are
+// buffered in it, otherwise they are committed immediately in a single-use
+// read-write transaction on a pooled session.
+func (c *Client) Apply(ctx context.Context, muts ...*spanner.Mutation) error {
+	if txn, ok := FromContextTxn(ctx); ok {
+		txn.BufferWrite(muts...)
+		return nil
+	}
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.ReleaseSession(ctx, *sess)
+	_, err = sess.Commit(ctx, muts, &spanner.TransactionOptions{ReadWrite: &spanner.ReadWrite{}}, "")
+	return errors.Wrap(err, "unable to commit mutations")
+}
@@ -0,0 +1,104 @@
+package spannerr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// txnIdleTimeout is how long Spanner lets a read-write transaction sit idle
+// before it may be aborted.
+const txnIdleTimeout = 10 * time.Second
+
+// TxnExpiredError is returned by Txn.Commit when the transaction could not be
+// committed because Spanner discarded it after it sat idle for too long. Use
+// Txn.Heartbeat to keep long-running transactions alive.
+type TxnExpiredError struct {
+	// Age is the time since the transaction began.
+	Age time.Duration
+	// Idle is the time the transaction had been idle before the commit.
+	Idle time.Duration
+	Err  error
+}
+
+func (e *TxnExpiredError) Error() string {
+	return fmt.Sprintf("transaction expired after %s idle (%s old): %s",
+		e.Idle.Round(time.Millisecond), e.Age.Round(time.Millisecond), e.Err)
+}
+
+// Cause returns the underlying API error.
+func (e *TxnExpiredError) Cause() error { return e.Err }
+
+// Unwrap returns the underlying API error.
+func (e *TxnExpiredError) Unwrap() error { return e.Err }
+
+// touch records activity on the transaction.
+func (t *Txn) touch() {
+	atomic.StoreInt64(&t.lastUsed, time.Now().UnixNano())
+}
+
+// expiredError returns a *TxnExpiredError wrapping err if err looks like the
+// result of the transaction having been idle for too long, and err otherwise.
+func (t *Txn) expiredError(err error) error {
+	last := atomic.LoadInt64(&t.lastUsed)
+	if err == nil || last == 0 {
+		return err
+	}
+	idle := time.Since(time.Unix(0, last))
+	if idle < txnIdleTimeout {
+		return err
+	}
+	switch ErrorCode(err) {
+	case "ABORTED", "NOT_FOUND", "FAILED_PRECONDITION":
+		return &TxnExpiredError{Age: time.Since(t.started), Idle: idle, Err: err}
+	}
+	return err
+}
+
+// Heartbeat keeps the transaction alive while the caller does slow work between
+// statements, such as calling other services, by running a trivial query in the
+// transaction every interval, which must be shorter than ten seconds and
+// defaults to five seconds if not positive. Call the returned function to stop the heartbeat before committing.
+func (t *Txn) Heartbeat(ctx context.Context, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		tick := time.NewTicker(tickInterval(interval, 5*time.Second))
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+			exit, err := t.Session.enterBackground(ctx, "transaction heartbeat")
+			if err != nil {
+				// a statement is running, which keeps the transaction alive
+				continue
+			}
+			_, err = t.Session.rpc(ctx).ExecuteSql(t.Session.name, &spanner.ExecuteSqlRequest{
+				Sql:         "SELECT 1",
+				Transaction: t.Selector(),
+				Seqno:       t.Session.nextSeqno(),
+			}).Context(ctx).Do()
+			exit()
+			if err != nil {
+				t.Session.client.logf(ctx, "transaction heartbeat failed: %s", err)
+				return
+			}
+			t.touch()
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
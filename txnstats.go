@@ -0,0 +1,161 @@
+package spannerr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+type txnTagKey struct{}
+
+// WithTransactionTag returns a copy of ctx that tags transactions begun or committed
+// with it. Tags are sent to Spanner, where they appear in the transaction
+// statistics tables, and are used to key the Client's in-process commit stats.
+func WithTransactionTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, txnTagKey{}, tag)
+}
+
+func transactionTag(ctx context.Context) string {
+	tag, _ := ctx.Value(txnTagKey{}).(string)
+	return tag
+}
+
+// TxnTagStats holds commit statistics for a single transaction tag.
+type TxnTagStats struct {
+	Commits  int64 `json:"commits"`
+	Aborts   int64 `json:"aborts"`
+	Failures int64 `json:"failures"`
+	Retries  int64 `json:"retries"`
+	// TotalLatency and MaxLatency measure successful commit calls.
+	TotalLatency time.Duration `json:"total_latency_ns"`
+	MaxLatency   time.Duration `json:"max_latency_ns"`
+}
+
+// AvgLatency returns the mean latency of successful commits.
+func (s TxnTagStats) AvgLatency() time.Duration {
+	if s.Commits == 0 {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Commits)
+}
+
+// AbortRate returns the fraction of commit attempts that were aborted.
+func (s TxnTagStats) AbortRate() float64 {
+	attempts := s.Commits + s.Aborts + s.Failures
+	if attempts == 0 {
+		return 0
+	}
+	return float64(s.Aborts) / float64(attempts)
+}
+
+type txnStats struct {
+	mu   sync.Mutex
+	tags map[string]*TxnTagStats
+}
+
+func (t *txnStats) update(tag string, fn func(*TxnTagStats)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tags == nil {
+		t.tags = map[string]*TxnTagStats{}
+	}
+	st, ok := t.tags[tag]
+	if !ok {
+		st = &TxnTagStats{}
+		t.tags[tag] = st
+	}
+	fn(st)
+}
+
+// recordCommit records the outcome of a commit call.
+func (c *Client) recordCommit(ctx context.Context, start time.Time, err error) {
+	lat := time.Since(start)
+	c.txnStats.update(transactionTag(ctx), func(st *TxnTagStats) {
+		switch {
+		case err == nil:
+			st.Commits++
+			st.TotalLatency += lat
+			if lat > st.MaxLatency {
+				st.MaxLatency = lat
+			}
+		case IsAborted(err):
+			st.Aborts++
+		default:
+			st.Failures++
+		}
+	})
+}
+
+// recordRetry records a retry of the transaction tagged in ctx.
+func (c *Client) recordRetry(ctx context.Context) {
+	c.txnStats.update(transactionTag(ctx), func(st *TxnTagStats) { st.Retries++ })
+}
+
+// TransactionStats returns a snapshot of the commit statistics of every
+// transaction tag seen by the Client. Untagged transactions are keyed by "".
+func (c *Client) TransactionStats() map[string]TxnTagStats {
+	c.txnStats.mu.Lock()
+	defer c.txnStats.mu.Unlock()
+	out := make(map[string]TxnTagStats, len(c.txnStats.tags))
+	for tag, st := range c.txnStats.tags {
+		out[tag] = *st
+	}
+	return out
+}
+
+// PoolStats describes the state of the Client's session pool.
+type PoolStats struct {
+	Sessions    int `json:"sessions"`
+	InUse       int `json:"in_use"`
+	MaxSessions int `json:"max_sessions"`
+	// Waiting is the number of AcquireSession calls waiting for a session; see
+	// Client.WaitForSession.
+	Waiting int `json:"waiting"`
+}
+
+// PoolStats returns a snapshot of the session pool.
+func (c *Client) PoolStats() PoolStats {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	st := PoolStats{Sessions: len(c.sessions), MaxSessions: c.maxSessions, Waiting: len(c.waiters)}
+	for _, info := range c.sessions {
+		if info.inUse {
+			st.InUse++
+		}
+	}
+	return st
+}
+
+// Stats is the snapshot served by StatsHandler.
+type Stats struct {
+	Pool         PoolStats              `json:"pool"`
+	Transactions map[string]TxnTagStats `json:"transactions"`
+}
+
+// Stats returns a snapshot of the Client's pool and transaction statistics.
+func (c *Client) Stats() Stats {
+	return Stats{Pool: c.PoolStats(), Transactions: c.TransactionStats()}
+}
+
+// StatsHandler returns an http.Handler serving the Client's Stats as JSON, for
+// mounting on an internal debug route.
+func (c *Client) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.Stats())
+	})
+}
+
+// requestOptions returns the RequestOptions for a call made with ctx, or nil if
+// there are none to set.
+func requestOptions(ctx context.Context) *spanner.RequestOptions {
+	tag := transactionTag(ctx)
+	if tag == "" {
+		return nil
+	}
+	return &spanner.RequestOptions{TransactionTag: tag}
+}
@@ -0,0 +1,75 @@
+package spannerr
+
+import "github.com/pkg/errors"
+
+// TypeCode is a Spanner type code, as used for Param.Type.
+// See https://godoc.org/google.golang.org/api/spanner/v1#Type
+type TypeCode string
+
+// The Spanner type codes.
+const (
+	TypeBool      TypeCode = "BOOL"
+	TypeInt64     TypeCode = "INT64"
+	TypeFloat32   TypeCode = "FLOAT32"
+	TypeFloat64   TypeCode = "FLOAT64"
+	TypeNumeric   TypeCode = "NUMERIC"
+	TypeString    TypeCode = "STRING"
+	TypeBytes     TypeCode = "BYTES"
+	TypeJSON      TypeCode = "JSON"
+	TypeDate      TypeCode = "DATE"
+	TypeTimestamp TypeCode = "TIMESTAMP"
+	TypeInterval  TypeCode = "INTERVAL"
+	TypeUUID      TypeCode = "UUID"
+	TypeProto     TypeCode = "PROTO"
+	TypeEnum      TypeCode = "ENUM"
+	TypeArray     TypeCode = "ARRAY"
+	TypeStruct    TypeCode = "STRUCT"
+)
+
+// Valid reports whether t is a known type code.
+func (t TypeCode) Valid() bool {
+	switch t {
+	case TypeBool, TypeInt64, TypeFloat32, TypeFloat64, TypeNumeric, TypeString, TypeBytes,
+		TypeJSON, TypeDate, TypeTimestamp, TypeInterval, TypeUUID, TypeProto, TypeEnum,
+		TypeArray, TypeStruct:
+		return true
+	}
+	return false
+}
+
+// QueryMode is the mode a statement is executed in.
+// See https://godoc.org/google.golang.org/api/spanner/v1#ExecuteSqlRequest
+type QueryMode string
+
+// The query modes. An empty QueryMode is treated as QueryModeNormal.
+const (
+	// QueryModeNormal returns only the statement's results.
+	QueryModeNormal QueryMode = "NORMAL"
+	// QueryModePlan returns only the query plan, without executing the statement.
+	QueryModePlan QueryMode = "PLAN"
+	// QueryModeProfile returns the query plan and execution statistics along
+	// with the results.
+	QueryModeProfile QueryMode = "PROFILE"
+	// QueryModeWithStats returns execution statistics along with the results.
+	QueryModeWithStats QueryMode = "WITH_STATS"
+	// QueryModeWithPlanAndStats returns the query plan and execution statistics
+	// along with the results.
+	QueryModeWithPlanAndStats QueryMode = "WITH_PLAN_AND_STATS"
+)
+
+// Valid reports whether m is a known query mode or empty.
+func (m QueryMode) Valid() bool {
+	switch m {
+	case "", QueryModeNormal, QueryModePlan, QueryModeProfile, QueryModeWithStats, QueryModeWithPlanAndStats:
+		return true
+	}
+	return false
+}
+
+// checkQueryMode returns an error for an unknown query mode.
+func checkQueryMode(m QueryMode) error {
+	if !m.Valid() {
+		return errors.Errorf("invalid query mode %q", string(m))
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package spannerr
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+type userCredsKey struct{}
+
+// WithUserCredentials returns a copy of ctx that makes Session calls made with it
+// authenticate with the given token source, typically wrapping an end user's OAuth
+// token, instead of the Client's credentials. This lets multi-tenant proxies
+// execute Spanner calls under the caller's identity so IAM checks and audit logs
+// are attributed to the end user. Sessions are still drawn from the shared pool.
+func WithUserCredentials(ctx context.Context, ts oauth2.TokenSource) context.Context {
+	return context.WithValue(ctx, userCredsKey{}, ts)
+}
+
+func userCredentials(ctx context.Context) oauth2.TokenSource {
+	ts, _ := ctx.Value(userCredsKey{}).(oauth2.TokenSource)
+	return ts
+}
+
+// rpc returns the sessions service to use for a call made with ctx, honoring any
+// end user credentials it carries.
+func (s *Session) rpc(ctx context.Context) *spanner.ProjectsInstancesDatabasesSessionsService {
+	ts := userCredentials(ctx)
+	if ts == nil {
+		return s.sess
+	}
+	svc, err := s.client.newService(s.client.compress(oauth2.NewClient(ctx, ts)))
+	if err != nil {
+		// only possible with a nil HTTP client
+		return s.sess
+	}
+	return svc.Projects.Instances.Databases.Sessions
+}
+
+// httpClient returns the HTTP client to use for a call made with ctx, honoring any
+// end user credentials it carries.
+func (s *Session) httpClient(ctx context.Context) *http.Client {
+	if ts := userCredentials(ctx); ts != nil {
+		return s.client.compress(oauth2.NewClient(ctx, ts))
+	}
+	return s.hc
+}
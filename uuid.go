@@ -0,0 +1,105 @@
+package spannerr
+
+import (
+	"encoding/hex"
+	"math/bits"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// NewUUID returns a random (version 4) UUID in its canonical 36 character string
+// form, suitable for a STRING(36) primary key column. Random keys spread writes
+// evenly across splits, unlike timestamps or sequential IDs.
+func NewUUID() string {
+	return FormatUUID(NewUUIDBytes())
+}
+
+// NewUUIDBytes returns a random (version 4) UUID as 16 bytes, suitable for a
+// BYTES(16) primary key column, which takes less than half the space of its
+// string form.
+func NewUUIDBytes() []byte {
+	b := make([]byte, 16)
+	copy(b, currentIDSource().UUIDBytes())
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return b
+}
+
+// FormatUUID returns the canonical string form of a 16 byte UUID.
+func FormatUUID(b []byte) string {
+	if len(b) != 16 {
+		return ""
+	}
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf)
+}
+
+// ParseUUID returns the 16 bytes of a UUID in its canonical string form, for
+// converting between STRING and BYTES key columns.
+func ParseUUID(s string) ([]byte, error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return nil, errors.Errorf("invalid uuid %q", s)
+	}
+	b, err := hex.DecodeString(s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:])
+	return b, errors.Wrapf(err, "invalid uuid %q", s)
+}
+
+// BitReverse returns the bit-reversed positive form of a sequential value, as
+// produced by a Spanner bit_reversed_positive sequence. Keys generated from
+// an existing counter this way stay unique but no longer hot-spot the end of the
+// key range.
+func BitReverse(seq int64) int64 {
+	return int64(bits.Reverse64(uint64(seq)) >> 1)
+}
+
+// randomKey returns a random positive INT64 key.
+func randomKey() int64 {
+	return currentIDSource().Int64Key()
+}
+
+// populateKeys sets every zero-valued key field of v tagged with the auto option
+// to a new random key: a UUID string for string fields, UUID bytes for []byte
+// fields and a random positive integer for int64 fields.
+func populateKeys(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		// reported by the mutation builder
+		return nil
+	}
+	for _, f := range structFields(rv.Type()) {
+		if !f.hasOpt("auto") {
+			continue
+		}
+		fv := rv.FieldByIndex(f.index)
+		if !fv.IsZero() {
+			continue
+		}
+		if !fv.CanSet() {
+			return errors.Errorf("auto key column %q requires a pointer to the struct", f.name)
+		}
+		switch {
+		case fv.Kind() == reflect.String:
+			fv.SetString(NewUUID())
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8:
+			fv.SetBytes(NewUUIDBytes())
+		case fv.Kind() == reflect.Int64:
+			fv.SetInt(randomKey())
+		default:
+			return errors.Errorf("auto key column %q has unsupported type %s", f.name, fv.Type())
+		}
+	}
+	return nil
+}
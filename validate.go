@@ -0,0 +1,127 @@
+package spannerr
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// ValidateQuery plans sql against the database and verifies that every mapped field
+// of v, a struct or pointer to a struct, has a matching result column of a
+// compatible type. Parameters do not need to be supplied. Run it at startup or in
+// CI to catch drift between the schema and the structs queries decode into.
+func ValidateQuery(ctx context.Context, c *Client, sql string, v interface{}) error {
+	t := reflect.TypeOf(v)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return errors.Errorf("expected a struct, got %T", v)
+	}
+	sess, err := c.AcquireSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.ReleaseSession(ctx, *sess)
+	res, err := sess.ExecuteSQL(ctx, nil, sql, QueryModePlan, nil)
+	if err != nil {
+		return err
+	}
+	var fields []*spanner.Field
+	if res.Metadata != nil && res.Metadata.RowType != nil {
+		fields = res.Metadata.RowType.Fields
+	}
+	return checkStruct(fields, t)
+}
+
+// checkStruct verifies each mapped field of struct type t against the columns.
+func checkStruct(cols []*spanner.Field, t reflect.Type) error {
+	var errs []string
+	for _, f := range structFields(t) {
+		var col *spanner.Field
+		for _, c := range cols {
+			if strings.EqualFold(c.Name, f.name) {
+				col = c
+				break
+			}
+		}
+		ft := t.FieldByIndex(f.index).Type
+		switch {
+		case col == nil:
+			errs = append(errs, "no column for field "+f.name)
+		case !compatible(col.Type, ft):
+			errs = append(errs, "column "+col.Name+" of type "+typeString(col.Type)+
+				" cannot be decoded into "+ft.String())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("%s does not match query results: %s", t, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// compatible reports whether values of the Spanner type st can be decoded into gt.
+func compatible(st *spanner.Type, gt reflect.Type) bool {
+	for gt.Kind() == reflect.Ptr {
+		gt = gt.Elem()
+	}
+	if st == nil || gt.Kind() == reflect.Interface {
+		return true
+	}
+	switch gt {
+	case timeType:
+		return st.Code == "TIMESTAMP" || st.Code == "DATE"
+	case ratType:
+		return st.Code == "NUMERIC"
+	case intervalType:
+		return st.Code == "INTERVAL"
+	case dateType:
+		return st.Code == "DATE" || st.Code == "TIMESTAMP"
+	case bytesType:
+		return st.Code == "BYTES" || st.Code == "JSON" || st.Code == "PROTO"
+	}
+	switch gt.Kind() {
+	case reflect.String:
+		switch st.Code {
+		case "STRING", "JSON", "NUMERIC", "DATE", "TIMESTAMP", "INTERVAL":
+			return true
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return st.Code == "INT64" || st.Code == "ENUM"
+	case reflect.Float32, reflect.Float64:
+		return st.Code == "FLOAT64" || st.Code == "FLOAT32" || st.Code == "NUMERIC"
+	case reflect.Bool:
+		return st.Code == "BOOL"
+	case reflect.Slice:
+		return st.Code == "ARRAY" && compatible(st.ArrayElementType, gt.Elem())
+	case reflect.Struct:
+		return st.Code == "STRUCT" && st.StructType != nil &&
+			checkStruct(st.StructType.Fields, gt) == nil
+	}
+	return false
+}
+
+// typeString renders a Spanner type in SQL syntax, e.g. ARRAY<INT64>.
+func typeString(t *spanner.Type) string {
+	if t == nil {
+		return "UNKNOWN"
+	}
+	switch t.Code {
+	case "ARRAY":
+		return "ARRAY<" + typeString(t.ArrayElementType) + ">"
+	case "STRUCT":
+		if t.StructType == nil {
+			return "STRUCT<>"
+		}
+		fs := make([]string, len(t.StructType.Fields))
+		for i, f := range t.StructType.Fields {
+			fs[i] = strings.TrimSpace(f.Name + " " + typeString(f.Type))
+		}
+		return "STRUCT<" + strings.Join(fs, ", ") + ">"
+	}
+	return t.Code
+}
@@ -0,0 +1,159 @@
+package spannerr
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Embedding is a vector embedding stored in an ARRAY<FLOAT32> (or ARRAY<FLOAT64>)
+// column. Embedding fields decode directly from query results.
+type Embedding []float32
+
+// EmbeddingParam returns an ARRAY<FLOAT32> Param holding the embedding.
+func EmbeddingParam(name string, e Embedding) *Param {
+	vals := make([]interface{}, len(e))
+	for i, f := range e {
+		vals[i] = float64(f)
+	}
+	return &Param{Name: name, Value: vals, Type: TypeArray, ArrayElementType: TypeFloat32}
+}
+
+// DistanceFunc is a Spanner vector distance function.
+type DistanceFunc string
+
+const (
+	// CosineDistance uses COSINE_DISTANCE; smaller is closer.
+	CosineDistance DistanceFunc = "COSINE_DISTANCE"
+	// EuclideanDistance uses EUCLIDEAN_DISTANCE; smaller is closer.
+	EuclideanDistance DistanceFunc = "EUCLIDEAN_DISTANCE"
+	// DotProduct uses DOT_PRODUCT; larger is closer.
+	DotProduct DistanceFunc = "DOT_PRODUCT"
+)
+
+// VectorDistanceColumn is the name of the distance column returned by
+// NearestNeighbors. Map it with a float64 field tagged `spanner:"vector_distance"`.
+const VectorDistanceColumn = "vector_distance"
+
+// VectorQuery describes a nearest neighbor search over an embedding column.
+// More details can be found here: https://cloud.google.com/spanner/docs/find-k-nearest-neighbors
+type VectorQuery struct {
+	// Table is the table to search and Column its embedding column.
+	Table  string
+	Column string
+	// Columns are the columns to return. If empty, they are derived from the
+	// `spanner` tags of the destination.
+	Columns []string
+	// Target is the embedding to compare against.
+	Target Embedding
+	// Distance is the distance function. It defaults to CosineDistance.
+	Distance DistanceFunc
+	// Where is an optional additional predicate, which may reference Params.
+	Where  string
+	Params []*Param
+	// Limit is the number of neighbors to return. It is required.
+	Limit int64
+
+	// Index enables approximate nearest neighbor search using the named vector
+	// index. DotProduct is not supported for approximate searches.
+	Index string
+	// LeavesToSearch is the num_leaves_to_search option for approximate searches.
+	// Note the option is passed as a JSON literal, so Clients in LintStrict mode
+	// must allow it.
+	LeavesToSearch int
+}
+
+// SQL renders the nearest neighbor search as a parameterized statement ordered
+// from closest to furthest.
+func (q VectorQuery) SQL() (string, []*Param, error) {
+	if q.Limit <= 0 {
+		return "", nil, errors.New("a limit is required for vector searches")
+	}
+	if len(q.Columns) == 0 {
+		return "", nil, errors.New("no columns selected for vector search")
+	}
+	fn := q.Distance
+	if fn == "" {
+		fn = CosineDistance
+	}
+	switch fn {
+	case CosineDistance, EuclideanDistance, DotProduct:
+	default:
+		return "", nil, errors.Errorf("invalid distance function %q", string(fn))
+	}
+	from, err := quoteIdent(q.Table)
+	if err != nil {
+		return "", nil, err
+	}
+	col, err := quoteIdent(q.Column)
+	if err != nil {
+		return "", nil, err
+	}
+	cols := make([]string, len(q.Columns))
+	for i, c := range q.Columns {
+		if cols[i], err = quoteIdent(c); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var (
+		dist  = string(fn) + "(" + col + ", @vector_target)"
+		where = []string{}
+	)
+	if q.Index != "" {
+		if fn == DotProduct {
+			return "", nil, errors.New("approximate searches do not support DOT_PRODUCT")
+		}
+		if from, err = TableHints(q.Table, ForceIndex(q.Index)); err != nil {
+			return "", nil, err
+		}
+		dist = "APPROX_" + string(fn) + "(" + col + ", @vector_target"
+		if q.LeavesToSearch > 0 {
+			dist += `, options => JSON '{"num_leaves_to_search": ` + strconv.Itoa(q.LeavesToSearch) + `}'`
+		}
+		dist += ")"
+		// vector indexes only cover non-NULL embeddings
+		where = append(where, col+" IS NOT NULL")
+	}
+	if q.Where != "" {
+		where = append(where, "("+q.Where+")")
+	}
+	sql := "SELECT " + strings.Join(cols, ", ") + ", " + dist + " AS " + VectorDistanceColumn +
+		" FROM " + from
+	if len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	order := " ASC"
+	if fn == DotProduct {
+		order = " DESC"
+	}
+	sql += " ORDER BY " + VectorDistanceColumn + order + " LIMIT @vector_limit"
+	params := append([]*Param{
+		EmbeddingParam("vector_target", q.Target),
+		{Name: "vector_limit", Value: strconv.FormatInt(q.Limit, 10), Type: TypeInt64},
+	}, q.Params...)
+	return sql, params, nil
+}
+
+// NearestNeighbors executes the vector search and decodes the closest rows, nearest
+// first, into dst, which must be a pointer to a slice of structs.
+func (s *Session) NearestNeighbors(ctx context.Context, q VectorQuery, dst interface{}) error {
+	if len(q.Columns) == 0 {
+		cols, err := columnsOf(dst)
+		if err != nil {
+			return err
+		}
+		for _, c := range cols {
+			if c != VectorDistanceColumn {
+				q.Columns = append(q.Columns, c)
+			}
+		}
+	}
+	sql, params, err := q.SQL()
+	if err != nil {
+		return err
+	}
+	return s.query(ctx, sql, params, nil, dst)
+}